@@ -0,0 +1,283 @@
+// Package anonymize replaces the names and label values of the exports with
+// pseudonyms derived by a keyed hash, so exports can be shared without revealing
+// the cluster, while the same value is replaced by the same pseudonym in every
+// table, file and run using the same secret.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mikeskali/PerfectScalePoc/output"
+)
+
+// PseudonymLength is the number of hex characters of a pseudonym
+const PseudonymLength = 16
+
+// Anonymizer replaces values with the truncated HMAC-SHA256 of the value keyed
+// by a secret, and remembers the value of every pseudonym it returned. A nil
+// Anonymizer returns values as is.
+type Anonymizer struct {
+	key []byte
+
+	lock   sync.Mutex
+	values map[string]string
+}
+
+// NewAnonymizer creates an Anonymizer keyed by the secret
+func NewAnonymizer(secret string) *Anonymizer {
+	return &Anonymizer{
+		key:    []byte(secret),
+		values: make(map[string]string),
+	}
+}
+
+// Value returns the pseudonym of the value. Empty values stay empty.
+func (a *Anonymizer) Value(value string) string {
+	if a == nil || value == "" {
+		return value
+	}
+
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(value))
+	pseudonym := hex.EncodeToString(mac.Sum(nil))[:PseudonymLength]
+
+	a.lock.Lock()
+	a.values[pseudonym] = value
+	a.lock.Unlock()
+	return pseudonym
+}
+
+// Values returns the pseudonyms of the values
+func (a *Anonymizer) Values(values []string) []string {
+	if a == nil {
+		return values
+	}
+	pseudonyms := make([]string, len(values))
+	for i, v := range values {
+		pseudonyms[i] = a.Value(v)
+	}
+	return pseudonyms
+}
+
+// Labels returns the key:value pairs of a label cell, separated by sep, with
+// their values replaced by pseudonyms. Label keys are kept.
+func (a *Anonymizer) Labels(cell string, sep string) string {
+	if a == nil || cell == "" {
+		return cell
+	}
+	pairs := strings.Split(cell, sep)
+	for i, pair := range pairs {
+		if k := strings.Index(pair, ":"); k >= 0 {
+			pairs[i] = pair[:k+1] + a.Value(strings.TrimSpace(pair[k+1:]))
+		}
+	}
+	return strings.Join(pairs, sep)
+}
+
+// LabelMap returns the labels with their values replaced by pseudonyms
+func (a *Anonymizer) LabelMap(labels map[string]string) map[string]string {
+	if a == nil || labels == nil {
+		return labels
+	}
+	anonymized := make(map[string]string, len(labels))
+	for k, v := range labels {
+		anonymized[k] = a.Value(v)
+	}
+	return anonymized
+}
+
+// Expressions returns the key:value,value expressions of a selector cell,
+// separated by " | ", with their values replaced by pseudonyms
+func (a *Anonymizer) Expressions(cell string) string {
+	if a == nil || cell == "" {
+		return cell
+	}
+	expressions := strings.Split(cell, " | ")
+	for i, expr := range expressions {
+		k := strings.Index(expr, ":")
+		if k < 0 {
+			continue
+		}
+		values := a.Values(strings.Split(expr[k+1:], ","))
+		expressions[i] = expr[:k+1] + strings.Join(values, ",")
+	}
+	return strings.Join(expressions, " | ")
+}
+
+// nameColumns hold a pod, owner, workload, node or namespace name
+var nameColumns = map[string]bool{
+	"namespace":     true,
+	"name":          true,
+	"pod_name":      true,
+	"node_name":     true,
+	"owner_name":    true,
+	"workload_name": true,
+	"hpa_name":      true,
+}
+
+// instanceTypeTables are the prefixes of the optimizer tables whose name column
+// holds an instance type, which is kept like in the other optimizer tables
+var instanceTypeTables = []string{"solutions_", "mix_"}
+
+// labelColumns hold comma separated key:value labels
+var labelColumns = map[string]bool{
+	"node_selector":   true,
+	"label_selectors": true,
+}
+
+// opaqueColumns hold label values and names within a free form description,
+// such as an affinity, and are replaced as a whole
+var opaqueColumns = map[string]bool{
+	"node_affinity":     true,
+	"pod_affinity":      true,
+	"pod_anti_affinity": true,
+}
+
+// nodeSelectorReason matches the unschedulable reason of an unmatched node
+// selector label
+var nodeSelectorReason = regexp.MustCompile(`^(node selector [^=]+=)(.*)$`)
+
+// Table replaces the names and label values of the table with pseudonyms, by
+// the column they are in. Instance types are kept.
+func (a *Anonymizer) Table(t *output.Table) {
+	if a == nil {
+		return
+	}
+	instanceTypes := false
+	for _, prefix := range instanceTypeTables {
+		if strings.HasPrefix(t.Name, prefix) {
+			instanceTypes = true
+		}
+	}
+
+	for i, column := range t.Columns {
+		var replace func(string) string
+		switch {
+		case column == "name" && instanceTypes:
+			continue
+		case nameColumns[column], opaqueColumns[column]:
+			replace = a.Value
+		case labelColumns[column]:
+			replace = func(cell string) string { return a.Labels(cell, ",") }
+		case column == "expression_selectors":
+			replace = a.Expressions
+		case column == "reason":
+			replace = func(cell string) string {
+				if m := nodeSelectorReason.FindStringSubmatch(cell); m != nil {
+					return m[1] + a.Value(m[2])
+				}
+				return cell
+			}
+		default:
+			continue
+		}
+		for _, row := range t.Rows {
+			if i < len(row) {
+				row[i] = replace(row[i])
+			}
+		}
+	}
+}
+
+// nameKeys are the JSON keys holding a pod, owner, workload, node or namespace
+// name
+var nameKeys = map[string]bool{
+	"namespace":    true,
+	"name":         true,
+	"pod":          true,
+	"podName":      true,
+	"node":         true,
+	"nodeName":     true,
+	"ownerName":    true,
+	"workloadName": true,
+}
+
+// JSON replaces the names and label values of a JSON document with pseudonyms,
+// by the key they are under
+func (a *Anonymizer) JSON(data []byte) ([]byte, error) {
+	if a == nil {
+		return data, nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	anonymized, err := json.MarshalIndent(a.walk("", doc), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(anonymized, '\n'), nil
+}
+
+func (a *Anonymizer) walk(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if key == "labels" || key == "nodeSelector" {
+				if s, ok := child.(string); ok {
+					v[k] = a.Value(s)
+				}
+				continue
+			}
+			v[k] = a.walk(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = a.walk(key, child)
+		}
+		return v
+	case string:
+		if nameKeys[key] {
+			return a.Value(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// Mapping returns the value of every pseudonym returned so far
+func (a *Anonymizer) Mapping() map[string]string {
+	mapping := make(map[string]string)
+	if a == nil {
+		return mapping
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for pseudonym, value := range a.values {
+		mapping[pseudonym] = value
+	}
+	return mapping
+}
+
+// pseudonyms matches the pseudonyms within a text
+var pseudonyms = regexp.MustCompile(`[0-9a-f]{16}`)
+
+// Reveal replaces the pseudonyms of the mapping found in the text with their
+// values. Other hex strings are kept.
+func Reveal(text []byte, mapping map[string]string) []byte {
+	return pseudonyms.ReplaceAllFunc(text, func(pseudonym []byte) []byte {
+		if value, ok := mapping[string(pseudonym)]; ok {
+			return []byte(value)
+		}
+		return pseudonym
+	})
+}
+
+// sortedPseudonyms returns the pseudonyms of the mapping in order
+func sortedPseudonyms(mapping map[string]string) []string {
+	keys := make([]string, 0, len(mapping))
+	for k := range mapping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,208 @@
+package anonymize
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/mikeskali/PerfectScalePoc/output"
+)
+
+var hex16 = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func TestValue(t *testing.T) {
+	a := NewAnonymizer("secret")
+
+	pseudonym := a.Value("web")
+	if !hex16.MatchString(pseudonym) {
+		t.Fatalf("expected 16 hex characters, got %q", pseudonym)
+	}
+	if again := a.Value("web"); again != pseudonym {
+		t.Errorf("expected the same pseudonym, got %s and %s", pseudonym, again)
+	}
+	if other := NewAnonymizer("secret").Value("web"); other != pseudonym {
+		t.Errorf("expected the same pseudonym with the same secret, got %s and %s", pseudonym, other)
+	}
+	if other := NewAnonymizer("other").Value("web"); other == pseudonym {
+		t.Error("expected another pseudonym with another secret")
+	}
+	if a.Value("db") == pseudonym {
+		t.Error("expected another pseudonym for another value")
+	}
+	if a.Value("") != "" {
+		t.Error("expected empty values to stay empty")
+	}
+
+	var none *Anonymizer
+	if none.Value("web") != "web" {
+		t.Error("expected a nil anonymizer to keep the value")
+	}
+
+	expected := map[string]string{pseudonym: "web", a.Value("db"): "db"}
+	if mapping := a.Mapping(); !reflect.DeepEqual(mapping, expected) {
+		t.Errorf("expected the mapping %v, got %v", expected, mapping)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	a := NewAnonymizer("secret")
+
+	tests := []struct {
+		name     string
+		actual   string
+		expected string
+	}{
+		{
+			name:     "labels",
+			actual:   a.Labels("app:web,tier: frontend", ","),
+			expected: "app:" + a.Value("web") + ",tier:" + a.Value("frontend"),
+		},
+		{name: "empty labels", actual: a.Labels("", ","), expected: ""},
+		{
+			name:     "expressions",
+			actual:   a.Expressions("app:web,api | tier:frontend"),
+			expected: "app:" + a.Value("web") + "," + a.Value("api") + " | tier:" + a.Value("frontend"),
+		},
+		{name: "expression without values", actual: a.Expressions("tier"), expected: "tier"},
+	}
+
+	for _, test := range tests {
+		if test.actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, test.actual)
+		}
+	}
+
+	expected := map[string]string{"app": a.Value("web")}
+	if labels := a.LabelMap(map[string]string{"app": "web"}); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected %v, got %v", expected, labels)
+	}
+}
+
+func TestTable(t *testing.T) {
+	a := NewAnonymizer("secret")
+	h := a.Value
+
+	tests := []struct {
+		name     string
+		table    *output.Table
+		expected [][]string
+	}{
+		{
+			name: "pods",
+			table: &output.Table{
+				Name:    "pods.csv",
+				Columns: []string{"pod_name", "node_name", "namespace", "owner_kind", "owner_name", "req_cpu_milli_core"},
+				Rows:    [][]string{{"web-1", "node-a", "default", "Deployment", "web", "500"}},
+			},
+			expected: [][]string{{h("web-1"), h("node-a"), h("default"), "Deployment", h("web"), "500"}},
+		},
+		{
+			name: "deployments",
+			table: &output.Table{
+				Name:    "deployments.csv",
+				Columns: []string{"namespace", "name", "node_selector", "expression_selectors", "node_affinity"},
+				Rows:    [][]string{{"default", "web", "pool:general", "zone:a,b", "zone In [a]"}},
+			},
+			expected: [][]string{{h("default"), h("web"), "pool:" + h("general"), "zone:" + h("a") + "," + h("b"), h("zone In [a]")}},
+		},
+		{
+			name: "solutions",
+			table: &output.Table{
+				Name:    "solutions_0.csv",
+				Columns: []string{"name", "num_nodes"},
+				Rows:    [][]string{{"m5.large", "2"}},
+			},
+			expected: [][]string{{"m5.large", "2"}},
+		},
+		{
+			name: "mix",
+			table: &output.Table{
+				Name:    "mix_0.csv",
+				Columns: []string{"name", "num_nodes"},
+				Rows:    [][]string{{"m5.large", "2"}},
+			},
+			expected: [][]string{{"m5.large", "2"}},
+		},
+		{
+			name: "unschedulable",
+			table: &output.Table{
+				Name:    "unschedulable_0.csv",
+				Columns: []string{"instance_type", "pod_name", "reason"},
+				Rows: [][]string{
+					{"m5.large", "db-0", "node selector pool=memory"},
+					{"m5.large", "db-1", "required node affinity"},
+				},
+			},
+			expected: [][]string{
+				{"m5.large", h("db-0"), "node selector pool=" + h("memory")},
+				{"m5.large", h("db-1"), "required node affinity"},
+			},
+		},
+		{
+			// short rows are left as is past their end
+			name: "short row",
+			table: &output.Table{
+				Name:    "pods.csv",
+				Columns: []string{"cpu", "pod_name"},
+				Rows:    [][]string{{"500"}},
+			},
+			expected: [][]string{{"500"}},
+		},
+	}
+
+	for _, test := range tests {
+		a.Table(test.table)
+		if !reflect.DeepEqual(test.table.Rows, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, test.table.Rows)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	a := NewAnonymizer("secret")
+	data := []byte(`{
+		"allocations": [{"namespace": "default", "ownerKind": "Deployment", "ownerName": "web", "hourly": 0.5}],
+		"pod": {"name": "web-1", "labels": {"app": "web"}, "nodeSelector": {"pool": "general"}}
+	}`)
+
+	anonymized, err := a.JSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual interface{}
+	if err := json.Unmarshal(anonymized, &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"allocations": []interface{}{map[string]interface{}{
+			"namespace": a.Value("default"),
+			"ownerKind": "Deployment",
+			"ownerName": a.Value("web"),
+			"hourly":    0.5,
+		}},
+		"pod": map[string]interface{}{
+			"name":         a.Value("web-1"),
+			"labels":       map[string]interface{}{"app": a.Value("web")},
+			"nodeSelector": map[string]interface{}{"pool": a.Value("general")},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	if _, err := a.JSON([]byte("{")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestReveal(t *testing.T) {
+	a := NewAnonymizer("secret")
+	text := []byte("web is " + a.Value("web") + ", the commit 0123456789abcdef is kept")
+
+	revealed := Reveal(text, a.Mapping())
+	if expected := "web is web, the commit 0123456789abcdef is kept"; string(revealed) != expected {
+		t.Errorf("expected %q, got %q", expected, revealed)
+	}
+}
@@ -0,0 +1,81 @@
+package anonymize
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WriteMapping writes a mapping.csv formatted list of the pseudonyms and their
+// values
+func WriteMapping(w io.Writer, mapping map[string]string) error {
+	records := [][]string{
+		{"pseudonym", "value"},
+	}
+	for _, pseudonym := range sortedPseudonyms(mapping) {
+		records = append(records, []string{pseudonym, mapping[pseudonym]})
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// ReadMapping reads a mapping written by WriteMapping
+func ReadMapping(r io.Reader) (map[string]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading mapping: %s", err)
+	}
+	mapping := make(map[string]string)
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, fmt.Errorf("failed reading mapping: line %d has %d fields, expected 2", i+1, len(record))
+		}
+		mapping[record[0]] = record[1]
+	}
+	return mapping, nil
+}
+
+// ReadMappingFile reads the mapping from the file at path
+func ReadMappingFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadMapping(f)
+}
+
+// UpdateMappingFile adds the mapping to the mapping file at path, creating it
+// when missing, so the file maps the pseudonyms of every run using the same
+// secret
+func UpdateMappingFile(path string, mapping map[string]string) error {
+	merged, err := ReadMappingFile(path)
+	if os.IsNotExist(err) {
+		merged = make(map[string]string)
+	} else if err != nil {
+		return err
+	}
+	for pseudonym, value := range mapping {
+		merged[pseudonym] = value
+	}
+
+	// the mapping reveals the anonymized values, so only the owner can read it,
+	// even when an existing file was readable by others
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := WriteMapping(f, merged); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,96 @@
+package anonymize
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMapping(t *testing.T) {
+	mapping := map[string]string{"1111111111111111": "web", "0000000000000000": "db, primary"}
+
+	var buf bytes.Buffer
+	if err := WriteMapping(&buf, mapping); err != nil {
+		t.Fatal(err)
+	}
+	expected := "pseudonym,value\n0000000000000000,\"db, primary\"\n1111111111111111,web\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	read, err := ReadMapping(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, mapping) {
+		t.Errorf("expected %v, got %v", mapping, read)
+	}
+
+	if _, err := ReadMapping(strings.NewReader("pseudonym,value\n0000000000000000,web\n1111111111111111\n")); err == nil {
+		t.Error("expected an error for a record without a value")
+	}
+}
+
+func TestUpdateMappingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mapping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mapping.csv")
+
+	a := NewAnonymizer("secret")
+	a.Value("web")
+	if err := UpdateMappingFile(path, a.Mapping()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a later run adds its pseudonyms to the ones of the first run
+	b := NewAnonymizer("secret")
+	b.Value("db")
+	if err := UpdateMappingFile(path, b.Mapping()); err != nil {
+		t.Fatal(err)
+	}
+
+	mapping, err := ReadMappingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{a.Value("web"): "web", b.Value("db"): "db"}
+	if !reflect.DeepEqual(mapping, expected) {
+		t.Errorf("expected %v, got %v", expected, mapping)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected the mapping file to be readable by its owner only, got %v", mode)
+	}
+
+	// an existing mapping file readable by others is restricted as well
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateMappingFile(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("expected the existing mapping file to be restricted to its owner, got %v", mode)
+	}
+
+	if err := UpdateMappingFile(filepath.Join(dir, "missing", "mapping.csv"), expected); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+	if _, err := ReadMappingFile(filepath.Join(dir, "missing.csv")); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
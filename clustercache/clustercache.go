@@ -2,15 +2,25 @@ package clustercache
 
 import (
 	"sync"
+	"time"
 
 	"github.com/mikeskali/PerfectScalePoc/env"
 	"k8s.io/klog"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	stv1 "k8s.io/api/storage/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	rt "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 // ClusterCache defines an contract for an object which caches components within a cluster, ensuring
@@ -50,14 +60,138 @@ type ClusterCache interface {
 	// GetAllReplicaSets returns all the cached ReplicaSets
 	GetAllReplicaSets() []*appsv1.ReplicaSet
 
+	// GetAllJobs returns all the cached Jobs
+	GetAllJobs() []*batchv1.Job
+
 	// GetAllPersistentVolumes returns all the cached persistent volumes
 	GetAllPersistentVolumes() []*v1.PersistentVolume
 
 	// GetAllStorageClasses returns all the cached storage classes
 	GetAllStorageClasses() []*stv1.StorageClass
 
+	// GetAllPersistentVolumeClaims returns all the cached persistent volume claims
+	GetAllPersistentVolumeClaims() []*v1.PersistentVolumeClaim
+
+	// GetAllPodDisruptionBudgets returns all the cached pod disruption budgets
+	GetAllPodDisruptionBudgets() []*policyv1beta1.PodDisruptionBudget
+
+	// GetAllCronJobs returns all the cached CronJobs
+	GetAllCronJobs() []*batchv1beta1.CronJob
+
+	// GetAllHorizontalPodAutoscalers returns all the cached horizontal pod autoscalers
+	GetAllHorizontalPodAutoscalers() []*autoscalingv1.HorizontalPodAutoscaler
+
+	// GetAllPriorityClasses returns all the cached priority classes
+	GetAllPriorityClasses() []*schedulingv1.PriorityClass
+
+	// GetAllLimitRanges returns all the cached limit ranges
+	GetAllLimitRanges() []*v1.LimitRange
+
+	// GetAllResourceQuotas returns all the cached resource quotas
+	GetAllResourceQuotas() []*v1.ResourceQuota
+
+	// GetPodsOnNode returns the cached pods scheduled on the node
+	GetPodsOnNode(nodeName string) []*v1.Pod
+
+	// GetPodsInNamespace returns the cached pods of the namespace
+	GetPodsInNamespace(namespace string) []*v1.Pod
+
+	// GetPodsForOwner returns the cached pods with an owner reference to the uid
+	GetPodsForOwner(uid types.UID) []*v1.Pod
+
+	// GetNodesOfInstanceType returns the cached nodes of the instance type
+	GetNodesOfInstanceType(instanceType string) []*v1.Node
+
 	// SetConfigMapUpdateFunc sets the configmap update function
 	SetConfigMapUpdateFunc(func(interface{}))
+
+	// SetPodUpdateFunc sets the function called with every added or updated pod
+	SetPodUpdateFunc(func(interface{}))
+
+	// SetPodRemovedFunc sets the function called with the key of every removed pod
+	SetPodRemovedFunc(func(interface{}))
+
+	// SetNodeUpdateFunc sets the function called with every added or updated node
+	SetNodeUpdateFunc(func(interface{}))
+
+	// SetNodeRemovedFunc sets the function called with the key of every removed node
+	SetNodeRemovedFunc(func(interface{}))
+
+	// SubscribeNamespaces adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) namespaces
+	SubscribeNamespaces(func(old *v1.Namespace, new *v1.Namespace))
+
+	// SubscribeNodes adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) nodes
+	SubscribeNodes(func(old *v1.Node, new *v1.Node))
+
+	// SubscribePods adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) pods
+	SubscribePods(func(old *v1.Pod, new *v1.Pod))
+
+	// SubscribeConfigMaps adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) kubecost configmaps
+	SubscribeConfigMaps(func(old *v1.ConfigMap, new *v1.ConfigMap))
+
+	// SubscribeServices adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) services
+	SubscribeServices(func(old *v1.Service, new *v1.Service))
+
+	// SubscribeDaemonSets adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) DaemonSets
+	SubscribeDaemonSets(func(old *appsv1.DaemonSet, new *appsv1.DaemonSet))
+
+	// SubscribeDeployments adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) deployments
+	SubscribeDeployments(func(old *appsv1.Deployment, new *appsv1.Deployment))
+
+	// SubscribeStatefulSets adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) StatefulSets
+	SubscribeStatefulSets(func(old *appsv1.StatefulSet, new *appsv1.StatefulSet))
+
+	// SubscribeReplicaSets adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) ReplicaSets
+	SubscribeReplicaSets(func(old *appsv1.ReplicaSet, new *appsv1.ReplicaSet))
+
+	// SubscribeJobs adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) Jobs
+	SubscribeJobs(func(old *batchv1.Job, new *batchv1.Job))
+
+	// SubscribePersistentVolumes adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) persistent volumes
+	SubscribePersistentVolumes(func(old *v1.PersistentVolume, new *v1.PersistentVolume))
+
+	// SubscribeStorageClasses adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) storage classes
+	SubscribeStorageClasses(func(old *stv1.StorageClass, new *stv1.StorageClass))
+
+	// SubscribePersistentVolumeClaims adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) persistent volume claims
+	SubscribePersistentVolumeClaims(func(old *v1.PersistentVolumeClaim, new *v1.PersistentVolumeClaim))
+
+	// SubscribePodDisruptionBudgets adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) pod disruption budgets
+	SubscribePodDisruptionBudgets(func(old *policyv1beta1.PodDisruptionBudget, new *policyv1beta1.PodDisruptionBudget))
+
+	// SubscribeCronJobs adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) CronJobs
+	SubscribeCronJobs(func(old *batchv1beta1.CronJob, new *batchv1beta1.CronJob))
+
+	// SubscribeHorizontalPodAutoscalers adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) horizontal pod autoscalers
+	SubscribeHorizontalPodAutoscalers(func(old *autoscalingv1.HorizontalPodAutoscaler, new *autoscalingv1.HorizontalPodAutoscaler))
+
+	// SubscribePriorityClasses adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) priority classes
+	SubscribePriorityClasses(func(old *schedulingv1.PriorityClass, new *schedulingv1.PriorityClass))
+
+	// SubscribeLimitRanges adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) limit ranges
+	SubscribeLimitRanges(func(old *v1.LimitRange, new *v1.LimitRange))
+
+	// SubscribeResourceQuotas adds a handler receiving the previous and current state of the
+	// added (old is nil), updated and deleted (new is nil) resource quotas
+	SubscribeResourceQuotas(func(old *v1.ResourceQuota, new *v1.ResourceQuota))
 }
 
 // KubernetesClusterCache is the implementation of ClusterCache
@@ -73,8 +207,16 @@ type KubernetesClusterCache struct {
 	deploymentsWatch       WatchController
 	statefulsetWatch       WatchController
 	replicasetWatch        WatchController
+	jobWatch               WatchController
 	pvWatch                WatchController
 	storageClassWatch      WatchController
+	pvcWatch               WatchController
+	pdbWatch               WatchController
+	cronJobWatch           WatchController
+	hpaWatch               WatchController
+	priorityClassWatch     WatchController
+	limitRangeWatch        WatchController
+	resourceQuotaWatch     WatchController
 	stop                   chan struct{}
 }
 
@@ -83,70 +225,237 @@ func initializeCache(wc WatchController, wg *sync.WaitGroup, cancel chan struct{
 	wc.WarmUp(cancel)
 }
 
+// optionalWatch is a watcher of resources the cluster may not serve, such as
+// beta APIs removed by later Kubernetes versions, or may not let the cache list
+type optionalWatch struct {
+	wc       WatchController
+	resource string
+
+	// served is false when the cluster doesn't serve the resources, which are
+	// then not watched at all
+	served bool
+
+	// failed receives the errors listing the resources which retrying won't fix
+	failed chan error
+}
+
+// newOptionalWatch creates the watcher of the resources listed and watched by
+// the list watcher, which is only run when served
+func newOptionalWatch(lw cache.ListerWatcher, resource string, resourceType rt.Object, served bool) *optionalWatch {
+	failed := make(chan error, 1)
+	return &optionalWatch{
+		wc:       NewCachingWatcher(reportListErrors(lw, failed), resource, resourceType, cache.Indexers{}),
+		resource: resource,
+		served:   served,
+		failed:   failed,
+	}
+}
+
+// initializeOptionalCache warms up the optional watcher, giving up when listing
+// its resources is forbidden or not found, or when the timeout expires. The
+// watcher is then stopped and stays empty, so such resources don't hold up the
+// cache.
+func initializeOptionalCache(o *optionalWatch, wg *sync.WaitGroup, timeout time.Duration) {
+	defer wg.Done()
+	if !o.served {
+		klog.Warningf("The cluster doesn't serve %s, they are left out of the cache", o.resource)
+		return
+	}
+
+	cancel := make(chan struct{})
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		o.wc.WarmUp(cancel)
+	}()
+
+	select {
+	case <-synced:
+		return
+	case err := <-o.failed:
+		klog.Warningf("Failed listing %s, they are left out of the cache: %s", o.resource, err)
+	case <-time.After(timeout):
+		klog.Warningf("Timed out listing %s after %s, they are left out of the cache", o.resource, timeout)
+	}
+	close(cancel)
+	<-synced
+}
+
+// reportListErrors returns the list watcher sending the errors listing the
+// resources which retrying won't fix, i.e. resources which aren't served or
+// which listing is forbidden, to failed
+func reportListErrors(lw cache.ListerWatcher, failed chan error) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			list, err := lw.List(options)
+			if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+				select {
+				case failed <- err:
+				default:
+				}
+			}
+			return list, err
+		},
+		WatchFunc: lw.Watch,
+	}
+}
+
+// serves returns true if the cluster serves the resource of the group version
+func serves(client kubernetes.Interface, groupVersion string, resource string) bool {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil || resources == nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures the cluster cache
+type Options struct {
+	// Shared returns the cached resources themselves from the getters and to the
+	// subscribers, rather than deep copies of them, which saves copying every
+	// resource on hot paths. Callers must then treat the resources as read-only,
+	// as modifying them corrupts the cache.
+	Shared bool
+
+	// WarmUpTimeout bounds the warm-up of the watchers of the optional resources:
+	// jobs, cronjobs, persistent volume claims, pod disruption budgets, horizontal
+	// pod autoscalers, priority classes, limit ranges and resource quotas. Those
+	// not listed in time are left out of the cache.
+	WarmUpTimeout time.Duration
+}
+
+// DefaultOptions returns copies of the cached resources and waits a minute for
+// the optional resources
+func DefaultOptions() *Options {
+	return &Options{
+		Shared:        false,
+		WarmUpTimeout: time.Minute,
+	}
+}
+
+// NewKubernetesClusterCache creates a cache of the cluster returning copies of
+// the cached resources
 func NewKubernetesClusterCache(client kubernetes.Interface) ClusterCache {
-	coreRestClient := client.CoreV1().RESTClient()
-	appsRestClient := client.AppsV1().RESTClient()
-	storageRestClient := client.StorageV1().RESTClient()
-	
+	return NewKubernetesClusterCacheWithOptions(client, DefaultOptions())
+}
+
+// NewKubernetesClusterCacheWithOptions creates a cache of the cluster configured
+// by the options
+func NewKubernetesClusterCacheWithOptions(client kubernetes.Interface, options *Options) ClusterCache {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if options.WarmUpTimeout <= 0 {
+		withTimeout := *options
+		withTimeout.WarmUpTimeout = DefaultOptions().WarmUpTimeout
+		options = &withTimeout
+	}
 
 	kubecostNamespace := env.GetKubecostNamespace()
 	klog.Infof("NAMESPACE: %s", kubecostNamespace)
 
+	// resources which may not be served or listed don't hold up the cache. Beta
+	// APIs are only watched when the cluster serves them.
+	jobs := newOptionalWatch(jobListWatch(client), "jobs", &batchv1.Job{}, true)
+	pvcs := newOptionalWatch(pvcListWatch(client), "persistentvolumeclaims", &v1.PersistentVolumeClaim{}, true)
+	pdbs := newOptionalWatch(pdbListWatch(client), "poddisruptionbudgets", &policyv1beta1.PodDisruptionBudget{}, serves(client, "policy/v1beta1", "poddisruptionbudgets"))
+	cronJobs := newOptionalWatch(cronJobListWatch(client), "cronjobs", &batchv1beta1.CronJob{}, serves(client, "batch/v1beta1", "cronjobs"))
+	hpas := newOptionalWatch(hpaListWatch(client), "horizontalpodautoscalers", &autoscalingv1.HorizontalPodAutoscaler{}, true)
+	priorityClasses := newOptionalWatch(priorityClassListWatch(client), "priorityclasses", &schedulingv1.PriorityClass{}, true)
+	limitRanges := newOptionalWatch(limitRangeListWatch(client), "limitranges", &v1.LimitRange{}, true)
+	resourceQuotas := newOptionalWatch(resourceQuotaListWatch(client), "resourcequotas", &v1.ResourceQuota{}, true)
+	optional := []*optionalWatch{jobs, pvcs, pdbs, cronJobs, hpas, priorityClasses, limitRanges, resourceQuotas}
+
 	kcc := &KubernetesClusterCache{
 		client:                 client,
-		namespaceWatch:         NewCachingWatcher(coreRestClient, "namespaces", &v1.Namespace{}, "", fields.Everything()),
-		nodeWatch:              NewCachingWatcher(coreRestClient, "nodes", &v1.Node{}, "", fields.Everything()),
-		podWatch:               NewCachingWatcher(coreRestClient, "pods", &v1.Pod{}, "", fields.Everything()),
-		kubecostConfigMapWatch: NewCachingWatcher(coreRestClient, "configmaps", &v1.ConfigMap{}, kubecostNamespace, fields.Everything()),
-		serviceWatch:           NewCachingWatcher(coreRestClient, "services", &v1.Service{}, "", fields.Everything()),
-		daemonsetsWatch:        NewCachingWatcher(appsRestClient, "daemonsets", &appsv1.DaemonSet{}, "", fields.Everything()),
-		deploymentsWatch:       NewCachingWatcher(appsRestClient, "deployments", &appsv1.Deployment{}, "", fields.Everything()),
-		statefulsetWatch:       NewCachingWatcher(appsRestClient, "statefulsets", &appsv1.StatefulSet{}, "", fields.Everything()),
-		replicasetWatch:        NewCachingWatcher(appsRestClient, "replicasets", &appsv1.ReplicaSet{}, "", fields.Everything()),
-		pvWatch:                NewCachingWatcher(coreRestClient, "persistentvolumes", &v1.PersistentVolume{}, "", fields.Everything()),
-		storageClassWatch:      NewCachingWatcher(storageRestClient, "storageclasses", &stv1.StorageClass{}, "", fields.Everything()),
-	}
-
-	// Wait for each caching watcher to initialize
-	var wg sync.WaitGroup
-	wg.Add(11)
+		namespaceWatch:         NewCachingWatcher(namespaceListWatch(client), "namespaces", &v1.Namespace{}, cache.Indexers{}),
+		nodeWatch:              NewCachingWatcher(nodeListWatch(client), "nodes", &v1.Node{}, nodeIndexers),
+		podWatch:               NewCachingWatcher(podListWatch(client), "pods", &v1.Pod{}, podIndexers),
+		kubecostConfigMapWatch: NewCachingWatcher(configMapListWatch(client, kubecostNamespace), "configmaps", &v1.ConfigMap{}, cache.Indexers{}),
+		serviceWatch:           NewCachingWatcher(serviceListWatch(client), "services", &v1.Service{}, cache.Indexers{}),
+		daemonsetsWatch:        NewCachingWatcher(daemonSetListWatch(client), "daemonsets", &appsv1.DaemonSet{}, cache.Indexers{}),
+		deploymentsWatch:       NewCachingWatcher(deploymentListWatch(client), "deployments", &appsv1.Deployment{}, cache.Indexers{}),
+		statefulsetWatch:       NewCachingWatcher(statefulSetListWatch(client), "statefulsets", &appsv1.StatefulSet{}, cache.Indexers{}),
+		replicasetWatch:        NewCachingWatcher(replicaSetListWatch(client), "replicasets", &appsv1.ReplicaSet{}, cache.Indexers{}),
+		jobWatch:               jobs.wc,
+		pvWatch:                NewCachingWatcher(pvListWatch(client), "persistentvolumes", &v1.PersistentVolume{}, cache.Indexers{}),
+		storageClassWatch:      NewCachingWatcher(storageClassListWatch(client), "storageclasses", &stv1.StorageClass{}, cache.Indexers{}),
+		pvcWatch:               pvcs.wc,
+		pdbWatch:               pdbs.wc,
+		cronJobWatch:           cronJobs.wc,
+		hpaWatch:               hpas.wc,
+		priorityClassWatch:     priorityClasses.wc,
+		limitRangeWatch:        limitRanges.wc,
+		resourceQuotaWatch:     resourceQuotas.wc,
+	}
 
+	for _, wc := range kcc.watchers() {
+		wc.SetShared(options.Shared)
+	}
+
+	// Wait for each caching watcher to initialize, the optional ones at most for
+	// the warm-up timeout
+	var wg sync.WaitGroup
 	cancel := make(chan struct{})
 
-	go initializeCache(kcc.namespaceWatch, &wg, cancel)
-	go initializeCache(kcc.nodeWatch, &wg, cancel)
-	go initializeCache(kcc.podWatch, &wg, cancel)
-	go initializeCache(kcc.kubecostConfigMapWatch, &wg, cancel)
-	go initializeCache(kcc.serviceWatch, &wg, cancel)
-	go initializeCache(kcc.daemonsetsWatch, &wg, cancel)
-	go initializeCache(kcc.deploymentsWatch, &wg, cancel)
-	go initializeCache(kcc.statefulsetWatch, &wg, cancel)
-	go initializeCache(kcc.replicasetWatch, &wg, cancel)
-	go initializeCache(kcc.pvWatch, &wg, cancel)
-	go initializeCache(kcc.storageClassWatch, &wg, cancel)
+	isOptional := make(map[WatchController]bool)
+	for _, o := range optional {
+		isOptional[o.wc] = true
+		wg.Add(1)
+		go initializeOptionalCache(o, &wg, options.WarmUpTimeout)
+	}
+	for _, wc := range kcc.watchers() {
+		if !isOptional[wc] {
+			wg.Add(1)
+			go initializeCache(wc, &wg, cancel)
+		}
+	}
 
 	wg.Wait()
 
 	return kcc
 }
 
+// watchers returns every watcher of the cache, which are all warmed up and run
+// together
+func (kcc *KubernetesClusterCache) watchers() []WatchController {
+	return []WatchController{
+		kcc.namespaceWatch,
+		kcc.nodeWatch,
+		kcc.podWatch,
+		kcc.kubecostConfigMapWatch,
+		kcc.serviceWatch,
+		kcc.daemonsetsWatch,
+		kcc.deploymentsWatch,
+		kcc.statefulsetWatch,
+		kcc.replicasetWatch,
+		kcc.jobWatch,
+		kcc.pvWatch,
+		kcc.storageClassWatch,
+		kcc.pvcWatch,
+		kcc.pdbWatch,
+		kcc.cronJobWatch,
+		kcc.hpaWatch,
+		kcc.priorityClassWatch,
+		kcc.limitRangeWatch,
+		kcc.resourceQuotaWatch,
+	}
+}
+
 func (kcc *KubernetesClusterCache) Run() {
 	if kcc.stop != nil {
 		return
 	}
 	stopCh := make(chan struct{})
 
-	go kcc.namespaceWatch.Run(1, stopCh)
-	go kcc.nodeWatch.Run(1, stopCh)
-	go kcc.podWatch.Run(1, stopCh)
-	go kcc.serviceWatch.Run(1, stopCh)
-	go kcc.kubecostConfigMapWatch.Run(1, stopCh)
-	go kcc.daemonsetsWatch.Run(1, stopCh)
-	go kcc.deploymentsWatch.Run(1, stopCh)
-	go kcc.statefulsetWatch.Run(1, stopCh)
-	go kcc.replicasetWatch.Run(1, stopCh)
-	go kcc.pvWatch.Run(1, stopCh)
-	go kcc.storageClassWatch.Run(1, stopCh)
+	for _, wc := range kcc.watchers() {
+		go wc.Run(1, stopCh)
+	}
 
 	kcc.stop = stopCh
 }
@@ -236,6 +545,15 @@ func (kcc *KubernetesClusterCache) GetAllReplicaSets() []*appsv1.ReplicaSet {
 	return replicasets
 }
 
+func (kcc *KubernetesClusterCache) GetAllJobs() []*batchv1.Job {
+	var jobs []*batchv1.Job
+	items := kcc.jobWatch.GetAll()
+	for _, job := range items {
+		jobs = append(jobs, job.(*batchv1.Job))
+	}
+	return jobs
+}
+
 func (kcc *KubernetesClusterCache) GetAllPersistentVolumes() []*v1.PersistentVolume {
 	var pvs []*v1.PersistentVolume
 	items := kcc.pvWatch.GetAll()
@@ -254,6 +572,266 @@ func (kcc *KubernetesClusterCache) GetAllStorageClasses() []*stv1.StorageClass {
 	return storageClasses
 }
 
+func (kcc *KubernetesClusterCache) GetAllPersistentVolumeClaims() []*v1.PersistentVolumeClaim {
+	var pvcs []*v1.PersistentVolumeClaim
+	items := kcc.pvcWatch.GetAll()
+	for _, pvc := range items {
+		pvcs = append(pvcs, pvc.(*v1.PersistentVolumeClaim))
+	}
+	return pvcs
+}
+
+func (kcc *KubernetesClusterCache) GetAllPodDisruptionBudgets() []*policyv1beta1.PodDisruptionBudget {
+	var pdbs []*policyv1beta1.PodDisruptionBudget
+	items := kcc.pdbWatch.GetAll()
+	for _, pdb := range items {
+		pdbs = append(pdbs, pdb.(*policyv1beta1.PodDisruptionBudget))
+	}
+	return pdbs
+}
+
+func (kcc *KubernetesClusterCache) GetAllCronJobs() []*batchv1beta1.CronJob {
+	var cronJobs []*batchv1beta1.CronJob
+	items := kcc.cronJobWatch.GetAll()
+	for _, cronJob := range items {
+		cronJobs = append(cronJobs, cronJob.(*batchv1beta1.CronJob))
+	}
+	return cronJobs
+}
+
+func (kcc *KubernetesClusterCache) GetAllHorizontalPodAutoscalers() []*autoscalingv1.HorizontalPodAutoscaler {
+	var hpas []*autoscalingv1.HorizontalPodAutoscaler
+	items := kcc.hpaWatch.GetAll()
+	for _, hpa := range items {
+		hpas = append(hpas, hpa.(*autoscalingv1.HorizontalPodAutoscaler))
+	}
+	return hpas
+}
+
+func (kcc *KubernetesClusterCache) GetAllPriorityClasses() []*schedulingv1.PriorityClass {
+	var priorityClasses []*schedulingv1.PriorityClass
+	items := kcc.priorityClassWatch.GetAll()
+	for _, pc := range items {
+		priorityClasses = append(priorityClasses, pc.(*schedulingv1.PriorityClass))
+	}
+	return priorityClasses
+}
+
+func (kcc *KubernetesClusterCache) GetAllLimitRanges() []*v1.LimitRange {
+	var limitRanges []*v1.LimitRange
+	items := kcc.limitRangeWatch.GetAll()
+	for _, lr := range items {
+		limitRanges = append(limitRanges, lr.(*v1.LimitRange))
+	}
+	return limitRanges
+}
+
+func (kcc *KubernetesClusterCache) GetAllResourceQuotas() []*v1.ResourceQuota {
+	var quotas []*v1.ResourceQuota
+	items := kcc.resourceQuotaWatch.GetAll()
+	for _, quota := range items {
+		quotas = append(quotas, quota.(*v1.ResourceQuota))
+	}
+	return quotas
+}
+
+func (kcc *KubernetesClusterCache) GetPodsOnNode(nodeName string) []*v1.Pod {
+	return toPods(kcc.podWatch.GetByIndex(PodNodeIndex, nodeName))
+}
+
+func (kcc *KubernetesClusterCache) GetPodsInNamespace(namespace string) []*v1.Pod {
+	return toPods(kcc.podWatch.GetByIndex(NamespaceIndex, namespace))
+}
+
+func (kcc *KubernetesClusterCache) GetPodsForOwner(uid types.UID) []*v1.Pod {
+	return toPods(kcc.podWatch.GetByIndex(PodOwnerIndex, string(uid)))
+}
+
+func (kcc *KubernetesClusterCache) GetNodesOfInstanceType(instanceType string) []*v1.Node {
+	var nodes []*v1.Node
+	items := kcc.nodeWatch.GetByIndex(NodeInstanceTypeIndex, instanceType)
+	for _, node := range items {
+		nodes = append(nodes, node.(*v1.Node))
+	}
+	return nodes
+}
+
+func toPods(items []interface{}) []*v1.Pod {
+	var pods []*v1.Pod
+	for _, pod := range items {
+		pods = append(pods, pod.(*v1.Pod))
+	}
+	return pods
+}
+
 func (kcc *KubernetesClusterCache) SetConfigMapUpdateFunc(f func(interface{})) {
 	kcc.kubecostConfigMapWatch.SetUpdateHandler(f)
 }
+
+func (kcc *KubernetesClusterCache) SetPodUpdateFunc(f func(interface{})) {
+	kcc.podWatch.SetUpdateHandler(f)
+}
+
+func (kcc *KubernetesClusterCache) SetPodRemovedFunc(f func(interface{})) {
+	kcc.podWatch.SetRemovedHandler(f)
+}
+
+func (kcc *KubernetesClusterCache) SetNodeUpdateFunc(f func(interface{})) {
+	kcc.nodeWatch.SetUpdateHandler(f)
+}
+
+func (kcc *KubernetesClusterCache) SetNodeRemovedFunc(f func(interface{})) {
+	kcc.nodeWatch.SetRemovedHandler(f)
+}
+
+func (kcc *KubernetesClusterCache) SubscribeNamespaces(handler func(old *v1.Namespace, new *v1.Namespace)) {
+	kcc.namespaceWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.Namespace)
+		n, _ := new.(*v1.Namespace)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeNodes(handler func(old *v1.Node, new *v1.Node)) {
+	kcc.nodeWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.Node)
+		n, _ := new.(*v1.Node)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribePods(handler func(old *v1.Pod, new *v1.Pod)) {
+	kcc.podWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.Pod)
+		n, _ := new.(*v1.Pod)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeConfigMaps(handler func(old *v1.ConfigMap, new *v1.ConfigMap)) {
+	kcc.kubecostConfigMapWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.ConfigMap)
+		n, _ := new.(*v1.ConfigMap)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeServices(handler func(old *v1.Service, new *v1.Service)) {
+	kcc.serviceWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.Service)
+		n, _ := new.(*v1.Service)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeDaemonSets(handler func(old *appsv1.DaemonSet, new *appsv1.DaemonSet)) {
+	kcc.daemonsetsWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*appsv1.DaemonSet)
+		n, _ := new.(*appsv1.DaemonSet)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeDeployments(handler func(old *appsv1.Deployment, new *appsv1.Deployment)) {
+	kcc.deploymentsWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*appsv1.Deployment)
+		n, _ := new.(*appsv1.Deployment)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeStatefulSets(handler func(old *appsv1.StatefulSet, new *appsv1.StatefulSet)) {
+	kcc.statefulsetWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*appsv1.StatefulSet)
+		n, _ := new.(*appsv1.StatefulSet)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeReplicaSets(handler func(old *appsv1.ReplicaSet, new *appsv1.ReplicaSet)) {
+	kcc.replicasetWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*appsv1.ReplicaSet)
+		n, _ := new.(*appsv1.ReplicaSet)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeJobs(handler func(old *batchv1.Job, new *batchv1.Job)) {
+	kcc.jobWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*batchv1.Job)
+		n, _ := new.(*batchv1.Job)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribePersistentVolumes(handler func(old *v1.PersistentVolume, new *v1.PersistentVolume)) {
+	kcc.pvWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.PersistentVolume)
+		n, _ := new.(*v1.PersistentVolume)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeStorageClasses(handler func(old *stv1.StorageClass, new *stv1.StorageClass)) {
+	kcc.storageClassWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*stv1.StorageClass)
+		n, _ := new.(*stv1.StorageClass)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribePersistentVolumeClaims(handler func(old *v1.PersistentVolumeClaim, new *v1.PersistentVolumeClaim)) {
+	kcc.pvcWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.PersistentVolumeClaim)
+		n, _ := new.(*v1.PersistentVolumeClaim)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribePodDisruptionBudgets(handler func(old *policyv1beta1.PodDisruptionBudget, new *policyv1beta1.PodDisruptionBudget)) {
+	kcc.pdbWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*policyv1beta1.PodDisruptionBudget)
+		n, _ := new.(*policyv1beta1.PodDisruptionBudget)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeCronJobs(handler func(old *batchv1beta1.CronJob, new *batchv1beta1.CronJob)) {
+	kcc.cronJobWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*batchv1beta1.CronJob)
+		n, _ := new.(*batchv1beta1.CronJob)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeHorizontalPodAutoscalers(handler func(old *autoscalingv1.HorizontalPodAutoscaler, new *autoscalingv1.HorizontalPodAutoscaler)) {
+	kcc.hpaWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*autoscalingv1.HorizontalPodAutoscaler)
+		n, _ := new.(*autoscalingv1.HorizontalPodAutoscaler)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribePriorityClasses(handler func(old *schedulingv1.PriorityClass, new *schedulingv1.PriorityClass)) {
+	kcc.priorityClassWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*schedulingv1.PriorityClass)
+		n, _ := new.(*schedulingv1.PriorityClass)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeLimitRanges(handler func(old *v1.LimitRange, new *v1.LimitRange)) {
+	kcc.limitRangeWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.LimitRange)
+		n, _ := new.(*v1.LimitRange)
+		handler(o, n)
+	})
+}
+
+func (kcc *KubernetesClusterCache) SubscribeResourceQuotas(handler func(old *v1.ResourceQuota, new *v1.ResourceQuota)) {
+	kcc.resourceQuotaWatch.Subscribe(func(old interface{}, new interface{}) {
+		o, _ := old.(*v1.ResourceQuota)
+		n, _ := new.(*v1.ResourceQuota)
+		handler(o, n)
+	})
+}
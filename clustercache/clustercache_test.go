@@ -0,0 +1,101 @@
+package clustercache_test
+
+import (
+	"testing"
+	"time"
+
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+)
+
+func cronJob(namespace string, name string) *batchv1beta1.CronJob {
+	return &batchv1beta1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func TestOptionalResources(t *testing.T) {
+	node := clustercachetest.Node("node-1", "m5.large", "2", "8Gi", nil)
+	pod := clustercachetest.Pod("default", "web-0", "node-1", "100m", "128Mi", nil)
+
+	t.Run("served", func(t *testing.T) {
+		h := clustercachetest.NewHarness(node, pod, cronJob("default", "backup"))
+		defer h.Stop()
+
+		if n := len(h.Cache.GetAllCronJobs()); n != 1 {
+			t.Errorf("expected the cronjob to be cached, got %d cronjobs", n)
+		}
+	})
+
+	t.Run("not served", func(t *testing.T) {
+		// the fake discovery serves none of the beta APIs
+		client := fake.NewSimpleClientset(node, pod, cronJob("default", "backup"))
+		cache := clustercache.NewKubernetesClusterCache(client)
+		cache.Run()
+		defer cache.Stop()
+
+		if n := len(cache.GetAllCronJobs()); n != 0 {
+			t.Errorf("expected no cronjobs when batch/v1beta1 isn't served, got %d", n)
+		}
+		if n := len(cache.GetAllPods()); n != 1 {
+			t.Errorf("expected the pod to be cached, got %d pods", n)
+		}
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		client := fake.NewSimpleClientset(node, pod)
+		client.PrependReactor("list", "horizontalpodautoscalers", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			gr := schema.GroupResource{Group: "autoscaling", Resource: "horizontalpodautoscalers"}
+			return true, nil, apierrors.NewForbidden(gr, "", nil)
+		})
+		client.PrependReactor("list", "limitranges", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "limitranges"}, "")
+		})
+
+		done := make(chan clustercache.ClusterCache)
+		go func() {
+			done <- clustercache.NewKubernetesClusterCache(client)
+		}()
+
+		select {
+		case cache := <-done:
+			cache.Run()
+			defer cache.Stop()
+			if n := len(cache.GetAllHorizontalPodAutoscalers()); n != 0 {
+				t.Errorf("expected no horizontal pod autoscalers, got %d", n)
+			}
+			if n := len(cache.GetAllNodes()); n != 1 {
+				t.Errorf("expected the node to be cached, got %d nodes", n)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("the warm-up waits for resources it can't list")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		// listing priority classes fails with an error retrying may fix
+		client := fake.NewSimpleClientset(node, pod)
+		client.PrependReactor("list", "priorityclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewServiceUnavailable("unavailable")
+		})
+
+		start := time.Now()
+		options := clustercache.DefaultOptions()
+		options.WarmUpTimeout = 200 * time.Millisecond
+		cache := clustercache.NewKubernetesClusterCacheWithOptions(client, options)
+		defer cache.Stop()
+
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the warm-up to give up after the timeout, took %s", elapsed)
+		}
+		if n := len(cache.GetAllPriorityClasses()); n != 0 {
+			t.Errorf("expected no priority classes, got %d", n)
+		}
+	})
+}
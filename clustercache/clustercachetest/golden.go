@@ -0,0 +1,68 @@
+package clustercachetest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mikeskali/PerfectScalePoc/env"
+)
+
+// UpdateGoldenEnvVar rewrites the golden files with the actual output instead of
+// comparing against them when set to true
+const UpdateGoldenEnvVar = "UPDATE_GOLDEN"
+
+// update rewrites the golden files like UPDATE_GOLDEN, when the tests using them
+// run with -update
+var update = flag.Bool("update", false, "rewrite the golden files with the actual output")
+
+// Golden compares the output written by write against the golden file at path.
+// When UPDATE_GOLDEN is true or the tests run with -update, the golden file is
+// rewritten instead. It returns
+// an error naming the first differing line.
+func Golden(path string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return fmt.Errorf("failed writing output: %s", err)
+	}
+	return CompareGolden(path, buf.Bytes())
+}
+
+// CompareGolden compares actual against the golden file at path, or rewrites the
+// golden file when UPDATE_GOLDEN is true or the tests run with -update
+func CompareGolden(path string, actual []byte) error {
+	if *update || env.GetBool(UpdateGoldenEnvVar, false) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, actual, 0644)
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading golden file, run the tests with -update to create it: %s", err)
+	}
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+
+	expectedLines := bytes.Split(expected, []byte("\n"))
+	actualLines := bytes.Split(actual, []byte("\n"))
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		var e, a []byte
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if !bytes.Equal(e, a) {
+			return fmt.Errorf("%s differs at line %d:\nexpected: %s\nactual:   %s", path, i+1, e, a)
+		}
+	}
+	return fmt.Errorf("%s differs", path)
+}
@@ -0,0 +1,244 @@
+package clustercachetest
+
+import (
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+)
+
+// Harness is a KubernetesClusterCache over a fake clientset, for exercising
+// everything built on the cluster cache without a cluster. Objects added,
+// updated and deleted through the harness reach the cache through its watchers,
+// as they would in a cluster.
+type Harness struct {
+	Client *fake.Clientset
+	Cache  clustercache.ClusterCache
+}
+
+// NewHarness creates a fake clientset holding the objects and a running cluster
+// cache over it, warmed up with the objects
+func NewHarness(objects ...runtime.Object) *Harness {
+	client := fake.NewSimpleClientset(objects...)
+	// the cache only watches the beta APIs the cluster serves
+	client.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "batch/v1beta1", APIResources: []metav1.APIResource{{Name: "cronjobs", Namespaced: true, Kind: "CronJob"}}},
+		{GroupVersion: "policy/v1beta1", APIResources: []metav1.APIResource{{Name: "poddisruptionbudgets", Namespaced: true, Kind: "PodDisruptionBudget"}}},
+	}
+	cache := clustercache.NewKubernetesClusterCache(client)
+	cache.Run()
+	return &Harness{Client: client, Cache: cache}
+}
+
+// Stop stops the watchers of the cache
+func (h *Harness) Stop() {
+	h.Cache.Stop()
+}
+
+// Add creates the object in the fake clientset
+func (h *Harness) Add(obj runtime.Object) error {
+	return h.Client.Tracker().Add(obj)
+}
+
+// Update replaces the object in the fake clientset
+func (h *Harness) Update(obj runtime.Object) error {
+	gvr, meta, err := resourceOf(obj)
+	if err != nil {
+		return err
+	}
+	return h.Client.Tracker().Update(gvr, obj, meta.GetNamespace())
+}
+
+// Delete deletes the object from the fake clientset
+func (h *Harness) Delete(obj runtime.Object) error {
+	gvr, meta, err := resourceOf(obj)
+	if err != nil {
+		return err
+	}
+	return h.Client.Tracker().Delete(gvr, meta.GetNamespace(), meta.GetName())
+}
+
+// WaitFor polls the condition until it holds, typically until a change made
+// through the harness reached the cache, failing after the timeout
+func (h *Harness) WaitFor(condition func() bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("condition not met after %s", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// resourceOf returns the resource of the object, for the object types the
+// builders create
+func resourceOf(obj runtime.Object) (schema.GroupVersionResource, metav1.Object, error) {
+	var group schema.GroupVersion
+	var name string
+	switch obj.(type) {
+	case *v1.Node:
+		group, name = v1.SchemeGroupVersion, "nodes"
+	case *v1.Pod:
+		group, name = v1.SchemeGroupVersion, "pods"
+	case *appsv1.Deployment:
+		group, name = appsv1.SchemeGroupVersion, "deployments"
+	case *appsv1.ReplicaSet:
+		group, name = appsv1.SchemeGroupVersion, "replicasets"
+	case *appsv1.StatefulSet:
+		group, name = appsv1.SchemeGroupVersion, "statefulsets"
+	case *appsv1.DaemonSet:
+		group, name = appsv1.SchemeGroupVersion, "daemonsets"
+	case *batchv1.Job:
+		group, name = batchv1.SchemeGroupVersion, "jobs"
+	default:
+		return schema.GroupVersionResource{}, nil, fmt.Errorf("unsupported object type %T", obj)
+	}
+	return group.WithResource(name), obj.(metav1.Object), nil
+}
+
+// Node builds a node of the instance type with the capacity, all of it
+// allocatable, and the extra labels
+func Node(name string, instanceType string, cpu string, memory string, labels map[string]string) *v1.Node {
+	nodeLabels := map[string]string{
+		"kubernetes.io/hostname":           name,
+		"node.kubernetes.io/instance-type": instanceType,
+	}
+	for k, v := range labels {
+		nodeLabels[k] = v
+	}
+	capacity := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			UID:    uid("", name),
+			Labels: nodeLabels,
+		},
+		Status: v1.NodeStatus{
+			Capacity:    capacity,
+			Allocatable: capacity,
+		},
+	}
+}
+
+// Pod builds a running pod scheduled on the node, with a single container
+// requesting the cpu and memory, and owned by the owner unless it is nil
+func Pod(namespace string, name string, node string, cpu string, memory string, owner *metav1.OwnerReference) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       uid(namespace, name),
+		},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Name: "main",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse(cpu),
+						v1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if owner != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return pod
+}
+
+// Deployment builds a deployment of the replicas
+func Deployment(namespace string, name string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       uid(namespace, name),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: selector(name),
+		},
+	}
+}
+
+// ReplicaSet builds a ReplicaSet owned by the owner unless it is nil
+func ReplicaSet(namespace string, name string, owner *metav1.OwnerReference) *appsv1.ReplicaSet {
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       uid(namespace, name),
+		},
+	}
+	if owner != nil {
+		rs.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return rs
+}
+
+// StatefulSet builds a StatefulSet of the replicas
+func StatefulSet(namespace string, name string, replicas int32) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       uid(namespace, name),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: selector(name),
+		},
+	}
+}
+
+// DaemonSet builds a DaemonSet
+func DaemonSet(namespace string, name string) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			UID:       uid(namespace, name),
+		},
+		Spec: appsv1.DaemonSetSpec{Selector: selector(name)},
+	}
+}
+
+// OwnerReference returns a controller reference to the object of the kind
+func OwnerReference(kind string, obj metav1.Object) *metav1.OwnerReference {
+	controller := true
+	return &metav1.OwnerReference{
+		Kind:       kind,
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+		Controller: &controller,
+	}
+}
+
+// selector returns the app=name label selector of a workload, as workloads
+// always have a selector in a cluster
+func selector(name string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}}
+}
+
+// uid derives the uid of an object from its namespace and name, so the objects
+// built by the harness, and the outputs derived from them, are deterministic
+func uid(namespace string, name string) types.UID {
+	return types.UID(namespace + "/" + name)
+}
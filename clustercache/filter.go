@@ -0,0 +1,308 @@
+package clustercache
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// namespaceFilter is a ClusterCache restricted to the namespaced resources of a
+// set of namespaces. Cluster scoped resources, such as nodes, are all kept.
+type namespaceFilter struct {
+	ClusterCache
+	namespaces map[string]bool
+}
+
+// NewNamespaceFilter restricts the namespaced resources of the cache to the
+// namespaces. The cache is returned as is when there are no namespaces.
+func NewNamespaceFilter(cache ClusterCache, namespaces []string) ClusterCache {
+	if len(namespaces) == 0 {
+		return cache
+	}
+	f := &namespaceFilter{ClusterCache: cache, namespaces: make(map[string]bool, len(namespaces))}
+	for _, ns := range namespaces {
+		f.namespaces[ns] = true
+	}
+	return f
+}
+
+func (f *namespaceFilter) GetAllNamespaces() []*v1.Namespace {
+	var namespaces []*v1.Namespace
+	for _, ns := range f.ClusterCache.GetAllNamespaces() {
+		if f.namespaces[ns.Name] {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+func (f *namespaceFilter) GetPodsOnNode(nodeName string) []*v1.Pod {
+	return f.pods(f.ClusterCache.GetPodsOnNode(nodeName))
+}
+
+func (f *namespaceFilter) GetPodsInNamespace(namespace string) []*v1.Pod {
+	if !f.namespaces[namespace] {
+		return nil
+	}
+	return f.ClusterCache.GetPodsInNamespace(namespace)
+}
+
+func (f *namespaceFilter) GetPodsForOwner(uid types.UID) []*v1.Pod {
+	return f.pods(f.ClusterCache.GetPodsForOwner(uid))
+}
+
+func (f *namespaceFilter) pods(pods []*v1.Pod) []*v1.Pod {
+	var filtered []*v1.Pod
+	for _, pod := range pods {
+		if f.namespaces[pod.Namespace] {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
+
+func (f *namespaceFilter) SubscribeNamespaces(handler func(old *v1.Namespace, new *v1.Namespace)) {
+	f.ClusterCache.SubscribeNamespaces(func(old *v1.Namespace, new *v1.Namespace) {
+		if (old != nil && f.namespaces[old.Name]) || (new != nil && f.namespaces[new.Name]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllPods() []*v1.Pod {
+	var pods []*v1.Pod
+	for _, obj := range f.ClusterCache.GetAllPods() {
+		if f.namespaces[obj.Namespace] {
+			pods = append(pods, obj)
+		}
+	}
+	return pods
+}
+
+func (f *namespaceFilter) SubscribePods(handler func(old *v1.Pod, new *v1.Pod)) {
+	f.ClusterCache.SubscribePods(func(old *v1.Pod, new *v1.Pod) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllServices() []*v1.Service {
+	var services []*v1.Service
+	for _, obj := range f.ClusterCache.GetAllServices() {
+		if f.namespaces[obj.Namespace] {
+			services = append(services, obj)
+		}
+	}
+	return services
+}
+
+func (f *namespaceFilter) SubscribeServices(handler func(old *v1.Service, new *v1.Service)) {
+	f.ClusterCache.SubscribeServices(func(old *v1.Service, new *v1.Service) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllDaemonSets() []*appsv1.DaemonSet {
+	var daemonSets []*appsv1.DaemonSet
+	for _, obj := range f.ClusterCache.GetAllDaemonSets() {
+		if f.namespaces[obj.Namespace] {
+			daemonSets = append(daemonSets, obj)
+		}
+	}
+	return daemonSets
+}
+
+func (f *namespaceFilter) SubscribeDaemonSets(handler func(old *appsv1.DaemonSet, new *appsv1.DaemonSet)) {
+	f.ClusterCache.SubscribeDaemonSets(func(old *appsv1.DaemonSet, new *appsv1.DaemonSet) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllDeployments() []*appsv1.Deployment {
+	var deployments []*appsv1.Deployment
+	for _, obj := range f.ClusterCache.GetAllDeployments() {
+		if f.namespaces[obj.Namespace] {
+			deployments = append(deployments, obj)
+		}
+	}
+	return deployments
+}
+
+func (f *namespaceFilter) SubscribeDeployments(handler func(old *appsv1.Deployment, new *appsv1.Deployment)) {
+	f.ClusterCache.SubscribeDeployments(func(old *appsv1.Deployment, new *appsv1.Deployment) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllStatefulSets() []*appsv1.StatefulSet {
+	var statefulSets []*appsv1.StatefulSet
+	for _, obj := range f.ClusterCache.GetAllStatefulSets() {
+		if f.namespaces[obj.Namespace] {
+			statefulSets = append(statefulSets, obj)
+		}
+	}
+	return statefulSets
+}
+
+func (f *namespaceFilter) SubscribeStatefulSets(handler func(old *appsv1.StatefulSet, new *appsv1.StatefulSet)) {
+	f.ClusterCache.SubscribeStatefulSets(func(old *appsv1.StatefulSet, new *appsv1.StatefulSet) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllReplicaSets() []*appsv1.ReplicaSet {
+	var replicaSets []*appsv1.ReplicaSet
+	for _, obj := range f.ClusterCache.GetAllReplicaSets() {
+		if f.namespaces[obj.Namespace] {
+			replicaSets = append(replicaSets, obj)
+		}
+	}
+	return replicaSets
+}
+
+func (f *namespaceFilter) SubscribeReplicaSets(handler func(old *appsv1.ReplicaSet, new *appsv1.ReplicaSet)) {
+	f.ClusterCache.SubscribeReplicaSets(func(old *appsv1.ReplicaSet, new *appsv1.ReplicaSet) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllJobs() []*batchv1.Job {
+	var jobs []*batchv1.Job
+	for _, obj := range f.ClusterCache.GetAllJobs() {
+		if f.namespaces[obj.Namespace] {
+			jobs = append(jobs, obj)
+		}
+	}
+	return jobs
+}
+
+func (f *namespaceFilter) SubscribeJobs(handler func(old *batchv1.Job, new *batchv1.Job)) {
+	f.ClusterCache.SubscribeJobs(func(old *batchv1.Job, new *batchv1.Job) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllCronJobs() []*batchv1beta1.CronJob {
+	var cronJobs []*batchv1beta1.CronJob
+	for _, obj := range f.ClusterCache.GetAllCronJobs() {
+		if f.namespaces[obj.Namespace] {
+			cronJobs = append(cronJobs, obj)
+		}
+	}
+	return cronJobs
+}
+
+func (f *namespaceFilter) SubscribeCronJobs(handler func(old *batchv1beta1.CronJob, new *batchv1beta1.CronJob)) {
+	f.ClusterCache.SubscribeCronJobs(func(old *batchv1beta1.CronJob, new *batchv1beta1.CronJob) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllPersistentVolumeClaims() []*v1.PersistentVolumeClaim {
+	var pvcs []*v1.PersistentVolumeClaim
+	for _, obj := range f.ClusterCache.GetAllPersistentVolumeClaims() {
+		if f.namespaces[obj.Namespace] {
+			pvcs = append(pvcs, obj)
+		}
+	}
+	return pvcs
+}
+
+func (f *namespaceFilter) SubscribePersistentVolumeClaims(handler func(old *v1.PersistentVolumeClaim, new *v1.PersistentVolumeClaim)) {
+	f.ClusterCache.SubscribePersistentVolumeClaims(func(old *v1.PersistentVolumeClaim, new *v1.PersistentVolumeClaim) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllPodDisruptionBudgets() []*policyv1beta1.PodDisruptionBudget {
+	var pdbs []*policyv1beta1.PodDisruptionBudget
+	for _, obj := range f.ClusterCache.GetAllPodDisruptionBudgets() {
+		if f.namespaces[obj.Namespace] {
+			pdbs = append(pdbs, obj)
+		}
+	}
+	return pdbs
+}
+
+func (f *namespaceFilter) SubscribePodDisruptionBudgets(handler func(old *policyv1beta1.PodDisruptionBudget, new *policyv1beta1.PodDisruptionBudget)) {
+	f.ClusterCache.SubscribePodDisruptionBudgets(func(old *policyv1beta1.PodDisruptionBudget, new *policyv1beta1.PodDisruptionBudget) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllHorizontalPodAutoscalers() []*autoscalingv1.HorizontalPodAutoscaler {
+	var hpas []*autoscalingv1.HorizontalPodAutoscaler
+	for _, obj := range f.ClusterCache.GetAllHorizontalPodAutoscalers() {
+		if f.namespaces[obj.Namespace] {
+			hpas = append(hpas, obj)
+		}
+	}
+	return hpas
+}
+
+func (f *namespaceFilter) SubscribeHorizontalPodAutoscalers(handler func(old *autoscalingv1.HorizontalPodAutoscaler, new *autoscalingv1.HorizontalPodAutoscaler)) {
+	f.ClusterCache.SubscribeHorizontalPodAutoscalers(func(old *autoscalingv1.HorizontalPodAutoscaler, new *autoscalingv1.HorizontalPodAutoscaler) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllLimitRanges() []*v1.LimitRange {
+	var limitRanges []*v1.LimitRange
+	for _, obj := range f.ClusterCache.GetAllLimitRanges() {
+		if f.namespaces[obj.Namespace] {
+			limitRanges = append(limitRanges, obj)
+		}
+	}
+	return limitRanges
+}
+
+func (f *namespaceFilter) SubscribeLimitRanges(handler func(old *v1.LimitRange, new *v1.LimitRange)) {
+	f.ClusterCache.SubscribeLimitRanges(func(old *v1.LimitRange, new *v1.LimitRange) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
+
+func (f *namespaceFilter) GetAllResourceQuotas() []*v1.ResourceQuota {
+	var quotas []*v1.ResourceQuota
+	for _, obj := range f.ClusterCache.GetAllResourceQuotas() {
+		if f.namespaces[obj.Namespace] {
+			quotas = append(quotas, obj)
+		}
+	}
+	return quotas
+}
+
+func (f *namespaceFilter) SubscribeResourceQuotas(handler func(old *v1.ResourceQuota, new *v1.ResourceQuota)) {
+	f.ClusterCache.SubscribeResourceQuotas(func(old *v1.ResourceQuota, new *v1.ResourceQuota) {
+		if (old != nil && f.namespaces[old.Namespace]) || (new != nil && f.namespaces[new.Namespace]) {
+			handler(old, new)
+		}
+	})
+}
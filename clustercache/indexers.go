@@ -0,0 +1,69 @@
+package clustercache
+
+import (
+	"github.com/mikeskali/PerfectScalePoc/util"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// NamespaceIndex indexes resources by their namespace
+	NamespaceIndex = cache.NamespaceIndex
+
+	// PodNodeIndex indexes pods by the name of the node they are scheduled on
+	PodNodeIndex = "node"
+
+	// PodOwnerIndex indexes pods by the uid of each of their owners
+	PodOwnerIndex = "owner"
+
+	// NodeInstanceTypeIndex indexes nodes by their instance type label
+	NodeInstanceTypeIndex = "instanceType"
+)
+
+// podIndexers are the indexers of the pod watcher
+var podIndexers = cache.Indexers{
+	NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	PodNodeIndex:   podNodeIndexFunc,
+	PodOwnerIndex:  podOwnerIndexFunc,
+}
+
+// nodeIndexers are the indexers of the node watcher
+var nodeIndexers = cache.Indexers{
+	NodeInstanceTypeIndex: nodeInstanceTypeIndexFunc,
+}
+
+// podNodeIndexFunc indexes scheduled pods by their node name
+func podNodeIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return []string{}, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+// podOwnerIndexFunc indexes pods by the uids of their owner references
+func podOwnerIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return []string{}, nil
+	}
+	uids := make([]string, 0, len(pod.OwnerReferences))
+	for _, owner := range pod.OwnerReferences {
+		uids = append(uids, string(owner.UID))
+	}
+	return uids, nil
+}
+
+// nodeInstanceTypeIndexFunc indexes nodes by their instance type, leaving out
+// nodes without an instance type label
+func nodeInstanceTypeIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return []string{}, nil
+	}
+	if instanceType, ok := util.GetInstanceType(node.Labels); ok {
+		return []string{instanceType}, nil
+	}
+	return []string{}, nil
+}
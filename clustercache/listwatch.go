@@ -0,0 +1,243 @@
+package clustercache
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	rt "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The list watchers go through the typed clients rather than the REST clients of
+// the clientset, so the cache also runs over clientsets without REST clients,
+// such as the fake clientset.
+
+// namespaceListWatch lists and watches namespaces
+func namespaceListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().Namespaces().List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Namespaces().Watch(context.TODO(), options)
+		},
+	}
+}
+
+// nodeListWatch lists and watches nodes
+func nodeListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().Nodes().List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Nodes().Watch(context.TODO(), options)
+		},
+	}
+}
+
+// podListWatch lists and watches pods
+func podListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().Pods("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Pods("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// configMapListWatch lists and watches configmaps
+func configMapListWatch(client kubernetes.Interface, namespace string) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().ConfigMaps(namespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ConfigMaps(namespace).Watch(context.TODO(), options)
+		},
+	}
+}
+
+// serviceListWatch lists and watches services
+func serviceListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().Services("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().Services("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// daemonSetListWatch lists and watches daemonsets
+func daemonSetListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.AppsV1().DaemonSets("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().DaemonSets("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// deploymentListWatch lists and watches deployments
+func deploymentListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.AppsV1().Deployments("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().Deployments("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// statefulSetListWatch lists and watches statefulsets
+func statefulSetListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.AppsV1().StatefulSets("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().StatefulSets("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// replicaSetListWatch lists and watches replicasets
+func replicaSetListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.AppsV1().ReplicaSets("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.AppsV1().ReplicaSets("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// jobListWatch lists and watches jobs
+func jobListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.BatchV1().Jobs("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.BatchV1().Jobs("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// cronJobListWatch lists and watches cronjobs
+func cronJobListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.BatchV1beta1().CronJobs("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.BatchV1beta1().CronJobs("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// pvListWatch lists and watches persistentvolumes
+func pvListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().PersistentVolumes().List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().PersistentVolumes().Watch(context.TODO(), options)
+		},
+	}
+}
+
+// pvcListWatch lists and watches persistentvolumeclaims
+func pvcListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().PersistentVolumeClaims("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().PersistentVolumeClaims("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// storageClassListWatch lists and watches storageclasses
+func storageClassListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.StorageV1().StorageClasses().List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.StorageV1().StorageClasses().Watch(context.TODO(), options)
+		},
+	}
+}
+
+// pdbListWatch lists and watches poddisruptionbudgets
+func pdbListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.PolicyV1beta1().PodDisruptionBudgets("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.PolicyV1beta1().PodDisruptionBudgets("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// hpaListWatch lists and watches horizontalpodautoscalers
+func hpaListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.AutoscalingV1().HorizontalPodAutoscalers("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.AutoscalingV1().HorizontalPodAutoscalers("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// priorityClassListWatch lists and watches priorityclasses
+func priorityClassListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.SchedulingV1().PriorityClasses().List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.SchedulingV1().PriorityClasses().Watch(context.TODO(), options)
+		},
+	}
+}
+
+// limitRangeListWatch lists and watches limitranges
+func limitRangeListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().LimitRanges("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().LimitRanges("").Watch(context.TODO(), options)
+		},
+	}
+}
+
+// resourceQuotaListWatch lists and watches resourcequotas
+func resourceQuotaListWatch(client kubernetes.Interface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (rt.Object, error) {
+			return client.CoreV1().ResourceQuotas("").List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ResourceQuotas("").Watch(context.TODO(), options)
+		},
+	}
+}
@@ -3,15 +3,14 @@ package clustercache
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"k8s.io/klog"
 
-	"k8s.io/apimachinery/pkg/fields"
 	rt "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -19,6 +18,10 @@ import (
 // Type alias for a receiver func
 type WatchHandler = func(interface{})
 
+// EventHandler receives the changes of a watched resource. old is nil for added
+// resources and new is nil for deleted resources.
+type EventHandler = func(old interface{}, new interface{})
+
 // WatchController defines a contract for an object which watches a specific resource set for
 // add, updates, and removals
 type WatchController interface {
@@ -31,11 +34,25 @@ type WatchController interface {
 	// GetAll returns all of the resources
 	GetAll() []interface{}
 
+	// GetByIndex returns the resources whose indexName index contains value
+	GetByIndex(indexName string, value string) []interface{}
+
+	// SetShared sets whether the resources returned and delivered to subscribers
+	// are the cached objects themselves rather than copies. Shared resources must
+	// be treated as read-only.
+	SetShared(bool) WatchController
+
 	// SetUpdateHandler sets a specific handler for adding/updating individual resources
 	SetUpdateHandler(WatchHandler) WatchController
 
 	// SetRemovedHandler sets a specific handler for removing individual resources
 	SetRemovedHandler(WatchHandler) WatchController
+
+	// Subscribe adds a handler receiving the previous and current state of every
+	// added, updated and deleted resource. The resources already cached are
+	// delivered as added first. Handlers are called in order by the watcher and
+	// must not block.
+	Subscribe(EventHandler)
 }
 
 // CachingWatchController composites the watching behavior and a cache to ensure that all
@@ -48,25 +65,41 @@ type CachingWatchController struct {
 	resource     string
 	resourceType string
 
+	// shared skips deep copying the cached resources
+	shared bool
+
 	updateHandler WatchHandler
 	removeHandler WatchHandler
+
+	subscribersLock sync.RWMutex
+	subscribers     []EventHandler
 }
 
-func NewCachingWatcher(restClient rest.Interface, resource string, resourceType rt.Object, namespace string, fieldSelector fields.Selector) WatchController {
-	resourceCache := cache.NewListWatchFromClient(restClient, resource, namespace, fieldSelector)
+// NewCachingWatcher creates a watcher of the resources listed and watched by the
+// list watcher, maintaining the indexers along with the cache
+func NewCachingWatcher(resourceCache cache.ListerWatcher, resource string, resourceType rt.Object, indexers cache.Indexers) WatchController {
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	indexer, informer := cache.NewIndexerInformer(resourceCache, resourceType, 0, cache.ResourceEventHandlerFuncs{
+
+	c := &CachingWatchController{
+		queue:        queue,
+		resource:     resource,
+		resourceType: reflect.TypeOf(resourceType).String(),
+	}
+
+	c.indexer, c.informer = cache.NewIndexerInformer(resourceCache, resourceType, 0, cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(obj)
 			if err == nil {
 				queue.Add(key)
 			}
+			c.notify(nil, obj)
 		},
 		UpdateFunc: func(old interface{}, new interface{}) {
 			key, err := cache.MetaNamespaceKeyFunc(new)
 			if err == nil {
 				queue.Add(key)
 			}
+			c.notify(old, new)
 		},
 		DeleteFunc: func(obj interface{}) {
 			// IndexerInformer uses a delta queue, therefore for deletes we have to use this
@@ -75,20 +108,35 @@ func NewCachingWatcher(restClient rest.Interface, resource string, resourceType
 			if err == nil {
 				queue.Add(key)
 			}
+			// the last known state of resources deleted while the watch was down
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			c.notify(obj, nil)
 		},
-	}, cache.Indexers{})
+	}, indexers)
 
-	return &CachingWatchController{
-		indexer:      indexer,
-		queue:        queue,
-		informer:     informer,
-		resource:     resource,
-		resourceType: reflect.TypeOf(resourceType).String(),
-	}
+	return c
 }
 
 func (c *CachingWatchController) GetAll() []interface{} {
-	list := c.indexer.List()
+	return c.copyList(c.indexer.List())
+}
+
+func (c *CachingWatchController) GetByIndex(indexName string, value string) []interface{} {
+	list, err := c.indexer.ByIndex(indexName, value)
+	if err != nil {
+		klog.Errorf("Fetching %s by index %s failed with %v", c.resourceType, indexName, err)
+		return nil
+	}
+	return c.copyList(list)
+}
+
+// copyList returns the resources of the list, copied unless they are shared
+func (c *CachingWatchController) copyList(list []interface{}) []interface{} {
+	if c.shared {
+		return list
+	}
 
 	// since the indexer returns the as-is pointer to the resource,
 	// we deep copy the resources such that callers don't corrupt the
@@ -103,6 +151,11 @@ func (c *CachingWatchController) GetAll() []interface{} {
 	return cloneList
 }
 
+func (c *CachingWatchController) SetShared(shared bool) WatchController {
+	c.shared = shared
+	return c
+}
+
 func (c *CachingWatchController) SetUpdateHandler(handler WatchHandler) WatchController {
 	c.updateHandler = handler
 	return c
@@ -113,6 +166,40 @@ func (c *CachingWatchController) SetRemovedHandler(handler WatchHandler) WatchCo
 	return c
 }
 
+func (c *CachingWatchController) Subscribe(handler EventHandler) {
+	c.subscribersLock.Lock()
+	defer c.subscribersLock.Unlock()
+
+	for _, obj := range c.GetAll() {
+		handler(nil, obj)
+	}
+	c.subscribers = append(c.subscribers, handler)
+}
+
+// notify delivers a copy of the change, unless resources are shared, to every
+// subscriber, such that subscribers don't corrupt the index
+func (c *CachingWatchController) notify(old interface{}, new interface{}) {
+	c.subscribersLock.RLock()
+	defer c.subscribersLock.RUnlock()
+
+	if len(c.subscribers) == 0 {
+		return
+	}
+	if !c.shared {
+		old, new = deepCopy(old), deepCopy(new)
+	}
+	for _, handler := range c.subscribers {
+		handler(old, new)
+	}
+}
+
+func deepCopy(obj interface{}) interface{} {
+	if deepCopyable, ok := obj.(rt.Object); ok {
+		return deepCopyable.DeepCopyObject()
+	}
+	return nil
+}
+
 func (c *CachingWatchController) processNextItem() bool {
 	// Wait until there is a new item in the working queue
 	key, quit := c.queue.Get()
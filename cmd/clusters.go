@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+	"github.com/mikeskali/PerfectScalePoc/snapshot"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Cluster is a cluster the commands run against
+type Cluster struct {
+	// ID identifies the cluster in the exported rows
+	ID    string
+	Cache clustercache.ClusterCache
+}
+
+// forEachCluster runs the command on every cluster, restricted to the
+// namespaces, and then writes the tables of all clusters. The sink is created
+// first, so invalid output options fail before the clusters are collected.
+func (c *Context) forEachCluster(run func(cluster *Cluster) error) error {
+	if err := c.startHashing(); err != nil {
+		return err
+	}
+	if _, err := c.sink(); err != nil {
+		return err
+	}
+	clusters, err := c.clusters()
+	if err != nil {
+		return err
+	}
+
+	for _, cluster := range clusters {
+		if len(clusters) > 1 {
+			fmt.Println("===== Cluster: " + c.anonymizer.Value(cluster.ID) + " ======")
+		}
+		if err := run(cluster); err != nil {
+			return fmt.Errorf("cluster %s: %s", cluster.ID, err)
+		}
+	}
+	return c.finish()
+}
+
+// clusters returns the clusters restricted to the namespaces, collecting them
+// from the snapshot archives or the kubeconfig contexts on first use
+func (c *Context) clusters() ([]*Cluster, error) {
+	if c.Clusters == nil {
+		var clusters []*Cluster
+		var err error
+		if len(c.Snapshots) > 0 {
+			clusters, err = c.loadSnapshots()
+		} else {
+			clusters, err = c.connect()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make(map[string]bool)
+		for _, cluster := range clusters {
+			if ids[cluster.ID] {
+				return nil, fmt.Errorf("several clusters have the id %q", cluster.ID)
+			}
+			ids[cluster.ID] = true
+		}
+		c.Clusters = clusters
+	}
+
+	filtered := make([]*Cluster, 0, len(c.Clusters))
+	for _, cluster := range c.Clusters {
+		filtered = append(filtered, &Cluster{
+			ID:    cluster.ID,
+			Cache: clustercache.NewNamespaceFilter(cluster.Cache, c.Namespaces),
+		})
+	}
+	return filtered, nil
+}
+
+// collect runs load for every target concurrently and returns the clusters
+// loaded in the order of the targets. Targets which fail, or aren't loaded
+// within the timeout when it is positive, are logged and skipped, collect fails
+// only when all of them do. The caches of targets loaded after their timeout are
+// stopped.
+func collect(targets []string, timeout time.Duration, load func(target string) (*Cluster, error)) ([]*Cluster, error) {
+	loaded := make([]*Cluster, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for i, target := range targets {
+		go func(i int, target string) {
+			defer wg.Done()
+			if timeout <= 0 {
+				loaded[i], errs[i] = load(target)
+				return
+			}
+
+			var cluster *Cluster
+			var err error
+			done := make(chan struct{})
+			go func() {
+				cluster, err = load(target)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				loaded[i], errs[i] = cluster, err
+			case <-time.After(timeout):
+				errs[i] = fmt.Errorf("timed out after %s", timeout)
+				go func() {
+					<-done
+					if cluster != nil {
+						cluster.Cache.Stop()
+					}
+				}()
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	var clusters []*Cluster
+	for i, cluster := range loaded {
+		if errs[i] != nil {
+			log.Printf("Failed collecting %s: %s", targets[i], errs[i])
+			continue
+		}
+		clusters = append(clusters, cluster)
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("failed collecting %s: %s", strings.Join(targets, ", "), errs[0])
+	}
+	return clusters, nil
+}
+
+// loadSnapshots loads the cluster of every snapshot archive. Clusters are
+// identified by the id recorded in their snapshot, falling back to ClusterID for
+// a single archive and to the archive name otherwise.
+func (c *Context) loadSnapshots() ([]*Cluster, error) {
+	return collect(c.Snapshots, c.LoadTimeout, func(path string) (*Cluster, error) {
+		snapshotCache, err := snapshot.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading snapshot %s: %s", path, err)
+		}
+		s := snapshotCache.Snapshot()
+		log.Printf("Loaded snapshot %s captured at %s", path, s.CapturedAt)
+
+		id := s.ClusterID
+		if id == "" && len(c.Snapshots) == 1 {
+			id = c.ClusterID
+		}
+		if id == "" {
+			id = strings.TrimSuffix(filepath.Base(path), ".json.gz")
+		}
+		return &Cluster{ID: id, Cache: snapshotCache}, nil
+	})
+}
+
+// connect creates and runs the cache of the cluster of every selected kubeconfig
+// context, or of the cluster the process runs in when no kubeconfig is set.
+// Clusters are identified by their context when contexts are selected, and by
+// ClusterID, falling back to the current context, otherwise.
+func (c *Context) connect() ([]*Cluster, error) {
+	if c.Kubeconfig == "" {
+		if len(c.KubeContexts) > 0 || c.AllContexts {
+			return nil, fmt.Errorf("kubeconfig contexts are selected but no kubeconfig is set")
+		}
+		kc, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("no kubeconfig set and not running in a cluster: %s", err)
+		}
+		k8sCache, err := newKubernetesCache(kc)
+		if err != nil {
+			return nil, err
+		}
+		return []*Cluster{{ID: c.ClusterID, Cache: k8sCache}}, nil
+	}
+
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: c.Kubeconfig}
+	raw, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed loading kubeconfig %s: %s", c.Kubeconfig, err)
+	}
+
+	contexts := c.KubeContexts
+	if c.AllContexts {
+		contexts = nil
+		for name := range raw.Contexts {
+			contexts = append(contexts, name)
+		}
+		sort.Strings(contexts)
+	}
+	selected := len(contexts) > 0
+	if !selected {
+		contexts = []string{raw.CurrentContext}
+	}
+
+	return collect(contexts, c.LoadTimeout, func(context string) (*Cluster, error) {
+		kc, err := clientcmd.NewNonInteractiveClientConfig(raw, context, &clientcmd.ConfigOverrides{}, rules).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed loading context %q of kubeconfig %s: %s", context, c.Kubeconfig, err)
+		}
+		k8sCache, err := newKubernetesCache(kc)
+		if err != nil {
+			return nil, err
+		}
+
+		id := context
+		if !selected && c.ClusterID != "" {
+			id = c.ClusterID
+		}
+		log.Printf("Collected cluster %s of context %q", id, context)
+		return &Cluster{ID: id, Cache: k8sCache}, nil
+	})
+}
+
+// newKubernetesCache creates and runs the cache of the cluster of the config,
+// returning once the cache is warmed up. The API server is checked first, as the
+// warm-up waits for unreachable clusters forever.
+func newKubernetesCache(kc *rest.Config) (clustercache.ClusterCache, error) {
+	kubeClientset, err := kubernetes.NewForConfig(kc)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := kubeClientset.Discovery().ServerVersion(); err != nil {
+		return nil, fmt.Errorf("failed reaching %s: %s", kc.Host, err)
+	}
+
+	// Create Kubernetes Cluster Cache + Watchers
+	k8sCache := clustercache.NewKubernetesClusterCache(kubeClientset)
+	k8sCache.Run()
+	return k8sCache, nil
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/snapshot"
+)
+
+func TestCollect(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	load := func(target string) (*Cluster, error) {
+		switch target {
+		case "hanging":
+			<-release
+		case "failing":
+			return nil, fmt.Errorf("unreachable")
+		}
+		return &Cluster{ID: target, Cache: &snapshot.Cache{}}, nil
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		clusters, err := collect([]string{"a", "hanging", "failing", "b"}, 100*time.Millisecond, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected collect to return after the timeout, took %s", elapsed)
+		}
+		if len(clusters) != 2 || clusters[0].ID != "a" || clusters[1].ID != "b" {
+			t.Errorf("expected the clusters a and b, got %v", clusters)
+		}
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		_, err := collect([]string{"hanging", "failing"}, 100*time.Millisecond, load)
+		if err == nil {
+			t.Error("expected an error when no cluster is loaded")
+		}
+	})
+}
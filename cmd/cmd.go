@@ -0,0 +1,349 @@
+// Package cmd implements the commands of the PerfectScalePoc command line. Every
+// command is a function of a Context and the command arguments, so commands can
+// run against a harness or snapshot cache and an in memory sink.
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/anonymize"
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/output"
+)
+
+// Command is a command of the command line
+type Command struct {
+	Name        string
+	Description string
+	Run         func(c *Context, args []string) error
+}
+
+// Commands returns the commands of the command line
+func Commands() []*Command {
+	return []*Command{
+		{Name: "export", Description: "export the node groups, nodes, workloads and pods of the cluster", Run: Export},
+		{Name: "cost", Description: "price the nodes and allocate their cost to namespaces and workloads", Run: Cost},
+		{Name: "optimize", Description: "recommend the cheapest instance types per node group", Run: Optimize},
+		{Name: "rightsize", Description: "recommend container requests and limits from their usage", Run: Rightsize},
+		{Name: "serve", Description: "serve the cluster over HTTP and publish gauges on /metrics", Run: Serve},
+		{Name: "snapshot", Description: "capture the cluster to a snapshot archive", Run: Snapshot},
+		{Name: "diff", Description: "list the resources changed between two snapshot archives", Run: Diff},
+		{Name: "deanonymize", Description: "replace the hashes of exported files with their values", Run: Deanonymize},
+	}
+}
+
+// Main runs the command named by the first argument with the remaining ones,
+// export when there are no arguments or the first one is a flag, and returns the
+// exit code of the process
+func Main(args []string) int {
+	name := "export"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, command := range Commands() {
+		if command.Name != name {
+			continue
+		}
+		err := command.Run(NewContext(), args)
+		if err == flag.ErrHelp {
+			return 0
+		}
+		if err != nil {
+			log.Printf("%s: %s", name, err)
+			return 1
+		}
+		return 0
+	}
+
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	}
+	usage(os.Stderr)
+	return 2
+}
+
+// usage lists the commands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: PerfectScalePoc <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, command := range Commands() {
+		fmt.Fprintf(w, "  %-12s %s\n", command.Name, command.Description)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run PerfectScalePoc <command> -h for the flags of a command")
+}
+
+// Context holds the options shared by every command, and the clusters and sink
+// the command runs against
+type Context struct {
+	// Kubeconfig is the path of the kubeconfig, the in cluster config is used
+	// when empty
+	Kubeconfig string
+	// KubeContexts are the kubeconfig contexts of the clusters, the current
+	// context when empty
+	KubeContexts []string
+	// AllContexts selects every context of the kubeconfig
+	AllContexts bool
+	// Snapshots load the clusters from snapshot archives instead of connecting
+	// to them when set
+	Snapshots []string
+	// ClusterID is the id of a cluster which isn't selected by its context and
+	// has no id recorded in its snapshot
+	ClusterID string
+	// Namespaces restricts the namespaced resources to the namespaces, all
+	// namespaces when empty
+	Namespaces   []string
+	OutputDir    string
+	OutputFormat string
+	// SplitClusters writes the tables of every cluster to a directory named
+	// after the cluster instead of one table across all clusters
+	SplitClusters bool
+	// LoadTimeout bounds the time every cluster is connected to or loaded in,
+	// clusters which take longer are skipped. There is no bound when it is 0.
+	LoadTimeout time.Duration
+	// Hash replaces the pod, owner, node and namespace names and the label
+	// values of the exports with their HMAC keyed by HashSecret
+	Hash       bool
+	HashSecret string
+	// HashMapping is the path of the local file the hashes are mapped to their
+	// values in, not written when empty
+	HashMapping string
+
+	// Clusters are the clusters of the commands. They are collected from the
+	// options on first use when nil.
+	Clusters []*Cluster
+	// Sink receives the exports of the commands. It is created from the output
+	// options on first use when nil.
+	Sink output.Sink
+
+	// tables are the tables written across the clusters, written to the sink
+	// once every cluster ran
+	tables []*output.Table
+	// anonymizer replaces the names of the exports when hashing, it is nil
+	// otherwise
+	anonymizer *anonymize.Anonymizer
+	// writeErr is the first export which failed to be written, returned by
+	// finish once the remaining exports are written
+	writeErr error
+}
+
+// DefaultLoadTimeout is the default time a cluster is connected to or loaded in
+const DefaultLoadTimeout = 5 * time.Minute
+
+// NewContext returns a Context of the options set by the environment
+func NewContext() *Context {
+	return &Context{
+		Kubeconfig:   env.GetKubeConfigPath(),
+		KubeContexts: splitList(env.GetKubeContext()),
+		Snapshots:    splitList(env.GetSnapshotPath()),
+		ClusterID:    env.GetClusterID(),
+		Namespaces:   splitList(env.GetNamespaces()),
+		OutputDir:    env.GetOutputDir(),
+		OutputFormat: env.GetOutputFormat(),
+		LoadTimeout:  DefaultLoadTimeout,
+		Hash:         env.IsHashEnabled(),
+		HashSecret:   env.GetHashSecret(),
+		HashMapping:  env.GetHashMappingPath(),
+	}
+}
+
+// flagSet returns the flag set of the command, holding the flags of the shared
+// options which default to the current options of the context
+func (c *Context) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&c.Kubeconfig, "kubeconfig", c.Kubeconfig, "path of the kubeconfig, the in cluster config if empty ("+env.KubeConfigPathEnvVar+")")
+	fs.Var((*listValue)(&c.KubeContexts), "context", "comma separated kubeconfig contexts of the clusters, the current context if empty ("+env.KubeContextEnvVar+")")
+	fs.BoolVar(&c.AllContexts, "all-contexts", c.AllContexts, "collect the clusters of every kubeconfig context")
+	fs.Var((*listValue)(&c.Snapshots), "snapshot", "comma separated snapshot archives to load the clusters from instead of connecting to them ("+env.SnapshotPathEnvVar+")")
+	fs.StringVar(&c.ClusterID, "cluster-id", c.ClusterID, "id of a cluster not selected by its context and without an id in its snapshot ("+env.ClusterIDEnvVar+")")
+	fs.Var((*listValue)(&c.Namespaces), "namespaces", "comma separated namespaces to restrict the namespaced resources to, all if empty ("+env.NamespacesEnvVar+")")
+	fs.StringVar(&c.OutputDir, "output-dir", c.OutputDir, "directory the exports are written to, - for the standard output ("+env.OutputDirEnvVar+")")
+	fs.StringVar(&c.OutputFormat, "output-format", c.OutputFormat, "format of the exported tables, one of: "+output.FormatCSV+", "+output.FormatJSONL+", "+output.FormatParquet+" ("+env.OutputFormatEnvVar+")")
+	fs.BoolVar(&c.SplitClusters, "split-clusters", c.SplitClusters, "write the tables of every cluster to a directory named after the cluster")
+	fs.DurationVar(&c.LoadTimeout, "load-timeout", c.LoadTimeout, "time every cluster is connected to or loaded in before it is skipped, 0 for no limit")
+	fs.BoolVar(&c.Hash, "hash", c.Hash, "replace the pod, owner, node and namespace names and the label values of the exports with keyed hashes ("+env.ShouldHashEnvVar+")")
+	fs.StringVar(&c.HashSecret, "hash-secret", c.HashSecret, "secret key of the hashes ("+env.HashSecretEnvVar+")")
+	fs.StringVar(&c.HashMapping, "hash-mapping", c.HashMapping, "local file the hashes are mapped to their values in, added to when it exists ("+env.HashMappingEnvVar+")")
+	return fs
+}
+
+// sink returns the sink of the exports, creating it from the output options on
+// first use
+func (c *Context) sink() (output.Sink, error) {
+	if c.Sink == nil {
+		sink, err := output.NewSink(c.OutputFormat, c.OutputDir)
+		if err != nil {
+			return nil, err
+		}
+		c.Sink = sink
+	}
+	return c.Sink, nil
+}
+
+// ClusterIDColumn is the first column of every exported table, holding the id
+// of the cluster of the row
+const ClusterIDColumn = "cluster_id"
+
+// writeCsv writes the CSV written by write for the cluster to the sink, as a
+// table in the output format when name is a .csv, and as is otherwise. Tables
+// get a first cluster_id column and are written along with the tables of the
+// same name of the other clusters by flush, unless SplitClusters is set. Files
+// are written to a directory named after the cluster when there are several
+// clusters. The cluster id of the column and directory is hashed like the names.
+// Failures are logged, so the remaining exports are still written, and the first
+// one is returned by finish.
+func (c *Context) writeCsv(cluster *Cluster, name string, write func(io.Writer) error) {
+	if err := c.writeExport(cluster, name, write); err != nil {
+		log.Printf("Failed writing %s: %s", name, err)
+		if c.writeErr == nil {
+			c.writeErr = fmt.Errorf("failed writing %s: %s", name, err)
+		}
+	}
+}
+
+// writeExport writes the export of writeCsv
+
+func (c *Context) writeExport(cluster *Cluster, name string, write func(io.Writer) error) error {
+	sink, err := c.sink()
+	if err != nil {
+		return err
+	}
+	id := c.anonymizer.Value(cluster.ID)
+	if c.SplitClusters || len(c.Clusters) > 1 {
+		sink = output.NewPrefixSink(sink, id)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	if filepath.Ext(name) != ".csv" {
+		data := buf.Bytes()
+		if filepath.Ext(name) == ".json" {
+			if data, err = c.anonymizer.JSON(data); err != nil {
+				return err
+			}
+		}
+		return sink.WriteFile(name, data)
+	}
+
+	table, err := output.ReadCSV(name, &buf)
+	if err != nil {
+		return err
+	}
+	c.anonymizer.Table(table)
+	table.PrependColumn(ClusterIDColumn, id)
+
+	if c.SplitClusters {
+		return sink.WriteTable(table)
+	}
+	for _, t := range c.tables {
+		if t.Name == name {
+			if err := t.Append(table); err != nil {
+				return fmt.Errorf("cluster %s: %s", cluster.ID, err)
+			}
+			return nil
+		}
+	}
+	c.tables = append(c.tables, table)
+	return nil
+}
+
+// startHashing creates the anonymizer of the exports when hashing
+func (c *Context) startHashing() error {
+	if !c.Hash || c.anonymizer != nil {
+		return nil
+	}
+	if c.HashSecret == "" {
+		return fmt.Errorf("hashing requires a secret, set -hash-secret or %s", env.HashSecretEnvVar)
+	}
+	c.anonymizer = anonymize.NewAnonymizer(c.HashSecret)
+	return nil
+}
+
+// finish writes the tables of every cluster to the sink, and the hashes of the
+// exports to the mapping file. It returns the first export which failed to be
+// written, once the others are.
+func (c *Context) finish() error {
+	err := c.flush()
+	if c.writeErr != nil {
+		err = c.writeErr
+		c.writeErr = nil
+	}
+	if c.anonymizer != nil && c.HashMapping != "" {
+		if mappingErr := anonymize.UpdateMappingFile(c.HashMapping, c.anonymizer.Mapping()); mappingErr != nil && err == nil {
+			err = fmt.Errorf("failed writing hash mapping %s: %s", c.HashMapping, mappingErr)
+		}
+	}
+	return err
+}
+
+// flush writes the tables of every cluster to the sink. Failures are logged, so
+// the remaining tables are still written, and the first one is returned.
+func (c *Context) flush() error {
+	tables := c.tables
+	c.tables = nil
+	sink, err := c.sink()
+	if err != nil {
+		return fmt.Errorf("failed writing tables: %s", err)
+	}
+
+	var failed error
+	for _, table := range tables {
+		if err := sink.WriteTable(table); err != nil {
+			log.Printf("Failed writing %s: %s", table.Name, err)
+			if failed == nil {
+				failed = fmt.Errorf("failed writing %s: %s", table.Name, err)
+			}
+		}
+	}
+	return failed
+}
+
+// parse parses the arguments of a command which takes none
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return nil
+}
+
+// listValue is a comma separated list flag
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/output"
+)
+
+func TestWriteCsvClusterID(t *testing.T) {
+	for _, hash := range []bool{false, true} {
+		for _, split := range []bool{false, true} {
+			sink := output.NewMemorySink()
+			c := &Context{
+				Hash:          hash,
+				HashSecret:    "secret",
+				SplitClusters: split,
+				Clusters:      []*Cluster{{ID: "production"}, {ID: "staging"}},
+				Sink:          sink,
+			}
+			if err := c.startHashing(); err != nil {
+				t.Fatal(err)
+			}
+			for _, cluster := range c.Clusters {
+				c.writeCsv(cluster, "namespaces.csv", func(w io.Writer) error {
+					_, err := io.WriteString(w, "namespace\ndefault\n")
+					return err
+				})
+				c.writeCsv(cluster, "summary.json", func(w io.Writer) error {
+					_, err := io.WriteString(w, "{}")
+					return err
+				})
+			}
+			if err := c.finish(); err != nil {
+				t.Fatal(err)
+			}
+
+			var written []string
+			for name, table := range sink.Tables {
+				written = append(written, name)
+				for _, row := range table.Rows {
+					written = append(written, row[0])
+				}
+			}
+			for name := range sink.Files {
+				written = append(written, name)
+			}
+
+			// the cluster ids are only written in clear text when not hashing
+			for _, id := range []string{"production", "staging"} {
+				found := false
+				for _, s := range written {
+					found = found || strings.Contains(s, id)
+				}
+				if found == hash {
+					t.Errorf("hash %t, split %t: cluster id %s written %t in %v", hash, split, id, found, written)
+				}
+				if hash && !strings.Contains(strings.Join(written, " "), c.anonymizer.Value(id)) {
+					t.Errorf("split %t: expected the hash of %s to be written, got %v", split, id, written)
+				}
+			}
+		}
+	}
+}
+
+// failingSink fails writing the tables and files of its names
+type failingSink struct {
+	*output.MemorySink
+	names map[string]bool
+}
+
+func (s *failingSink) WriteTable(t *output.Table) error {
+	if s.names[t.Name] {
+		return errors.New("disk full")
+	}
+	return s.MemorySink.WriteTable(t)
+}
+
+func (s *failingSink) WriteFile(name string, data []byte) error {
+	if s.names[name] {
+		return errors.New("disk full")
+	}
+	return s.MemorySink.WriteFile(name, data)
+}
+
+func TestWriteFailures(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+
+	dir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		context  *Context
+		expected string
+	}{
+		{
+			name:     "unsupported format",
+			context:  &Context{OutputFormat: "xml", OutputDir: dir},
+			expected: `unsupported output format "xml"`,
+		},
+		{
+			name:     "uncreatable directory",
+			context:  &Context{OutputFormat: output.FormatCSV, OutputDir: filepath.Join(file, "out")},
+			expected: "failed creating output directory",
+		},
+		{
+			name:     "failed table",
+			context:  &Context{Sink: &failingSink{output.NewMemorySink(), map[string]bool{"pods.csv": true}}},
+			expected: "failed writing pods.csv: disk full",
+		},
+		{
+			name:     "failed split table",
+			context:  &Context{SplitClusters: true, Sink: &failingSink{output.NewMemorySink(), map[string]bool{"test-cluster/nodes.csv": true}}},
+			expected: "failed writing nodes.csv: disk full",
+		},
+	}
+
+	for _, test := range tests {
+		test.context.Clusters = []*Cluster{{ID: "test-cluster", Cache: h.Cache}}
+		err := Export(test.context, nil)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.expected, err)
+		}
+	}
+
+	// the other tables are still written
+	sink := &failingSink{output.NewMemorySink(), map[string]bool{"pods.csv": true}}
+	Export(&Context{Clusters: []*Cluster{{ID: "test-cluster", Cache: h.Cache}}, Sink: sink}, nil)
+	if sink.Tables["nodes.csv"] == nil {
+		t.Error("expected nodes.csv to be written after pods.csv failed")
+	}
+}
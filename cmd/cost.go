@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+// Cost prices the current cluster nodes, writing the cost per node to
+// node_costs.csv, the cost per node group and of the whole cluster to
+// node_group_costs.csv, and the nodes which could not be priced to
+// unknown_node_costs.csv. With -allocation, the cost of the nodes is split between
+// the pods running on them and written per namespace and workload to
+// allocation_costs.csv and allocation_costs.json, per namespace to
+// namespace_costs.csv and the idle cost per node group to idle_costs.csv. With
+// several clusters, the cost of all clusters is printed last.
+func Cost(c *Context, args []string) error {
+	fs := c.flagSet("cost")
+	instancesPath := fs.String("instances", "", "path to an instances csv, overriding the pricing provider chosen by USE_CSV_PROVIDER")
+	purchaseOption := fs.String("purchase-option", pricing.OnDemand, "purchase option of the nodes not labeled as spot nodes, one of: "+strings.Join(pricing.PurchaseOptions(), ", "))
+	allocation := fs.Bool("allocation", false, "allocate the node costs to namespaces and owners")
+	cpuWeight := fs.Float64("cpu-weight", cost.DefaultAllocationOptions().CPUWeight, "relative share of the node cost allocated by CPU requests")
+	memoryWeight := fs.Float64("memory-weight", cost.DefaultAllocationOptions().MemoryWeight, "relative share of the node cost allocated by memory requests")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if !util.Contains(pricing.PurchaseOptions(), *purchaseOption) {
+		return fmt.Errorf("unknown purchase option %q, must be one of: %s", *purchaseOption, strings.Join(pricing.PurchaseOptions(), ", "))
+	}
+
+	provider, err := newProvider(*instancesPath)
+	if err != nil {
+		return fmt.Errorf("failed creating pricing provider: %s", err)
+	}
+
+	options := cost.DefaultOptions()
+	options.PurchaseOption = *purchaseOption
+	allocationOptions := &cost.AllocationOptions{
+		CPUWeight:    *cpuWeight,
+		MemoryWeight: *memoryWeight,
+	}
+
+	var clusters, priced, unknown int
+	var hourly, monthly float64
+	err = c.forEachCluster(func(cluster *Cluster) error {
+		report := costCluster(c, cluster, provider, options, *allocation, allocationOptions)
+		clusters++
+		priced += len(report.Nodes)
+		unknown += len(report.Unknown)
+		hourly += report.Hourly
+		monthly += report.Monthly
+		return nil
+	})
+	if err != nil || clusters < 2 {
+		return err
+	}
+
+	fmt.Println("===== All clusters cost ======")
+	fmt.Printf(" * clusters: %d, priced nodes: %d, unknown nodes: %d\n", clusters, priced, unknown)
+	fmt.Printf(" * hourly cost: %.3f, monthly cost: %.2f\n", hourly, monthly)
+	return nil
+}
+
+// costCluster prices the nodes of the cluster and writes their costs, along
+// with the allocated costs when allocate is set, and returns the report
+func costCluster(c *Context, cluster *Cluster, provider pricing.Provider, options *cost.Options, allocate bool, allocationOptions *cost.AllocationOptions) *cost.Report {
+	k8sCache := cluster.Cache
+	nodes := k8sCache.GetAllNodes()
+	_, node2group := nodegroup.Group(nodes)
+	report := cost.NewReport(nodes, node2group, provider, options)
+
+	fmt.Println("===== Cluster cost ======")
+	fmt.Printf(" * priced nodes: %d, unknown nodes: %d\n", len(report.Nodes), len(report.Unknown))
+	fmt.Printf(" * hourly cost: %.3f, monthly cost: %.2f\n", report.Hourly, report.Monthly)
+
+	c.writeCsv(cluster, "node_costs.csv", func(f io.Writer) error {
+		return cost.WriteNodes(f, report)
+	})
+	c.writeCsv(cluster, "node_group_costs.csv", func(f io.Writer) error {
+		return cost.WriteNodeGroups(f, report)
+	})
+	c.writeCsv(cluster, "unknown_node_costs.csv", func(f io.Writer) error {
+		return cost.WriteUnknown(f, report)
+	})
+
+	if !allocate {
+		return report
+	}
+
+	allocated := cost.Allocate(report, nodes, k8sCache.GetAllPods(), workload.NewResolverFromCache(k8sCache), allocationOptions)
+	fmt.Printf(" * allocated hourly cost: %.3f, idle hourly cost: %.3f\n", allocated.Hourly, allocated.IdleHourly)
+
+	c.writeCsv(cluster, "allocation_costs.csv", func(f io.Writer) error {
+		return cost.WriteAllocations(f, allocated.Allocations)
+	})
+	c.writeCsv(cluster, "allocation_costs.json", func(f io.Writer) error {
+		return cost.WriteJSON(f, allocated)
+	})
+	c.writeCsv(cluster, "namespace_costs.csv", func(f io.Writer) error {
+		return cost.WriteAllocations(f, allocated.ByNamespace())
+	})
+	c.writeCsv(cluster, "idle_costs.csv", func(f io.Writer) error {
+		return cost.WriteIdle(f, allocated)
+	})
+	return report
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+)
+
+func TestCost(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+	instances := filepath.Join("testdata", "instances.csv")
+
+	t.Run("nodes", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Cost(c, []string{"-instances", instances}); err != nil {
+			t.Fatal(err)
+		}
+
+		assertCells(t, sink, "node_costs.csv", "node_name", "node-a", "node-b", "node-c")
+		assertCells(t, sink, "node_costs.csv", "hourly_cost", "0.0960", "0.0960", "0.2520")
+		assertCells(t, sink, "node_group_costs.csv", "node_group", "0", "1", "cluster")
+		assertCells(t, sink, "node_group_costs.csv", "hourly_cost", "0.1920", "0.2520", "0.4440")
+		assertCells(t, sink, "unknown_node_costs.csv", "node_name")
+		if _, ok := sink.Tables["allocation_costs.csv"]; ok {
+			t.Error("expected no allocation without -allocation")
+		}
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Cost(c, []string{"-instances", instances, "-purchase-option", "reserved"}); err != nil {
+			t.Fatal(err)
+		}
+		assertCells(t, sink, "node_costs.csv", "hourly_cost", "0.0600", "0.0600", "0.1590")
+	})
+
+	t.Run("allocation", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Cost(c, []string{"-instances", instances, "-allocation"}); err != nil {
+			t.Fatal(err)
+		}
+
+		assertCells(t, sink, "namespace_costs.csv", "namespace", "2024", "data", "default", "kube-system")
+		assertCells(t, sink, "namespace_costs.csv", "num_pods", "1", "1", "2", "3")
+		assertCells(t, sink, "allocation_costs.csv", "owner_name", "report", "db", "web", "logs")
+		assertCells(t, sink, "idle_costs.csv", "node_group", "0", "1")
+
+		var allocated map[string]interface{}
+		if err := json.Unmarshal(sink.Files["allocation_costs.json"], &allocated); err != nil {
+			t.Errorf("expected allocation_costs.json to be written: %s", err)
+		}
+	})
+
+	t.Run("unknown purchase option", func(t *testing.T) {
+		c, _ := testContext(h)
+		if err := Cost(c, []string{"-instances", instances, "-purchase-option", "free"}); err == nil {
+			t.Error("expected an error for an unknown purchase option")
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+
+	"github.com/mikeskali/PerfectScalePoc/anonymize"
+	"github.com/mikeskali/PerfectScalePoc/output"
+)
+
+// Deanonymize replaces the hashes found in the exported files given as
+// arguments, such as recommendations computed from hashed exports, with their
+// values from the hash mapping file, and writes the files under the same name to
+// the sink. Only CSV, JSON Lines and JSON files are supported.
+func Deanonymize(c *Context, args []string) error {
+	fs := c.flagSet("deanonymize")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("expected the files to deanonymize")
+	}
+	if c.HashMapping == "" {
+		return fmt.Errorf("deanonymizing requires the hash mapping, set -hash-mapping or HASH_MAPPING_PATH")
+	}
+
+	mapping, err := anonymize.ReadMappingFile(c.HashMapping)
+	if err != nil {
+		return fmt.Errorf("failed reading hash mapping %s: %s", c.HashMapping, err)
+	}
+	sink, err := c.sink()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		if filepath.Ext(path) == "."+output.FormatParquet {
+			return fmt.Errorf("can't deanonymize %s, parquet files aren't supported", path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := sink.WriteFile(filepath.Base(path), anonymize.Reveal(data, mapping)); err != nil {
+			return fmt.Errorf("failed writing %s: %s", filepath.Base(path), err)
+		}
+		log.Printf("Deanonymized %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mikeskali/PerfectScalePoc/snapshot"
+)
+
+// Diff compares two snapshot archives, given as the old and the new archive
+// arguments, and writes the resources added, removed and changed between them to
+// diff.csv. It doesn't need a cluster.
+func Diff(c *Context, args []string) error {
+	fs := c.flagSet("diff")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("expected the old and the new snapshot archives, got %d arguments", fs.NArg())
+	}
+
+	if err := c.startHashing(); err != nil {
+		return err
+	}
+	if _, err := c.sink(); err != nil {
+		return err
+	}
+
+	old, err := snapshot.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed reading snapshot %s: %s", fs.Arg(0), err)
+	}
+	new, err := snapshot.ReadFile(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("failed reading snapshot %s: %s", fs.Arg(1), err)
+	}
+
+	changes, err := snapshot.Diff(old, new)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Type]++
+	}
+	fmt.Println("===== Snapshot diff ======")
+	fmt.Printf(" * %s captured at %s, %s captured at %s\n", fs.Arg(0), old.CapturedAt, fs.Arg(1), new.CapturedAt)
+	fmt.Printf(" * added: %d, removed: %d, changed: %d\n", counts[snapshot.Added], counts[snapshot.Removed], counts[snapshot.Changed])
+
+	// the changes are tagged with the cluster of the new snapshot
+	cluster := &Cluster{ID: new.ClusterID}
+	c.writeCsv(cluster, "diff.csv", func(f io.Writer) error {
+		return snapshot.WriteChanges(f, changes)
+	})
+	return c.finish()
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/output"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestDiff(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	old := writeSnapshot(t, h, dir, "old.json.gz")
+
+	// scale web up, replace a logs pod and remove the bare pod
+	var web *appsv1.Deployment
+	for _, obj := range testObjects() {
+		if d, ok := obj.(*appsv1.Deployment); ok && d.Name == "web" {
+			web = d
+		}
+	}
+	replicas := int32(3)
+	web.Spec.Replicas = &replicas
+	if err := h.Update(web); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Add(clustercachetest.Pod("kube-system", "logs-d", "node-c", "100m", "128Mi", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Delete(clustercachetest.Pod("2024", "report", "node-b", "250m", "1Gi", nil)); err != nil {
+		t.Fatal(err)
+	}
+	err := h.WaitFor(func() bool {
+		deployments := h.Cache.GetAllDeployments()
+		return len(h.Cache.GetAllPods()) == 7 && len(deployments) == 1 && *deployments[0].Spec.Replicas == 3
+	}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	new := writeSnapshot(t, h, dir, "new.json.gz")
+
+	t.Run("changes", func(t *testing.T) {
+		sink := output.NewMemorySink()
+		c := &Context{Sink: sink}
+		if err := Diff(c, []string{old, new}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the changes are tagged with the cluster of the new snapshot
+		assertCells(t, sink, "diff.csv", ClusterIDColumn, "test-cluster", "test-cluster", "test-cluster")
+		assertCells(t, sink, "diff.csv", "resource", "deployments", "pods", "pods")
+		assertCells(t, sink, "diff.csv", "name", "web", "report", "logs-d")
+		assertCells(t, sink, "diff.csv", "change", "changed", "removed", "added")
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		sink := output.NewMemorySink()
+		c := &Context{Sink: sink}
+		if err := Diff(c, []string{"-hash", "-hash-secret", "secret", old, new}); err != nil {
+			t.Fatal(err)
+		}
+		assertCells(t, sink, "diff.csv", ClusterIDColumn, c.anonymizer.Value("test-cluster"), c.anonymizer.Value("test-cluster"), c.anonymizer.Value("test-cluster"))
+		assertCells(t, sink, "diff.csv", "name", c.anonymizer.Value("web"), c.anonymizer.Value("report"), c.anonymizer.Value("logs-d"))
+	})
+
+	t.Run("arguments", func(t *testing.T) {
+		c := &Context{Sink: output.NewMemorySink()}
+		if err := Diff(c, []string{old}); err == nil {
+			t.Error("expected an error without the new snapshot archive")
+		}
+	})
+}
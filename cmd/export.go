@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/mikeskali/PerfectScalePoc/anonymize"
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+	"github.com/mikeskali/PerfectScalePoc/inventory"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Export prints the node groups, DaemonSets, StatefulSets and deployments of
+// every cluster, and writes them to node_groups.csv, nodes.csv, daemonsets.csv,
+// statefulsets.csv, deployments.csv and workloads.csv, and the pods, along with
+// their usage when PROMETHEUS_SERVER_ENDPOINT is set and a single cluster is
+// selected, to pods.csv.
+func Export(c *Context, args []string) error {
+	fs := c.flagSet("export")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	usage, err := c.clusterUsage()
+	if err != nil {
+		log.Printf("Exporting the pods without their usage: %s", err)
+	}
+	return c.forEachCluster(func(cluster *Cluster) error {
+		k8sCache := cluster.Cache
+		nodes2groups := c.printNodeGroups(cluster)
+		fmt.Println()
+		fmt.Println()
+
+		printDaemonSets(k8sCache, c.anonymizer)
+		fmt.Println()
+		fmt.Println()
+
+		printStatefulSets(k8sCache, c.anonymizer)
+		fmt.Println()
+		fmt.Println()
+
+		printDeployments(k8sCache, c.anonymizer)
+
+		c.writeCsv(cluster, "deployments.csv", func(f io.Writer) error {
+			return inventory.WriteDeployments(f, k8sCache.GetAllDeployments())
+		})
+		c.writeCsv(cluster, "statefulsets.csv", func(f io.Writer) error {
+			return inventory.WriteStatefulSets(f, k8sCache.GetAllStatefulSets())
+		})
+		c.writeCsv(cluster, "daemonsets.csv", func(f io.Writer) error {
+			return inventory.WriteDaemonSets(f, k8sCache.GetAllDaemonSets())
+		})
+		c.writeCsv(cluster, "workloads.csv", func(f io.Writer) error {
+			containers := inventory.WorkloadContainers(k8sCache.GetAllDeployments(), k8sCache.GetAllStatefulSets(), k8sCache.GetAllDaemonSets(), k8sCache.GetAllHorizontalPodAutoscalers())
+			return inventory.WriteWorkloadContainers(f, containers)
+		})
+
+		c.printPods(cluster, nodes2groups, usage)
+		return nil
+	})
+}
+
+func (c *Context) printPods(cluster *Cluster, node2group map[string]string, usage *metrics.Usage) {
+	pods := inventory.Pods(cluster.Cache.GetAllPods(), node2group, workload.NewResolverFromCache(cluster.Cache), usage)
+
+	c.writeCsv(cluster, "pods.csv", func(f io.Writer) error {
+		return inventory.WritePods(f, pods)
+	})
+}
+
+func (c *Context) printNodeGroups(cluster *Cluster) map[string]string {
+	groups, node2group := nodegroup.Group(cluster.Cache.GetAllNodes())
+	nodes := inventory.Nodes(groups)
+
+	for _, group := range groups {
+		fmt.Println("===== Node group: " + group.ID + " ======")
+		printLabels(group, c.anonymizer)
+
+		fmt.Println("Nodes:")
+		for _, node := range nodes {
+			if node.NodeGroup != group.ID {
+				continue
+			}
+			fmt.Println(" * Name: ", c.anonymizer.Value(node.Name), ", node taints: ", strings.Join(node.Taints, ","), ", allocCPU: ", node.Allocatable.CPU, ", allocMemory", node.Allocatable.Memory, ", capCpu", node.Capacity.CPU, ", capMemory", node.Capacity.Memory)
+		}
+	}
+
+	c.writeCsv(cluster, "node_groups.csv", func(f io.Writer) error {
+		return inventory.WriteNodeGroups(f, groups)
+	})
+	c.writeCsv(cluster, "nodes.csv", func(f io.Writer) error {
+		return inventory.WriteNodes(f, nodes)
+	})
+
+	return node2group
+}
+
+func printDeployments(k8sCache clustercache.ClusterCache, a *anonymize.Anonymizer) {
+	if len(k8sCache.GetAllDeployments()) > 0 {
+		fmt.Println("============== Deployments =============")
+	} else {
+		return
+	}
+
+	for i, dep := range k8sCache.GetAllDeployments() {
+		var nodeAffinity, podAffinity, podAntiAffinity = getAffinity(dep.Spec.Template.Spec.Affinity, a)
+		fmt.Printf(" (%d) %s, Replicas: %s, Node Selector: %s, labelSelectors: %s\n",
+			i,
+			a.Value(dep.Name),
+			inventory.ReplicasColumn(dep.Spec.Replicas),
+			stringsMapToString(a.LabelMap(dep.Spec.Template.Spec.NodeSelector)),
+			stringsMapToString(a.LabelMap(dep.Spec.Selector.MatchLabels)),
+		)
+
+		fmt.Printf("      NodeAffinity: %s, PodAffinity: %s, PodAntiAffinity: %s\n",
+			nodeAffinity,
+			podAffinity,
+			podAntiAffinity)
+
+		fmt.Println("      Containers:")
+		for _, container := range dep.Spec.Template.Spec.Containers {
+			fmt.Printf("            %s, request cpu: %v, request memory: %v\n", container.Name, container.Resources.Requests.Cpu().String(), container.Resources.Requests.Memory().String())
+		}
+
+		if len(dep.Spec.Selector.MatchExpressions) > 0 {
+			fmt.Println("  expression selectors:")
+		}
+		for _, exprSelector := range dep.Spec.Selector.MatchExpressions {
+			fmt.Printf("     %s:%s\n", exprSelector.Key, strings.Join(a.Values(exprSelector.Values), ","))
+		}
+	}
+}
+
+func printStatefulSets(k8sCache clustercache.ClusterCache, a *anonymize.Anonymizer) {
+	statefulSets := k8sCache.GetAllStatefulSets()
+	if len(statefulSets) > 0 {
+		fmt.Println("============== Stateful States =============")
+	} else {
+		return
+	}
+	for i, sts := range statefulSets {
+		var nodeAffinity, podAffinity, podAntiAffinity = getAffinity(sts.Spec.Template.Spec.Affinity, a)
+		selector := sts.Spec.Selector
+		fmt.Printf("  (%d) %s, Replicas: %s, Pod management policy: %s, labelSelectors: %s\n",
+			i,
+			a.Value(sts.Name),
+			inventory.ReplicasColumn(sts.Spec.Replicas),
+			sts.Spec.PodManagementPolicy,
+			stringsMapToString(a.LabelMap(selector.MatchLabels)))
+
+		fmt.Printf("      NodeAffinity: %s, PodAffinity: %s, PodAntiAffinity: %s\n",
+			nodeAffinity,
+			podAffinity,
+			podAntiAffinity)
+		fmt.Println("      Containers:")
+		for _, container := range sts.Spec.Template.Spec.Containers {
+			fmt.Printf("            %s, request cpu: %v, request memory: %v\n", container.Name, container.Resources.Requests.Cpu().String(), container.Resources.Requests.Memory().String())
+		}
+
+		if len(selector.MatchExpressions) > 0 {
+			fmt.Println("      expression selectors:")
+		}
+		for _, exprSelector := range selector.MatchExpressions {
+			fmt.Printf("            %s:%s\n", exprSelector.Key, strings.Join(a.Values(exprSelector.Values), ","))
+		}
+	}
+}
+
+func printDaemonSets(k8sCache clustercache.ClusterCache, a *anonymize.Anonymizer) {
+	daemonSets := k8sCache.GetAllDaemonSets()
+
+	if len(daemonSets) > 0 {
+		fmt.Println("============== Daemon Sets =============")
+	} else {
+		return
+	}
+	for i, ds := range daemonSets {
+		selector := ds.Spec.Selector
+
+		var nodeAffinity, podAffinity, podAntiAffinity = getAffinity(ds.Spec.Template.Spec.Affinity, a)
+
+		fmt.Printf(" (%d) %s, labelSelectors: %s\n",
+			i,
+			a.Value(ds.Name),
+			stringsMapToString(a.LabelMap(selector.MatchLabels)))
+
+		fmt.Printf("      NodeAffinity: %s, PodAffinity: %s, PodAntiAffinity: %s\n",
+			nodeAffinity,
+			podAffinity,
+			podAntiAffinity)
+
+		fmt.Println("      Containers: ")
+		for _, container := range ds.Spec.Template.Spec.Containers {
+			fmt.Printf("            %s, request cpu: %v, request memory: %v\n", container.Name, container.Resources.Requests.Cpu().String(), container.Resources.Requests.Memory().String())
+		}
+
+		if len(selector.MatchExpressions) > 0 {
+			fmt.Println("      expression selectors:")
+		}
+		for _, exprSelector := range selector.MatchExpressions {
+			fmt.Printf("            %s:%s\n", exprSelector.Key, strings.Join(a.Values(exprSelector.Values), ","))
+		}
+	}
+}
+
+func stringsMapToString(labels map[string]string) string {
+	var labelsSlice []string
+	for k, v := range labels {
+		labelsSlice = append(labelsSlice, k+":"+v)
+	}
+	sort.Strings(labelsSlice)
+	return strings.Join(labelsSlice, ",")
+}
+
+func printLabels(group *nodegroup.NodeGroup, a *anonymize.Anonymizer) {
+	fmt.Println("labels:")
+	var commonLabels []string
+	for _, key := range group.CommonLabels {
+		commonLabels = append(commonLabels, key+" : "+a.Value(group.Labels[key]))
+	}
+
+	fmt.Println(" * common labels: ", strings.Join(commonLabels, ","))
+	fmt.Println(" * ignore labels (not participating in group calculation):")
+	for _, key := range group.IgnoredLabels {
+		fmt.Println("    * ", key, ":", a.Value(group.Labels[key]))
+	}
+	fmt.Println(" * unique labels: ")
+	for _, key := range group.UniqueLabels {
+		fmt.Println("    * ", key, ":", a.Value(group.Labels[key]))
+	}
+}
+
+// getAffinity returns the hashed node, pod and pod anti affinity of the pods
+func getAffinity(affinity *v1.Affinity, a *anonymize.Anonymizer) (nodeAffinity, podAffinity, podAntiAffinity string) {
+	nodeAffinity, podAffinity, podAntiAffinity = inventory.AffinityColumns(affinity)
+	return a.Value(nodeAffinity), a.Value(podAffinity), a.Value(podAntiAffinity)
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/output"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testObjects are the objects of a cluster with a general purpose node group in
+// two zones and a memory optimized one, running a deployment, a statefulset, a
+// daemonset and a bare pod in a namespace named like a number
+func testObjects() []runtime.Object {
+	general := func(zone string) map[string]string {
+		return map[string]string{"topology.kubernetes.io/zone": zone, "pool": "general"}
+	}
+	memory := map[string]string{"topology.kubernetes.io/zone": "us-east-1a", "pool": "memory"}
+
+	web := clustercachetest.Deployment("default", "web", 2)
+	web.Spec.Template = template("500m", "512Mi")
+	webRS := clustercachetest.ReplicaSet("default", "web-5d8f", clustercachetest.OwnerReference("Deployment", web))
+	db := clustercachetest.StatefulSet("data", "db", 1)
+	db.Spec.Template = template("2", "16Gi")
+	logs := clustercachetest.DaemonSet("kube-system", "logs")
+	logs.Spec.Template = template("100m", "128Mi")
+
+	return []runtime.Object{
+		clustercachetest.Node("node-a", "m5.large", "2", "8Gi", general("us-east-1a")),
+		clustercachetest.Node("node-b", "m5.large", "2", "8Gi", general("us-east-1b")),
+		clustercachetest.Node("node-c", "r5.xlarge", "4", "32Gi", memory),
+		web, webRS, db, logs,
+		clustercachetest.Pod("default", "web-5d8f-x1", "node-a", "500m", "512Mi", clustercachetest.OwnerReference("ReplicaSet", webRS)),
+		clustercachetest.Pod("default", "web-5d8f-x2", "node-b", "500m", "512Mi", clustercachetest.OwnerReference("ReplicaSet", webRS)),
+		clustercachetest.Pod("data", "db-0", "node-c", "2", "16Gi", clustercachetest.OwnerReference("StatefulSet", db)),
+		clustercachetest.Pod("kube-system", "logs-a", "node-a", "100m", "128Mi", clustercachetest.OwnerReference("DaemonSet", logs)),
+		clustercachetest.Pod("kube-system", "logs-b", "node-b", "100m", "128Mi", clustercachetest.OwnerReference("DaemonSet", logs)),
+		clustercachetest.Pod("kube-system", "logs-c", "node-c", "100m", "128Mi", clustercachetest.OwnerReference("DaemonSet", logs)),
+		clustercachetest.Pod("2024", "report", "node-b", "250m", "1Gi", nil),
+	}
+}
+
+// template returns a pod template of a main container requesting the cpu and
+// memory
+func template(cpu string, memory string) v1.PodTemplateSpec {
+	return v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "main",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse(cpu),
+						v1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			}},
+		},
+	}
+}
+
+// testContext returns a context running the commands against the harness and
+// writing to a memory sink
+func testContext(h *clustercachetest.Harness) (*Context, *output.MemorySink) {
+	sink := output.NewMemorySink()
+	return &Context{
+		Clusters: []*Cluster{{ID: "test-cluster", Cache: h.Cache}},
+		Sink:     sink,
+	}, sink
+}
+
+// tableOf returns the table written to the sink by name
+func tableOf(t *testing.T, sink *output.MemorySink, name string) *output.Table {
+	t.Helper()
+	table := sink.Tables[name]
+	if table == nil {
+		t.Fatalf("expected %s to be written", name)
+	}
+	return table
+}
+
+// cells returns the cells of the column in every row of the table
+func cells(t *testing.T, table *output.Table, column string) []string {
+	t.Helper()
+	for i, c := range table.Columns {
+		if c != column {
+			continue
+		}
+		var values []string
+		for _, row := range table.Rows {
+			values = append(values, cell(row, i))
+		}
+		return values
+	}
+	t.Fatalf("%s has no column %s", table.Name, column)
+	return nil
+}
+
+func cell(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+func assertCells(t *testing.T, sink *output.MemorySink, name string, column string, expected ...string) {
+	t.Helper()
+	if actual := cells(t, tableOf(t, sink, name), column); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%s %s: expected %v, got %v", name, column, expected, actual)
+	}
+}
+
+// writeSnapshot captures the harness to a snapshot archive in dir with the
+// snapshot command and returns its path
+func writeSnapshot(t *testing.T, h *clustercachetest.Harness, dir string, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	c, _ := testContext(h)
+	if err := Snapshot(c, []string{"-out", path}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestExport(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	archive := writeSnapshot(t, h, dir, "snapshot.json.gz")
+
+	t.Run("snapshot", func(t *testing.T) {
+		sink := output.NewMemorySink()
+		c := &Context{Snapshots: []string{archive}, Sink: sink}
+		if err := Export(c, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// the cluster is identified by the id recorded in the snapshot
+		assertCells(t, sink, "deployments.csv", ClusterIDColumn, "test-cluster")
+		assertCells(t, sink, "deployments.csv", "name", "web")
+		assertCells(t, sink, "statefulsets.csv", "name", "db")
+		assertCells(t, sink, "daemonsets.csv", "name", "logs")
+		assertCells(t, sink, "workloads.csv", "workload_name", "db", "web", "logs")
+		assertCells(t, sink, "workloads.csv", "replicas", "1", "2", "0")
+		assertCells(t, sink, "workloads.csv", "req_cpu_milli_core", "2000", "500", "100")
+		assertCells(t, sink, "pods.csv", "pod_name", "report", "db-0", "web-5d8f-x1", "web-5d8f-x2", "logs-a", "logs-b", "logs-c")
+		assertCells(t, sink, "pods.csv", "owner_kind", "BarePod", "StatefulSet", "Deployment", "Deployment", "DaemonSet", "DaemonSet", "DaemonSet")
+		assertCells(t, sink, "nodes.csv", "node_name", "node-a", "node-b", "node-c")
+	})
+
+	t.Run("namespaces", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Export(c, []string{"-namespaces", "default,2024"}); err != nil {
+			t.Fatal(err)
+		}
+		assertCells(t, sink, "pods.csv", "pod_name", "report", "web-5d8f-x1", "web-5d8f-x2")
+		assertCells(t, sink, "workloads.csv", "workload_name", "web")
+		// nodes aren't namespaced
+		assertCells(t, sink, "nodes.csv", "node_name", "node-a", "node-b", "node-c")
+	})
+}
+
+func TestExportUnsetFields(t *testing.T) {
+	// the replicas are defaulted by the API server, and may be unset in objects
+	// built otherwise
+	web := clustercachetest.Deployment("default", "web", 2)
+	web.Spec.Replicas = nil
+	web.Spec.Template = template("500m", "512Mi")
+	web.Spec.Template.Spec.Affinity = &v1.Affinity{
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{{TopologyKey: "kubernetes.io/hostname"}},
+		},
+	}
+	db := clustercachetest.StatefulSet("data", "db", 1)
+	db.Spec.Replicas = nil
+	h := clustercachetest.NewHarness(web, db)
+	defer h.Stop()
+
+	c, sink := testContext(h)
+	if err := Export(c, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertCells(t, sink, "deployments.csv", "replicas", "")
+	assertCells(t, sink, "statefulsets.csv", "replicas", "")
+
+	nodeAffinity, podAffinity, podAntiAffinity := getAffinity(web.Spec.Template.Spec.Affinity, nil)
+	if nodeAffinity != "" || podAffinity != "" || !strings.Contains(podAntiAffinity, "kubernetes.io/hostname") {
+		t.Errorf("expected only the pod anti affinity, got %q, %q and %q", nodeAffinity, podAffinity, podAntiAffinity)
+	}
+}
+
+func TestExportGolden(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+
+	tests := []struct {
+		name string
+		args []string
+		dir  string
+	}{
+		{name: "names", dir: filepath.Join("testdata", "export")},
+		{name: "hashes", args: []string{"-hash", "-hash-secret", "golden"}, dir: filepath.Join("testdata", "export", "hash")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, sink := testContext(h)
+			if err := Export(c, test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range []string{"node_groups.csv", "nodes.csv", "pods.csv"} {
+				table := sink.Tables[name]
+				if table == nil {
+					t.Errorf("expected %s to be written", name)
+					continue
+				}
+				var buf bytes.Buffer
+				if err := output.WriteCSV(&buf, table); err != nil {
+					t.Fatal(err)
+				}
+				if err := clustercachetest.CompareGolden(filepath.Join(test.dir, name), buf.Bytes()); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
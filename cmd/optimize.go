@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/optimizer"
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Optimize recommends the cheapest instance type for each node group, writing
+// solutions_<group>.csv and all_placements_<group>.csv to the sink,
+// along with the cheapest mix of instance types in mix_<group>.csv and
+// mix_placements_<group>.csv. Pods whose scheduling constraints rule out an
+// instance type are listed in unschedulable_<group>.csv, and the node count per
+// zone of the recommendations is written to zones_<group>.csv.
+func Optimize(c *Context, args []string) error {
+	fs := c.flagSet("optimize")
+	instancesPath := fs.String("instances", "", "path to an instances csv, overriding the pricing provider chosen by USE_CSV_PROVIDER")
+	region := fs.String("region", "", "region of the instance type prices, detected from the node labels if empty")
+	operatingSystem := fs.String("os", pricing.Linux, "operating system of the instance type prices, one of: "+strings.Join(pricing.OperatingSystems(), ", "))
+	purchaseOption := fs.String("purchase-option", pricing.Reserved, "purchase option of the instance type prices, one of: "+strings.Join(pricing.PurchaseOptions(), ", "))
+	nodeGroup := fs.String("node-group", "", "optimize a single node group id, all node groups if empty")
+	strategy := fs.String("strategy", optimizer.Auto, "packing strategy, one of: "+strings.Join(optimizer.Strategies(), ", "))
+	searchLimit := fs.Int("search-limit", optimizer.DefaultSearchLimit, "max search nodes explored per instance type by the exact strategy")
+	maxTypes := fs.Int("max-types", optimizer.DefaultOptions().MaxInstanceTypes, "max number of distinct instance types in a mix")
+	mixCandidates := fs.Int("mix-candidates", optimizer.DefaultOptions().MixCandidates, "number of cheapest instance types combined into mixes")
+	minNodesPerZone := fs.Int("min-nodes-per-zone", optimizer.DefaultOptions().MinNodesPerZone, "min number of nodes kept in every zone the node group currently has nodes in")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := optimizer.ValidateStrategy(*strategy); err != nil {
+		return err
+	}
+
+	provider, err := newProvider(*instancesPath)
+	if err != nil {
+		return fmt.Errorf("failed creating pricing provider: %s", err)
+	}
+
+	options := optimizer.DefaultOptions()
+	options.Strategy = *strategy
+	options.SearchLimit = *searchLimit
+	options.MaxInstanceTypes = *maxTypes
+	options.MixCandidates = *mixCandidates
+	options.MinNodesPerZone = *minNodesPerZone
+	opt := optimizer.NewOptimizer(options)
+
+	return c.forEachCluster(func(cluster *Cluster) error {
+		key := pricing.Key{
+			Region:          *region,
+			OperatingSystem: *operatingSystem,
+			PurchaseOption:  *purchaseOption,
+		}
+		return optimizeCluster(c, cluster, provider, key, opt, *nodeGroup)
+	})
+}
+
+// optimizeCluster recommends the instance types of the node groups of the
+// cluster, of the node group only unless it is empty, priced in the region of
+// the key, or of the cluster when the key has none
+func optimizeCluster(c *Context, cluster *Cluster, provider pricing.Provider, key pricing.Key, opt *optimizer.Optimizer, nodeGroup string) error {
+	k8sCache := cluster.Cache
+	nodes := k8sCache.GetAllNodes()
+	_, node2group := nodegroup.Group(nodes)
+	if key.Region == "" {
+		key.Region = clusterRegion(nodes)
+	}
+
+	priced, err := provider.InstanceTypes(key)
+	if err != nil {
+		return fmt.Errorf("failed loading instance types: %s", err)
+	}
+	types := optimizer.NewInstanceTypes(priced)
+
+	for _, problem := range optimizer.NewProblems(k8sCache.GetAllPods(), nodes, node2group, workload.NewResolverFromCache(k8sCache)) {
+		if nodeGroup != "" && problem.NodeGroup != nodeGroup {
+			continue
+		}
+
+		fmt.Println("===== Optimizing node group: " + problem.NodeGroup + " ======")
+		fmt.Printf(" * pods: %d, demand: %s, per node overhead: %s\n", len(problem.Pods), problem.Demand(), problem.Overhead)
+
+		solutions := opt.Solve(problem, types)
+		c.writeCsv(cluster, "solutions_"+problem.NodeGroup+".csv", func(f io.Writer) error {
+			return optimizer.WriteSolutions(f, solutions)
+		})
+
+		c.writeCsv(cluster, "unschedulable_"+problem.NodeGroup+".csv", func(f io.Writer) error {
+			return optimizer.WriteUnschedulable(f, solutions)
+		})
+
+		best := optimizer.Cheapest(solutions)
+		if best == nil {
+			fmt.Println(" * no solution found")
+			continue
+		}
+		fmt.Printf(" * best: %s, nodes: %d, hourly cost: %.3f, strategy: %s, lower bound gap: %.1f%%\n", best.Type.Name, best.NumNodes(), best.Cost, best.Strategy, best.Gap*100)
+		fmt.Printf(" * nodes per zone: %s\n", optimizer.FormatZones(best.Zones))
+
+		c.writeCsv(cluster, "all_placements_"+problem.NodeGroup+".csv", func(f io.Writer) error {
+			return optimizer.WritePlacements(f, problem, best.Nodes)
+		})
+
+		mix := opt.SolveMix(problem, solutions)
+		if mix != nil && mix.Cost >= best.Cost {
+			mix = nil
+		}
+		c.writeCsv(cluster, "zones_"+problem.NodeGroup+".csv", func(f io.Writer) error {
+			return optimizer.WriteZones(f, best, mix)
+		})
+		if mix == nil {
+			fmt.Println(" * no cheaper mix of instance types found")
+			continue
+		}
+		fmt.Printf(" * best mix: %s, hourly cost: %.3f, strategy: %s\n", mix, mix.Cost, mix.Strategy)
+		fmt.Printf(" * mix nodes per zone: %s\n", optimizer.FormatZones(mix.Zones))
+
+		c.writeCsv(cluster, "mix_"+problem.NodeGroup+".csv", func(f io.Writer) error {
+			return optimizer.WriteMix(f, mix)
+		})
+		c.writeCsv(cluster, "mix_placements_"+problem.NodeGroup+".csv", func(f io.Writer) error {
+			return optimizer.WritePlacements(f, problem, mix.Nodes)
+		})
+	}
+	return nil
+}
+
+// newProvider returns the CSV provider of the instances csv when a path is set,
+// and the provider configured by the environment otherwise
+func newProvider(instancesPath string) (pricing.Provider, error) {
+	if instancesPath != "" {
+		return pricing.NewCSVProvider(instancesPath, "")
+	}
+	return pricing.NewProvider()
+}
+
+// clusterRegion returns the region of the first node carrying a region label
+func clusterRegion(nodes []*v1.Node) string {
+	for _, node := range nodes {
+		if region, ok := util.GetRegion(node.Labels); ok {
+			return region
+		}
+	}
+	return ""
+}
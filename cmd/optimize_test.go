@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+)
+
+func TestOptimize(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+	instances := filepath.Join("testdata", "instances.csv")
+
+	t.Run("node groups", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Optimize(c, []string{"-instances", instances}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the solutions are ordered by cost, and the general purpose group keeps a
+		// node in both of its zones
+		assertCells(t, sink, "solutions_0.csv", "name", "m5.large", "r5.large", "m5.xlarge", "r5.xlarge")
+		assertCells(t, sink, "solutions_0.csv", "num_nodes", "2", "2", "2", "2")
+		assertCells(t, sink, "solutions_0.csv", "cost", "0.1200", "0.1580", "0.2420", "0.3180")
+		assertCells(t, sink, "zones_0.csv", "zone", "us-east-1a", "us-east-1b")
+		assertCells(t, sink, "zones_0.csv", "recommended_nodes", "1", "1")
+		// the daemonset pods are part of the per node overhead, and pods of the
+		// same size are placed in cache order
+		placed := cells(t, tableOf(t, sink, "all_placements_0.csv"), "owner_name")
+		sort.Strings(placed)
+		if !reflect.DeepEqual(placed, []string{"report", "web", "web"}) {
+			t.Errorf("expected the report and web pods to be placed, got %v", placed)
+		}
+		assertCells(t, sink, "unschedulable_0.csv", "pod_name")
+
+		// only r5.xlarge fits the 16Gi db pod
+		assertCells(t, sink, "solutions_1.csv", "name", "r5.xlarge")
+		assertCells(t, sink, "solutions_1.csv", "num_nodes", "1")
+		assertCells(t, sink, "all_placements_1.csv", "owner_name", "db")
+	})
+
+	t.Run("node group", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Optimize(c, []string{"-instances", instances, "-node-group", "1"}); err != nil {
+			t.Fatal(err)
+		}
+		assertCells(t, sink, "solutions_1.csv", "name", "r5.xlarge")
+		if _, ok := sink.Tables["solutions_0.csv"]; ok {
+			t.Error("expected only node group 1 to be optimized")
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Optimize(c, []string{"-instances", instances, "-hash", "-hash-secret", "secret"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// instance types are kept in every table, unlike the names of the pods
+		assertCells(t, sink, "solutions_1.csv", "name", "r5.xlarge")
+		assertCells(t, sink, "zones_1.csv", "recommendation", "r5.xlarge")
+		assertCells(t, sink, "all_placements_1.csv", "node_type", "r5.xlarge")
+		assertCells(t, sink, "all_placements_1.csv", "owner_name", c.anonymizer.Value("db"))
+		assertCells(t, sink, "all_placements_1.csv", "namespace", c.anonymizer.Value("data"))
+	})
+
+	t.Run("unknown strategy", func(t *testing.T) {
+		c, _ := testContext(h)
+		if err := Optimize(c, []string{"-instances", instances, "-strategy", "random"}); err == nil {
+			t.Error("expected an error for an unknown strategy")
+		}
+	})
+}
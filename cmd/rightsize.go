@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+	"github.com/mikeskali/PerfectScalePoc/rightsizing"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+// Rightsize recommends container requests and limits from the usage collected
+// from PROMETHEUS_SERVER_ENDPOINT, writing them along with the projected savings
+// to recommendations.csv. The savings are priced by the nodes the pods run on;
+// they are zero when the nodes cannot be priced. The usage can't be told apart
+// between clusters, so a single cluster must be selected.
+func Rightsize(c *Context, args []string) error {
+	defaults := rightsizing.DefaultOptions()
+	fs := c.flagSet("rightsize")
+	instancesPath := fs.String("instances", "", "path to an instances csv, overriding the pricing provider chosen by USE_CSV_PROVIDER")
+	cpuPercentile := fs.Float64("cpu-percentile", defaults.CPUPercentile, "CPU usage percentile the requests are based on: 50, 95, 99 or 100")
+	memoryPercentile := fs.Float64("memory-percentile", defaults.MemoryPercentile, "memory usage percentile the requests are based on: 50, 95, 99 or 100")
+	cpuHeadroom := fs.Float64("cpu-headroom", defaults.CPUHeadroom, "fraction added on top of the CPU usage percentile")
+	memoryHeadroom := fs.Float64("memory-headroom", defaults.MemoryHeadroom, "fraction added on top of the memory usage percentile")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	options := &rightsizing.Options{
+		CPUPercentile:    *cpuPercentile,
+		MemoryPercentile: *memoryPercentile,
+		CPUHeadroom:      *cpuHeadroom,
+		MemoryHeadroom:   *memoryHeadroom,
+		MinCPU:           defaults.MinCPU,
+		MinMemory:        defaults.MinMemory,
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	usage, err := c.clusterUsage()
+	if err != nil {
+		return err
+	}
+	if usage == nil {
+		return fmt.Errorf("no usage collected, set PROMETHEUS_SERVER_ENDPOINT")
+	}
+
+	provider, err := newProvider(*instancesPath)
+	if err != nil {
+		log.Printf("Failed creating pricing provider, savings are not priced: %s", err)
+		provider = nil
+	}
+
+	return c.forEachCluster(func(cluster *Cluster) error {
+		rightsizeCluster(c, cluster, usage, provider, options)
+		return nil
+	})
+}
+
+// rightsizeCluster writes the recommendations of the containers of the cluster
+// and prints their projected monthly savings, which are zero without a provider
+func rightsizeCluster(c *Context, cluster *Cluster, usage *metrics.Usage, provider pricing.Provider, options *rightsizing.Options) {
+	k8sCache := cluster.Cache
+	nodes := k8sCache.GetAllNodes()
+	var prices map[string]*cost.UnitPrice
+	if provider != nil {
+		_, node2group := nodegroup.Group(nodes)
+		report := cost.NewReport(nodes, node2group, provider, cost.DefaultOptions())
+		prices = cost.UnitPrices(report, nodes, cost.DefaultAllocationOptions())
+	}
+
+	recommendations := rightsizing.Recommend(k8sCache.GetAllPods(), workload.NewResolverFromCache(k8sCache), usage, prices, options)
+
+	var savings float64
+	for _, r := range recommendations {
+		savings += r.MonthlySavings
+	}
+	fmt.Println("===== Rightsizing ======")
+	fmt.Printf(" * containers: %d, projected monthly savings: %.2f\n", len(recommendations), savings)
+
+	c.writeCsv(cluster, "recommendations.csv", func(f io.Writer) error {
+		return rightsizing.WriteRecommendations(f, recommendations)
+	})
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/mikeskali/PerfectScalePoc/exporter"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/server"
+)
+
+// Serve serves the nodes, node groups, pods, workloads, costs and
+// recommendations of the cluster over HTTP until the server fails, along with
+// efficiency and waste gauges on /metrics which are recomputed as the pods and
+// nodes change. Costs are unavailable when no pricing provider can be created,
+// and usage and recommendations when no Prometheus server is configured. A
+// single cluster is served.
+func Serve(c *Context, args []string) error {
+	fs := c.flagSet("serve")
+	addr := fs.String("addr", ":9090", "address to listen on")
+	instancesPath := fs.String("instances", "", "path to an instances csv, overriding the pricing provider chosen by USE_CSV_PROVIDER")
+	metricsInterval := fs.Duration("metrics-interval", exporter.DefaultOptions().Interval, "min time between two recomputations of the /metrics gauges")
+	projectSavings := fs.Bool("metrics-savings", exporter.DefaultOptions().Optimize, "publish the projected savings of the optimizer on /metrics")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	clusters, err := c.clusters()
+	if err != nil {
+		return err
+	}
+	if len(clusters) != 1 {
+		return fmt.Errorf("serve serves a single cluster, %d are selected", len(clusters))
+	}
+	k8sCache := clusters[0].Cache
+
+	provider, err := newProvider(*instancesPath)
+	if err != nil {
+		log.Printf("Failed creating pricing provider, costs are not served: %s", err)
+		provider = nil
+	}
+
+	querier, err := newQuerier()
+	if err != nil {
+		log.Printf("Failed configuring usage queries, usage is not served: %s", err)
+		querier = nil
+	}
+
+	usageOptions, err := metrics.OptionsFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed parsing usage options: %s", err)
+	}
+
+	exporterOptions := exporter.DefaultOptions()
+	exporterOptions.Interval = *metricsInterval
+	exporterOptions.Optimize = *projectSavings
+	exp := exporter.NewExporter(k8sCache, provider, exporterOptions)
+	exp.Watch()
+	go exp.Run(make(chan struct{}))
+
+	srv := server.NewServer(k8sCache, provider, querier, usageOptions)
+	srv.Handle("/metrics", exp)
+
+	log.Printf("Serving on %s", *addr)
+	return http.ListenAndServe(*addr, srv)
+}
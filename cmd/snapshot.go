@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/mikeskali/PerfectScalePoc/snapshot"
+)
+
+// Snapshot writes every resource list of the cluster cache to a versioned,
+// gzipped JSON archive, recording the id of the cluster. With several clusters,
+// every cluster is written to its own archive, named after the cluster id.
+// Passing the archives to -snapshot, or setting SNAPSHOT_PATH to them, later runs
+// every command against the captured clusters instead of connecting to them.
+func Snapshot(c *Context, args []string) error {
+	fs := c.flagSet("snapshot")
+	out := fs.String("out", "snapshot.json.gz", "path of the snapshot archive to write")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	clusters, err := c.clusters()
+	if err != nil {
+		return err
+	}
+
+	for _, cluster := range clusters {
+		path := *out
+		if len(clusters) > 1 {
+			path = clusterPath(*out, cluster.ID)
+		}
+		s := snapshot.Capture(cluster.Cache, cluster.ID)
+		if err := snapshot.WriteFile(path, s); err != nil {
+			return fmt.Errorf("failed writing snapshot %s: %s", path, err)
+		}
+		log.Printf("Wrote snapshot of %d nodes and %d pods of cluster %s to %s", len(s.Nodes), len(s.Pods), cluster.ID, path)
+	}
+	return nil
+}
+
+// clusterPath inserts the cluster id before the extension of the archive path,
+// e.g. snapshot-prod.json.gz
+func clusterPath(path string, clusterID string) string {
+	ext := ".json.gz"
+	if !strings.HasSuffix(path, ext) {
+		ext = filepath.Ext(path)
+	}
+	return strings.TrimSuffix(path, ext) + "-" + clusterID + ext
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+)
+
+// newQuerier creates a querier of PROMETHEUS_SERVER_ENDPOINT which queries the
+// history older than PROMETHEUS_RETENTION from THANOS_QUERY_URL when
+// THANOS_ENABLED is set. It returns nil when no endpoint is configured.
+func newQuerier() (metrics.Querier, error) {
+	client := metrics.NewPrometheusClientFromEnv()
+	if client == nil {
+		return nil, nil
+	}
+	if !env.IsThanosEnabled() {
+		return client, nil
+	}
+	return metrics.NewThanosQuerierFromEnv(client)
+}
+
+// collectUsage collects the container usage from PROMETHEUS_SERVER_ENDPOINT over
+// USAGE_WINDOW. It returns nil when no endpoint is configured or the collection
+// fails.
+func collectUsage() *metrics.Usage {
+	querier, err := newQuerier()
+	if err != nil {
+		log.Printf("Failed configuring usage queries: %s", err)
+		return nil
+	}
+	if querier == nil {
+		return nil
+	}
+
+	options, err := metrics.OptionsFromEnv()
+	if err != nil {
+		log.Printf("Failed parsing usage options: %s", err)
+		return nil
+	}
+
+	endpoint := env.GetPrometheusServerEndpoint()
+	usage, err := metrics.NewCollector(querier, options).Collect()
+	if err != nil {
+		log.Printf("Failed collecting usage from %s: %s", endpoint, err)
+		return nil
+	}
+	log.Printf("Collected usage of %d pods from %s", len(usage.Pods), endpoint)
+	return usage
+}
+
+// clusterUsage collects the usage of the single selected cluster. The usage of
+// PROMETHEUS_SERVER_ENDPOINT is keyed by namespace and pod only, so it can't be
+// told apart between clusters: with several clusters, no usage is collected and
+// an error is returned. It returns nil when no endpoint is configured.
+func (c *Context) clusterUsage() (*metrics.Usage, error) {
+	if env.GetPrometheusServerEndpoint() == "" {
+		return nil, nil
+	}
+	clusters, err := c.clusters()
+	if err != nil {
+		return nil, err
+	}
+	if len(clusters) > 1 {
+		return nil, fmt.Errorf("the usage of %s can't be told apart between %d clusters, select a single cluster", env.PrometheusServerEndpointEnvVar, len(clusters))
+	}
+	return collectUsage(), nil
+}
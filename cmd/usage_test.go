@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/metrics/metricstest"
+	"github.com/mikeskali/PerfectScalePoc/output"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// usageServer serves a constant usage of the main container of the first web pod
+// over the last day, and points PROMETHEUS_SERVER_ENDPOINT at it until the
+// returned function is called
+func usageServer(t *testing.T) func() {
+	server := metricstest.NewServer()
+	end := time.Now().Add(-time.Hour).Truncate(time.Hour)
+	var cpu, memory []*util.Vector
+	for i := 0; i < 24; i++ {
+		ts := float64(end.Add(-time.Duration(i) * time.Hour).Unix())
+		cpu = append(cpu, &util.Vector{Timestamp: ts, Value: 0.25})
+		memory = append(memory, &util.Vector{Timestamp: ts, Value: 256 * 1024 * 1024})
+	}
+	labels := map[string]string{"namespace": "default", "pod": "web-5d8f-x1", "container": "main"}
+	server.Add(metrics.CPUQuery, labels, cpu)
+	server.Add(metrics.MemoryQuery, labels, memory)
+
+	if err := os.Setenv(env.PrometheusServerEndpointEnvVar, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.Unsetenv(env.PrometheusServerEndpointEnvVar)
+		server.Close()
+	}
+}
+
+func TestUsage(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+	defer usageServer(t)()
+
+	t.Run("single cluster", func(t *testing.T) {
+		c, sink := testContext(h)
+		if err := Export(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		pods := tableOf(t, sink, "pods.csv")
+		names := cells(t, pods, "pod_name")
+		usage := cells(t, pods, "usage_cpu_p50_milli_core")
+		for i, name := range names {
+			expected := ""
+			if name == "web-5d8f-x1" {
+				expected = "250"
+			}
+			if usage[i] != expected {
+				t.Errorf("%s: expected a cpu usage of %q, got %q", name, expected, usage[i])
+			}
+		}
+	})
+
+	// the usage of a pod can't be told apart between clusters
+	twoClusters := func() (*Context, *output.MemorySink) {
+		sink := output.NewMemorySink()
+		return &Context{
+			Clusters: []*Cluster{{ID: "prod", Cache: h.Cache}, {ID: "staging", Cache: h.Cache}},
+			Sink:     sink,
+		}, sink
+	}
+
+	t.Run("several clusters", func(t *testing.T) {
+		c, sink := twoClusters()
+		if err := Export(c, nil); err != nil {
+			t.Fatal(err)
+		}
+		for i, usage := range cells(t, tableOf(t, sink, "pods.csv"), "usage_cpu_p50_milli_core") {
+			if usage != "" {
+				t.Errorf("row %d: expected no usage, got %q", i, usage)
+			}
+		}
+	})
+
+	t.Run("rightsize several clusters", func(t *testing.T) {
+		c, _ := twoClusters()
+		err := Rightsize(c, nil)
+		if err == nil || !strings.Contains(err.Error(), "select a single cluster") {
+			t.Errorf("expected an error for several clusters, got %v", err)
+		}
+	})
+
+	t.Run("rightsize percentile", func(t *testing.T) {
+		c, _ := testContext(h)
+		err := Rightsize(c, []string{"-cpu-percentile", "90"})
+		if err == nil || !strings.Contains(err.Error(), "unsupported CPU percentile 90") {
+			t.Errorf("expected an error for the percentile, got %v", err)
+		}
+	})
+}
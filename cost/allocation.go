@@ -0,0 +1,267 @@
+package cost
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+// AllocationOptions configures how the price of a node is split between its pods
+type AllocationOptions struct {
+	// CPUWeight and MemoryWeight are the relative shares of the node price
+	// attributed to CPU and memory. Each share is split between the pods
+	// proportionally to their requests of the node allocatable resources.
+	CPUWeight    float64
+	MemoryWeight float64
+}
+
+// DefaultAllocationOptions splits the node price evenly between CPU and memory
+func DefaultAllocationOptions() *AllocationOptions {
+	return &AllocationOptions{
+		CPUWeight:    0.5,
+		MemoryWeight: 0.5,
+	}
+}
+
+// Allocation is the cost of the pods of a single workload within a namespace
+type Allocation struct {
+	Namespace     string  `json:"namespace"`
+	OwnerKind     string  `json:"ownerKind"`
+	OwnerName     string  `json:"ownerName"`
+	Pods          int     `json:"pods"`
+	CPURequest    int64   `json:"cpuRequest"`
+	MemoryRequest int64   `json:"memoryRequest"`
+	CPUCost       float64 `json:"cpuCost"`
+	MemoryCost    float64 `json:"memoryCost"`
+	Hourly        float64 `json:"hourly"`
+	Monthly       float64 `json:"monthly"`
+}
+
+// IdleCost is the cost of the node capacity of a node group which is not
+// requested by any pod
+type IdleCost struct {
+	NodeGroup  string  `json:"nodeGroup"`
+	CPUCost    float64 `json:"cpuCost"`
+	MemoryCost float64 `json:"memoryCost"`
+	Hourly     float64 `json:"hourly"`
+	Monthly    float64 `json:"monthly"`
+}
+
+// AllocationReport splits the cost of the priced nodes between the namespaces
+// and owners of their pods, with the unrequested capacity reported as idle.
+type AllocationReport struct {
+	Allocations []*Allocation `json:"allocations"`
+	Idle        []*IdleCost   `json:"idle"`
+	Hourly      float64       `json:"hourly"`
+	IdleHourly  float64       `json:"idleHourly"`
+}
+
+// Allocate splits the hourly price of every priced node of the report between
+// the pods running on it. The price is divided into a CPU and a memory share by
+// the option weights, and each pod is charged the fraction of the node
+// allocatable CPU and memory it requests. Whatever is left is idle. When the
+// requests exceed the allocatable resources, the shares are split between the
+// pods by their requests and nothing is idle. Pods are allocated to the workload
+// they resolve to.
+func Allocate(report *Report, nodes []*v1.Node, pods []*v1.Pod, resolver *workload.Resolver, options *AllocationOptions) *AllocationReport {
+	cpuShare, memShare := options.shares()
+
+	priced := make(map[string]*NodeCost, len(report.Nodes))
+	for _, nc := range report.Nodes {
+		priced[nc.Name] = nc
+	}
+	allocatable := make(map[string]v1.ResourceList, len(nodes))
+	for _, node := range nodes {
+		allocatable[node.Name] = node.Status.Allocatable
+	}
+
+	podsByNode := make(map[string][]*v1.Pod)
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if _, ok := priced[pod.Spec.NodeName]; !ok {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+	}
+
+	allocations := make(map[string]*Allocation)
+	idle := make(map[string]*IdleCost)
+	result := &AllocationReport{}
+
+	for _, nc := range report.Nodes {
+		nodePods := podsByNode[nc.Name]
+		cpuCost := nc.Hourly * cpuShare
+		memCost := nc.Hourly * memShare
+
+		var requested []requests
+		var totalCPU, totalMem int64
+		for _, pod := range nodePods {
+			r := podRequests(pod)
+			requested = append(requested, r)
+			totalCPU += r.cpu
+			totalMem += r.memory
+		}
+
+		// the pods are charged the fraction of the larger of the allocatable and
+		// requested resources, so the charges never exceed the node price
+		capacity := allocatable[nc.Name]
+		cpuCapacity := maxInt64(capacity.Cpu().MilliValue(), totalCPU)
+		memCapacity := maxInt64(capacity.Memory().Value(), totalMem)
+
+		var allocatedCPU, allocatedMem float64
+		for i, pod := range nodePods {
+			r := requested[i]
+			podCPUCost := fraction(r.cpu, cpuCapacity) * cpuCost
+			podMemCost := fraction(r.memory, memCapacity) * memCost
+
+			w := resolver.Resolve(pod)
+			a, ok := allocations[w.String()]
+			if !ok {
+				a = &Allocation{Namespace: w.Namespace, OwnerKind: w.Kind, OwnerName: w.Name}
+				allocations[w.String()] = a
+			}
+			a.Pods++
+			a.CPURequest += r.cpu
+			a.MemoryRequest += r.memory
+			a.CPUCost += podCPUCost
+			a.MemoryCost += podMemCost
+
+			allocatedCPU += podCPUCost
+			allocatedMem += podMemCost
+		}
+
+		ic, ok := idle[nc.NodeGroup]
+		if !ok {
+			ic = &IdleCost{NodeGroup: nc.NodeGroup}
+			idle[nc.NodeGroup] = ic
+		}
+		ic.CPUCost += cpuCost - allocatedCPU
+		ic.MemoryCost += memCost - allocatedMem
+	}
+
+	for _, a := range allocations {
+		a.Hourly = a.CPUCost + a.MemoryCost
+		a.Monthly = a.Hourly * util.HoursPerMonth
+		result.Hourly += a.Hourly
+		result.Allocations = append(result.Allocations, a)
+	}
+	for _, ic := range idle {
+		ic.Hourly = ic.CPUCost + ic.MemoryCost
+		ic.Monthly = ic.Hourly * util.HoursPerMonth
+		result.IdleHourly += ic.Hourly
+		result.Idle = append(result.Idle, ic)
+	}
+
+	sort.Slice(result.Allocations, func(i, j int) bool {
+		a, b := result.Allocations[i], result.Allocations[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.OwnerKind != b.OwnerKind {
+			return a.OwnerKind < b.OwnerKind
+		}
+		return a.OwnerName < b.OwnerName
+	})
+	sort.Slice(result.Idle, func(i, j int) bool {
+		return result.Idle[i].NodeGroup < result.Idle[j].NodeGroup
+	})
+
+	return result
+}
+
+// UnitPrice is the hourly price of a CPU core and of a byte of memory of a node
+type UnitPrice struct {
+	CPU    float64 `json:"cpu"`
+	Memory float64 `json:"memory"`
+}
+
+// UnitPrices returns the unit prices of every priced node of the report, keyed by
+// node name. The node price is split into a CPU and a memory share by the option
+// weights, each divided by the node allocatable resources.
+func UnitPrices(report *Report, nodes []*v1.Node, options *AllocationOptions) map[string]*UnitPrice {
+	cpuShare, memShare := options.shares()
+
+	allocatable := make(map[string]v1.ResourceList, len(nodes))
+	for _, node := range nodes {
+		allocatable[node.Name] = node.Status.Allocatable
+	}
+
+	prices := make(map[string]*UnitPrice, len(report.Nodes))
+	for _, nc := range report.Nodes {
+		capacity := allocatable[nc.Name]
+		prices[nc.Name] = &UnitPrice{
+			CPU:    nc.Hourly * cpuShare * fraction(1000, capacity.Cpu().MilliValue()),
+			Memory: nc.Hourly * memShare * fraction(1, capacity.Memory().Value()),
+		}
+	}
+	return prices
+}
+
+// shares returns the normalized CPU and memory weights, which are even for nil
+// options or zero weights
+func (o *AllocationOptions) shares() (float64, float64) {
+	if o == nil {
+		return 0.5, 0.5
+	}
+	total := o.CPUWeight + o.MemoryWeight
+	if total <= 0 {
+		return 0.5, 0.5
+	}
+	return o.CPUWeight / total, o.MemoryWeight / total
+}
+
+// ByNamespace sums the allocations of every namespace, with the owner fields left
+// empty
+func (r *AllocationReport) ByNamespace() []*Allocation {
+	namespaces := make(map[string]*Allocation)
+	var result []*Allocation
+	for _, a := range r.Allocations {
+		ns, ok := namespaces[a.Namespace]
+		if !ok {
+			ns = &Allocation{Namespace: a.Namespace}
+			namespaces[a.Namespace] = ns
+			result = append(result, ns)
+		}
+		ns.Pods += a.Pods
+		ns.CPURequest += a.CPURequest
+		ns.MemoryRequest += a.MemoryRequest
+		ns.CPUCost += a.CPUCost
+		ns.MemoryCost += a.MemoryCost
+		ns.Hourly += a.Hourly
+		ns.Monthly += a.Monthly
+	}
+	return result
+}
+
+type requests struct {
+	cpu    int64
+	memory int64
+}
+
+func podRequests(pod *v1.Pod) requests {
+	var r requests
+	for _, container := range pod.Spec.Containers {
+		r.cpu += container.Resources.Requests.Cpu().MilliValue()
+		r.memory += container.Resources.Requests.Memory().Value()
+	}
+	return r
+}
+
+func fraction(used int64, capacity int64) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return float64(used) / float64(capacity)
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
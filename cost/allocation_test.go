@@ -0,0 +1,158 @@
+package cost
+
+import (
+	"math"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+const bytesPerGiB = 1024 * 1024 * 1024
+
+// allocationFixture is a priced m5.large node of 2 cores and 8GiB at 0.1 an hour
+// in g1, and a node of an unknown instance type in g2. The priced node runs a
+// pod of the web Deployment, through its ReplicaSet, a pod of the db StatefulSet
+// and a completed job pod; the unpriced node runs a pod of web.
+func allocationFixture() (*Report, []*v1.Node, []*v1.Pod, *workload.Resolver) {
+	nodes := []*v1.Node{
+		newNode("node-a", "m5.large", "us-east-1", 2000, 8),
+		newNode("node-b", "x9.unknown", "us-east-1", 4000, 16),
+	}
+	report := NewReport(nodes, map[string]string{"node-a": "g1", "node-b": "g2"}, newStubProvider(), nil)
+
+	web := clustercachetest.Deployment("default", "web", 2)
+	webRS := clustercachetest.ReplicaSet("default", "web-5d8f", clustercachetest.OwnerReference("Deployment", web))
+	db := clustercachetest.StatefulSet("data", "db", 1)
+	done := clustercachetest.Pod("default", "report", "node-a", "1", "4Gi", nil)
+	done.Status.Phase = v1.PodSucceeded
+	pods := []*v1.Pod{
+		clustercachetest.Pod("default", "web-5d8f-x1", "node-a", "500m", "2Gi", clustercachetest.OwnerReference("ReplicaSet", webRS)),
+		clustercachetest.Pod("default", "web-5d8f-x2", "node-b", "500m", "2Gi", clustercachetest.OwnerReference("ReplicaSet", webRS)),
+		clustercachetest.Pod("data", "db-0", "node-a", "1", "1Gi", clustercachetest.OwnerReference("StatefulSet", db)),
+		done,
+	}
+	return report, nodes, pods, workload.NewResolver([]*appsv1.ReplicaSet{webRS}, nil)
+}
+
+func TestAllocate(t *testing.T) {
+	report, nodes, pods, resolver := allocationFixture()
+
+	// three quarters of the node price are CPU, a quarter memory
+	allocations := Allocate(report, nodes, pods, resolver, &AllocationOptions{CPUWeight: 3, MemoryWeight: 1})
+
+	expected := []Allocation{
+		{
+			Namespace: "data", OwnerKind: "StatefulSet", OwnerName: "db", Pods: 1,
+			CPURequest: 1000, MemoryRequest: bytesPerGiB,
+			CPUCost: 0.075 / 2, MemoryCost: 0.025 / 8,
+		},
+		// only the pod of the priced node is charged
+		{
+			Namespace: "default", OwnerKind: "Deployment", OwnerName: "web", Pods: 1,
+			CPURequest: 500, MemoryRequest: 2 * bytesPerGiB,
+			CPUCost: 0.075 / 4, MemoryCost: 0.025 / 4,
+		},
+	}
+	if len(allocations.Allocations) != len(expected) {
+		t.Fatalf("expected %d allocations, got %d", len(expected), len(allocations.Allocations))
+	}
+	for i, e := range expected {
+		a := allocations.Allocations[i]
+		e.Hourly = e.CPUCost + e.MemoryCost
+		e.Monthly = e.Hourly * util.HoursPerMonth
+		if a.Namespace != e.Namespace || a.OwnerKind != e.OwnerKind || a.OwnerName != e.OwnerName || a.Pods != e.Pods ||
+			a.CPURequest != e.CPURequest || a.MemoryRequest != e.MemoryRequest || !near(a.CPUCost, e.CPUCost) ||
+			!near(a.MemoryCost, e.MemoryCost) || !near(a.Hourly, e.Hourly) || !near(a.Monthly, e.Monthly) {
+			t.Errorf("expected the allocation %+v, got %+v", e, *a)
+		}
+	}
+
+	// the unrequested capacity of the priced node is idle, the unpriced node has
+	// no idle cost
+	if len(allocations.Idle) != 1 {
+		t.Fatalf("expected the idle cost of 1 node group, got %d", len(allocations.Idle))
+	}
+	idle := allocations.Idle[0]
+	if idle.NodeGroup != "g1" || !near(idle.CPUCost, 0.075/4) || !near(idle.MemoryCost, 0.025*5/8) ||
+		!near(idle.Hourly, 0.075/4+0.025*5/8) || !near(idle.Monthly, idle.Hourly*util.HoursPerMonth) {
+		t.Errorf("unexpected idle cost %+v", *idle)
+	}
+
+	// the allocations and idle cost add up to the price of the priced node
+	if !near(allocations.Hourly+allocations.IdleHourly, report.Hourly) {
+		t.Errorf("expected %v allocated and idle, got %v and %v", report.Hourly, allocations.Hourly, allocations.IdleHourly)
+	}
+
+	namespaces := allocations.ByNamespace()
+	if len(namespaces) != 2 || namespaces[0].Namespace != "data" || namespaces[1].Namespace != "default" ||
+		namespaces[1].OwnerName != "" || !near(namespaces[1].Hourly, expected[1].CPUCost+expected[1].MemoryCost) {
+		t.Errorf("unexpected namespace allocations %+v and %+v", *namespaces[0], *namespaces[1])
+	}
+}
+
+func TestAllocateOvercommitted(t *testing.T) {
+	report, nodes, _, resolver := allocationFixture()
+	// the pods request more CPU than allocatable and fit the memory
+	pods := []*v1.Pod{
+		clustercachetest.Pod("default", "a", "node-a", "3", "2Gi", nil),
+		clustercachetest.Pod("default", "b", "node-a", "1", "2Gi", nil),
+	}
+
+	allocations := Allocate(report, nodes, pods, resolver, nil)
+
+	// the CPU share is split by the requests, the memory share by allocatable
+	a, b := allocations.Allocations[0], allocations.Allocations[1]
+	if !near(a.CPUCost, 0.05*3/4) || !near(b.CPUCost, 0.05/4) || !near(a.MemoryCost, 0.05/4) || !near(b.MemoryCost, 0.05/4) {
+		t.Errorf("unexpected allocations %+v and %+v", *a, *b)
+	}
+	idle := allocations.Idle[0]
+	if !near(idle.CPUCost, 0) || !near(idle.MemoryCost, 0.05/2) {
+		t.Errorf("expected only idle memory, got %+v", *idle)
+	}
+}
+
+func TestUnitPrices(t *testing.T) {
+	report, nodes, _, _ := allocationFixture()
+
+	prices := UnitPrices(report, nodes, &AllocationOptions{CPUWeight: 3, MemoryWeight: 1})
+
+	if len(prices) != 1 {
+		t.Fatalf("expected the prices of the priced node only, got %d", len(prices))
+	}
+	price := prices["node-a"]
+	// 0.075 an hour for 2 cores and 0.025 an hour for 8GiB
+	if price == nil || !near(price.CPU, 0.0375) || !near(price.Memory*bytesPerGiB, 0.025/8) {
+		t.Errorf("unexpected unit prices %+v", price)
+	}
+}
+
+func TestShares(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *AllocationOptions
+		cpu     float64
+		memory  float64
+	}{
+		{name: "nil", options: nil, cpu: 0.5, memory: 0.5},
+		{name: "default", options: DefaultAllocationOptions(), cpu: 0.5, memory: 0.5},
+		{name: "weights", options: &AllocationOptions{CPUWeight: 3, MemoryWeight: 1}, cpu: 0.75, memory: 0.25},
+		{name: "cpu only", options: &AllocationOptions{CPUWeight: 1}, cpu: 1, memory: 0},
+		{name: "zero weights", options: &AllocationOptions{}, cpu: 0.5, memory: 0.5},
+	}
+
+	for _, test := range tests {
+		cpu, memory := test.options.shares()
+		if !near(cpu, test.cpu) || !near(memory, test.memory) {
+			t.Errorf("%s: expected %v and %v, got %v and %v", test.name, test.cpu, test.memory, cpu, memory)
+		}
+	}
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,137 @@
+package cost
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// WriteNodes writes the cost of every priced node, one row per node
+func WriteNodes(w io.Writer, report *Report) error {
+	records := [][]string{
+		{"node_name", "node_group", "instance_type", "region", "os", "purchase_option", "hourly_cost", "monthly_cost"},
+	}
+
+	for _, nc := range report.Nodes {
+		records = append(records, []string{
+			nc.Name,
+			nc.NodeGroup,
+			nc.InstanceType,
+			nc.Region,
+			nc.OperatingSystem,
+			nc.PurchaseOption,
+			formatCost(nc.Hourly),
+			formatCost(nc.Monthly),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteUnknown writes the nodes which could not be priced, along with the reason
+func WriteUnknown(w io.Writer, report *Report) error {
+	records := [][]string{
+		{"node_name", "node_group", "instance_type", "region", "os", "purchase_option", "reason"},
+	}
+
+	for _, nc := range report.Unknown {
+		records = append(records, []string{
+			nc.Name,
+			nc.NodeGroup,
+			nc.InstanceType,
+			nc.Region,
+			nc.OperatingSystem,
+			nc.PurchaseOption,
+			nc.Reason,
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteNodeGroups writes the cost of every node group, one row per group,
+// followed by a cluster row holding the totals
+func WriteNodeGroups(w io.Writer, report *Report) error {
+	records := [][]string{
+		{"node_group", "num_nodes", "unknown_nodes", "hourly_cost", "monthly_cost"},
+	}
+
+	nodes, unknown := 0, 0
+	for _, gc := range report.NodeGroups {
+		nodes += gc.Nodes
+		unknown += gc.Unknown
+		records = append(records, []string{
+			gc.NodeGroup,
+			strconv.Itoa(gc.Nodes),
+			strconv.Itoa(gc.Unknown),
+			formatCost(gc.Hourly),
+			formatCost(gc.Monthly),
+		})
+	}
+
+	records = append(records, []string{
+		"cluster",
+		strconv.Itoa(nodes),
+		strconv.Itoa(unknown),
+		formatCost(report.Hourly),
+		formatCost(report.Monthly),
+	})
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteAllocations writes the cost of every namespace and owner, one row per
+// owner. Memory requests are written in bytes.
+func WriteAllocations(w io.Writer, allocations []*Allocation) error {
+	records := [][]string{
+		{"namespace", "owner_kind", "owner_name", "num_pods", "req_cpu_milli_core", "req_mem_byte", "cpu_cost", "memory_cost", "hourly_cost", "monthly_cost"},
+	}
+
+	for _, a := range allocations {
+		records = append(records, []string{
+			a.Namespace,
+			a.OwnerKind,
+			a.OwnerName,
+			strconv.Itoa(a.Pods),
+			strconv.FormatInt(a.CPURequest, 10),
+			strconv.FormatInt(a.MemoryRequest, 10),
+			formatCost(a.CPUCost),
+			formatCost(a.MemoryCost),
+			formatCost(a.Hourly),
+			formatCost(a.Monthly),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteIdle writes the idle cost of every node group, one row per group
+func WriteIdle(w io.Writer, report *AllocationReport) error {
+	records := [][]string{
+		{"node_group", "cpu_cost", "memory_cost", "hourly_cost", "monthly_cost"},
+	}
+
+	for _, ic := range report.Idle {
+		records = append(records, []string{
+			ic.NodeGroup,
+			formatCost(ic.CPUCost),
+			formatCost(ic.MemoryCost),
+			formatCost(ic.Hourly),
+			formatCost(ic.Monthly),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteJSON writes the value as indented json
+func WriteJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func formatCost(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', 4, 64)
+}
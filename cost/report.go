@@ -0,0 +1,248 @@
+package cost
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// spotLabels are the node labels set by the common provisioners on spot nodes,
+// mapped to their spot value
+var spotLabels = map[string]string{
+	"eks.amazonaws.com/capacityType": "SPOT",
+	"karpenter.sh/capacity-type":     "spot",
+	"node.kubernetes.io/lifecycle":   "spot",
+}
+
+// NodeCost is the price of a single node. Reason is set, and the cost is zero,
+// when the node could not be priced.
+type NodeCost struct {
+	Name            string  `json:"name"`
+	NodeGroup       string  `json:"nodeGroup"`
+	InstanceType    string  `json:"instanceType"`
+	Region          string  `json:"region"`
+	OperatingSystem string  `json:"operatingSystem"`
+	PurchaseOption  string  `json:"purchaseOption"`
+	Hourly          float64 `json:"hourly"`
+	Monthly         float64 `json:"monthly"`
+	Reason          string  `json:"reason,omitempty"`
+}
+
+// GroupCost is the total price of the priced nodes of a node group
+type GroupCost struct {
+	NodeGroup string  `json:"nodeGroup"`
+	Nodes     int     `json:"nodes"`
+	Unknown   int     `json:"unknown"`
+	Hourly    float64 `json:"hourly"`
+	Monthly   float64 `json:"monthly"`
+}
+
+// Report is the current cost of the cluster nodes. Nodes which could not be
+// priced are listed in Unknown and are not part of any of the totals.
+type Report struct {
+	Nodes      []*NodeCost  `json:"nodes"`
+	Unknown    []*NodeCost  `json:"unknown"`
+	NodeGroups []*GroupCost `json:"nodeGroups"`
+	Hourly     float64      `json:"hourly"`
+	Monthly    float64      `json:"monthly"`
+}
+
+// Options configures how nodes are priced
+type Options struct {
+	// PurchaseOption is used for the nodes which are not labeled as spot nodes
+	PurchaseOption string
+
+	// DefaultOperatingSystem is used for the nodes without an os label
+	DefaultOperatingSystem string
+}
+
+// DefaultOptions prices nodes as on-demand linux nodes unless labeled otherwise
+func DefaultOptions() *Options {
+	return &Options{
+		PurchaseOption:         pricing.OnDemand,
+		DefaultOperatingSystem: pricing.Linux,
+	}
+}
+
+// priceRetryInterval is how long a failure to fetch prices is kept before the
+// prices are fetched again
+const priceRetryInterval = time.Minute
+
+// Pricer prices single nodes by their instance type, region and operating system
+// labels. The prices of every region, operating system and purchase option are
+// fetched once and kept for the lifetime of the pricer, while failures are
+// retried after priceRetryInterval.
+type Pricer struct {
+	provider      pricing.Provider
+	options       *Options
+	retryInterval time.Duration
+
+	lock     sync.Mutex
+	indexes  map[pricing.Key]map[string]*pricing.InstanceType
+	failures map[pricing.Key]*failure
+}
+
+// failure is a failure to fetch prices and the time it happened
+type failure struct {
+	err error
+	at  time.Time
+}
+
+// NewPricer creates a pricer of the provider prices
+func NewPricer(provider pricing.Provider, options *Options) *Pricer {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	return &Pricer{
+		provider:      provider,
+		options:       options,
+		retryInterval: priceRetryInterval,
+		indexes:       make(map[pricing.Key]map[string]*pricing.InstanceType),
+		failures:      make(map[pricing.Key]*failure),
+	}
+}
+
+// Price prices the node of the node group. Reason is set on the returned cost
+// when the node could not be priced.
+func (p *Pricer) Price(node *v1.Node, nodeGroup string) *NodeCost {
+	nc := &NodeCost{
+		Name:      node.Name,
+		NodeGroup: nodeGroup,
+	}
+	nc.InstanceType, _ = util.GetInstanceType(node.Labels)
+	nc.Region, _ = util.GetRegion(node.Labels)
+	nc.OperatingSystem = operatingSystem(node, p.options.DefaultOperatingSystem)
+	nc.PurchaseOption = purchaseOption(node, p.options.PurchaseOption)
+	nc.Reason = price(nc, p.lookup)
+	return nc
+}
+
+// lookup returns the index of the prices of the key. The prices are fetched
+// outside of the lock, so a slow provider doesn't hold up the lookups of the
+// prices already fetched.
+func (p *Pricer) lookup(key pricing.Key) (map[string]*pricing.InstanceType, error) {
+	p.lock.Lock()
+	index, ok := p.indexes[key]
+	f := p.failures[key]
+	p.lock.Unlock()
+	if ok {
+		return index, nil
+	}
+	if f != nil && time.Since(f.at) < p.retryInterval {
+		return nil, f.err
+	}
+
+	types, err := p.provider.InstanceTypes(key)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err != nil {
+		klog.Warningf("Failed pricing nodes of %s: %s", key, err)
+		p.failures[key] = &failure{err: err, at: time.Now()}
+		return nil, err
+	}
+	index = pricing.Index(types)
+	p.indexes[key] = index
+	delete(p.failures, key)
+	return index, nil
+}
+
+// NewReport prices each of the nodes by its instance type, region and operating
+// system labels. node2group maps a node name to its node group id; nodes without
+// a group are reported under an empty group.
+func NewReport(nodes []*v1.Node, node2group map[string]string, provider pricing.Provider, options *Options) *Report {
+	pricer := NewPricer(provider, options)
+
+	report := &Report{}
+	groups := make(map[string]*GroupCost)
+	for _, node := range nodes {
+		nc := pricer.Price(node, node2group[node.Name])
+
+		group, ok := groups[nc.NodeGroup]
+		if !ok {
+			group = &GroupCost{NodeGroup: nc.NodeGroup}
+			groups[nc.NodeGroup] = group
+		}
+		group.Nodes++
+
+		if nc.Reason != "" {
+			group.Unknown++
+			report.Unknown = append(report.Unknown, nc)
+			continue
+		}
+
+		group.Hourly += nc.Hourly
+		group.Monthly += nc.Monthly
+		report.Hourly += nc.Hourly
+		report.Monthly += nc.Monthly
+		report.Nodes = append(report.Nodes, nc)
+	}
+
+	for _, group := range groups {
+		report.NodeGroups = append(report.NodeGroups, group)
+	}
+	sort.Slice(report.NodeGroups, func(i, j int) bool {
+		return report.NodeGroups[i].NodeGroup < report.NodeGroups[j].NodeGroup
+	})
+	sortNodes(report.Nodes)
+	sortNodes(report.Unknown)
+
+	return report
+}
+
+// price sets the cost of the node, returning the reason it couldn't be priced
+func price(nc *NodeCost, lookup func(pricing.Key) (map[string]*pricing.InstanceType, error)) string {
+	if nc.InstanceType == "" {
+		return "missing instance type label"
+	}
+
+	key := pricing.Key{
+		Region:          nc.Region,
+		OperatingSystem: nc.OperatingSystem,
+		PurchaseOption:  nc.PurchaseOption,
+	}
+	index, err := lookup(key)
+	if err != nil {
+		return fmt.Sprintf("no prices for %s", key)
+	}
+
+	t, ok := index[nc.InstanceType]
+	if !ok {
+		return fmt.Sprintf("unknown instance type for %s", key)
+	}
+
+	nc.Hourly = t.Hourly
+	nc.Monthly = t.Hourly * util.HoursPerMonth
+	return ""
+}
+
+func operatingSystem(node *v1.Node, defaultOS string) string {
+	os, ok := util.GetOperatingSystem(node.Labels)
+	if !ok {
+		return defaultOS
+	}
+	return strings.ToLower(os)
+}
+
+func purchaseOption(node *v1.Node, defaultOption string) string {
+	for label, value := range spotLabels {
+		if node.Labels[label] == value {
+			return pricing.Spot
+		}
+	}
+	return defaultOption
+}
+
+func sortNodes(nodes []*NodeCost) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+}
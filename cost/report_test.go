@@ -0,0 +1,220 @@
+package cost
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// stubProvider prices the instance types of its keys, counting the calls it
+// receives. The calls fail with err when set, and the calls of the blocked
+// region wait for unblock once they signal started.
+type stubProvider struct {
+	types map[pricing.Key][]*pricing.InstanceType
+
+	lock    sync.Mutex
+	err     error
+	calls   int
+	blocked string
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (p *stubProvider) InstanceTypes(key pricing.Key) ([]*pricing.InstanceType, error) {
+	p.lock.Lock()
+	p.calls++
+	err := p.err
+	p.lock.Unlock()
+
+	if key.Region == p.blocked {
+		close(p.started)
+		<-p.unblock
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.types[key], nil
+}
+
+func (p *stubProvider) setErr(err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.err = err
+}
+
+func (p *stubProvider) callCount() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.calls
+}
+
+func onDemand(region string) pricing.Key {
+	return pricing.Key{Region: region, OperatingSystem: pricing.Linux, PurchaseOption: pricing.OnDemand}
+}
+
+// newStubProvider prices m5.large at 0.1 and m5.xlarge at 0.2 an hour in
+// us-east-1 and eu-west-1
+func newStubProvider() *stubProvider {
+	types := []*pricing.InstanceType{
+		{Name: "m5.large", VCPUs: 2, Memory: 8, Hourly: 0.1},
+		{Name: "m5.xlarge", VCPUs: 4, Memory: 16, Hourly: 0.2},
+	}
+	return &stubProvider{
+		types: map[pricing.Key][]*pricing.InstanceType{
+			onDemand("us-east-1"): types,
+			onDemand("eu-west-1"): types,
+		},
+	}
+}
+
+// newNode returns a node of the instance type in the region with the allocatable
+// CPU in millicores and memory in GiB
+func newNode(name string, instanceType string, region string, cpu int64, memory int64) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"node.kubernetes.io/instance-type": instanceType,
+				"topology.kubernetes.io/region":    region,
+			},
+		},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    *resource.NewMilliQuantity(cpu, resource.DecimalSI),
+				v1.ResourceMemory: *resource.NewQuantity(memory*1024*1024*1024, resource.BinarySI),
+			},
+		},
+	}
+}
+
+func TestPricerRetry(t *testing.T) {
+	provider := newStubProvider()
+	provider.setErr(errors.New("throttled"))
+	p := NewPricer(provider, nil)
+	node := newNode("node-a", "m5.large", "us-east-1", 2000, 8)
+
+	if nc := p.Price(node, ""); nc.Reason != "no prices for us-east-1/linux/on-demand" {
+		t.Errorf("expected no prices, got %q", nc.Reason)
+	}
+	// the failure is kept until the retry interval passed
+	provider.setErr(nil)
+	if nc := p.Price(node, ""); nc.Reason == "" {
+		t.Error("expected the failure to be kept within the retry interval")
+	}
+	if calls := provider.callCount(); calls != 1 {
+		t.Errorf("expected 1 call within the retry interval, got %d", calls)
+	}
+
+	p.retryInterval = 0
+	if nc := p.Price(node, ""); nc.Reason != "" || nc.Hourly != 0.1 {
+		t.Errorf("expected the node to be priced once retried, got %+v", nc)
+	}
+	// the prices are kept once fetched
+	p.Price(node, "")
+	if calls := provider.callCount(); calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestPricerLock(t *testing.T) {
+	provider := newStubProvider()
+	provider.blocked = "eu-west-1"
+	provider.started = make(chan struct{})
+	provider.unblock = make(chan struct{})
+	p := NewPricer(provider, nil)
+	p.Price(newNode("node-a", "m5.large", "us-east-1", 2000, 8), "")
+
+	done := make(chan *NodeCost)
+	go func() {
+		done <- p.Price(newNode("node-b", "m5.xlarge", "eu-west-1", 4000, 16), "")
+	}()
+	<-provider.started
+
+	// the prices fetched already are returned while the provider is busy
+	priced := make(chan *NodeCost)
+	go func() {
+		priced <- p.Price(newNode("node-c", "m5.xlarge", "us-east-1", 4000, 16), "")
+	}()
+	select {
+	case nc := <-priced:
+		if nc.Hourly != 0.2 {
+			t.Errorf("expected an hourly price of 0.2, got %v", nc.Hourly)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the known prices to be returned while the provider is busy")
+	}
+
+	close(provider.unblock)
+	if nc := <-done; nc.Hourly != 0.2 {
+		t.Errorf("expected an hourly price of 0.2, got %v", nc.Hourly)
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	spot := newNode("node-e", "m5.large", "us-east-1", 2000, 8)
+	spot.Labels["karpenter.sh/capacity-type"] = "spot"
+	unlabeled := newNode("node-d", "", "us-east-1", 2000, 8)
+	delete(unlabeled.Labels, "node.kubernetes.io/instance-type")
+	nodes := []*v1.Node{
+		newNode("node-b", "m5.xlarge", "us-east-1", 4000, 16),
+		newNode("node-a", "m5.large", "us-east-1", 2000, 8),
+		newNode("node-c", "x9.unknown", "us-east-1", 2000, 8),
+		unlabeled,
+		spot,
+	}
+	node2group := map[string]string{"node-a": "g1", "node-b": "g1", "node-c": "g2", "node-e": "g2"}
+
+	report := NewReport(nodes, node2group, newStubProvider(), nil)
+
+	if math.Abs(report.Hourly-0.3) > 1e-9 || math.Abs(report.Monthly-0.3*util.HoursPerMonth) > 1e-9 {
+		t.Errorf("expected 0.3 an hour and %v a month, got %v and %v", 0.3*util.HoursPerMonth, report.Hourly, report.Monthly)
+	}
+
+	var priced []string
+	for _, nc := range report.Nodes {
+		priced = append(priced, nc.Name)
+	}
+	if expected := []string{"node-a", "node-b"}; !reflect.DeepEqual(priced, expected) {
+		t.Errorf("expected the priced nodes %v, got %v", expected, priced)
+	}
+
+	reasons := make(map[string]string)
+	for _, nc := range report.Unknown {
+		reasons[nc.Name] = nc.Reason
+	}
+	expectedReasons := map[string]string{
+		"node-c": "unknown instance type for us-east-1/linux/on-demand",
+		"node-d": "missing instance type label",
+		"node-e": "unknown instance type for us-east-1/linux/spot",
+	}
+	if !reflect.DeepEqual(reasons, expectedReasons) {
+		t.Errorf("expected the reasons %v, got %v", expectedReasons, reasons)
+	}
+
+	// nodes without a group are reported under an empty group
+	expectedGroups := []GroupCost{
+		{NodeGroup: "", Nodes: 1, Unknown: 1},
+		{NodeGroup: "g1", Nodes: 2, Hourly: 0.3, Monthly: 0.3 * util.HoursPerMonth},
+		{NodeGroup: "g2", Nodes: 2, Unknown: 2},
+	}
+	if len(report.NodeGroups) != len(expectedGroups) {
+		t.Fatalf("expected %d node groups, got %d", len(expectedGroups), len(report.NodeGroups))
+	}
+	for i, expected := range expectedGroups {
+		actual := *report.NodeGroups[i]
+		if actual.NodeGroup != expected.NodeGroup || actual.Nodes != expected.Nodes || actual.Unknown != expected.Unknown ||
+			math.Abs(actual.Hourly-expected.Hourly) > 1e-9 || math.Abs(actual.Monthly-expected.Monthly) > 1e-9 {
+			t.Errorf("expected the node group %+v, got %+v", expected, actual)
+		}
+	}
+}
@@ -27,6 +27,8 @@ const (
 	ThanosOffsetEnvVar       = "THANOS_QUERY_OFFSET"
 	ThanosMaxSourceResEnvVar = "THANOS_MAX_SOURCE_RESOLUTION"
 
+	PrometheusRetentionEnvVar = "PROMETHEUS_RETENTION"
+
 	LogCollectionEnabledEnvVar    = "LOG_COLLECTION_ENABLED"
 	ProductAnalyticsEnabledEnvVar = "PRODUCT_ANALYTICS_ENABLED"
 	ErrorReportingEnabledEnvVar   = "ERROR_REPORTING_ENABLED"
@@ -43,6 +45,18 @@ const (
 	InsecureSkipVerify = "INSECURE_SKIP_VERIFY"
 
 	KubeConfigPathEnvVar = "KUBECONFIG_PATH"
+	KubeContextEnvVar    = "KUBE_CONTEXT"
+	SnapshotPathEnvVar   = "SNAPSHOT_PATH"
+	NamespacesEnvVar     = "NAMESPACES"
+	ShouldHashEnvVar     = "SHOULD_HASH"
+	HashSecretEnvVar     = "HASH_SECRET"
+	HashMappingEnvVar    = "HASH_MAPPING_PATH"
+
+	OutputDirEnvVar    = "OUTPUT_DIR"
+	OutputFormatEnvVar = "OUTPUT_FORMAT"
+
+	UsageWindowEnvVar     = "USAGE_WINDOW"
+	UsageResolutionEnvVar = "USAGE_RESOLUTION"
 )
 
 // GetAWSAccessKeyID returns the environment variable value for AWSAccessKeyIDEnvVar which represents
@@ -188,6 +202,13 @@ func GetThanosMaxSourceResolution() string {
 	}
 }
 
+// GetPrometheusRetention returns the environment variable value for PrometheusRetentionEnvVar which
+// represents the Prometheus style duration of the history kept by Prometheus. Older data is queried
+// from thanos when enabled.
+func GetPrometheusRetention() string {
+	return Get(PrometheusRetentionEnvVar, "15d")
+}
+
 // IsLogCollectionEnabled returns the environment variable value for LogCollectionEnabledEnvVar which represents
 // whether or not log collection has been enabled for kubecost deployments.
 func IsLogCollectionEnabled() bool {
@@ -250,3 +271,65 @@ func GetMultiClusterBearerToken() string {
 func GetKubeConfigPath() string {
 	return Get(KubeConfigPathEnvVar, "")
 }
+
+// GetKubeContext returns the environment variable value for KubeContextEnvVar which represents the
+// comma separated kubeconfig contexts to connect to, the current context of the kubeconfig when empty
+func GetKubeContext() string {
+	return Get(KubeContextEnvVar, "")
+}
+
+// GetSnapshotPath returns the environment variable value for SnapshotPathEnvVar which represents the
+// comma separated paths of the cluster snapshots to load the cluster caches from instead of connecting
+// to the clusters
+func GetSnapshotPath() string {
+	return Get(SnapshotPathEnvVar, "")
+}
+
+// GetNamespaces returns the environment variable value for NamespacesEnvVar which represents the
+// comma separated namespaces the namespaced resources are restricted to, all namespaces when empty
+func GetNamespaces() string {
+	return Get(NamespacesEnvVar, "")
+}
+
+// IsHashEnabled returns the environment variable value for ShouldHashEnvVar which represents whether
+// the pod, owner, node and namespace names and the label values of the exports are replaced by keyed
+// hashes
+func IsHashEnabled() bool {
+	return GetBool(ShouldHashEnvVar, false)
+}
+
+// GetHashSecret returns the environment variable value for HashSecretEnvVar which represents the
+// secret key of the hashes of the exports
+func GetHashSecret() string {
+	return Get(HashSecretEnvVar, "")
+}
+
+// GetHashMappingPath returns the environment variable value for HashMappingEnvVar which represents the
+// path of the local file mapping the hashes of the exports to their values, not written when empty
+func GetHashMappingPath() string {
+	return Get(HashMappingEnvVar, "")
+}
+
+// GetOutputDir returns the environment variable value for OutputDirEnvVar which represents the
+// directory the exported files are written to, - for the standard output
+func GetOutputDir() string {
+	return Get(OutputDirEnvVar, ".")
+}
+
+// GetOutputFormat returns the environment variable value for OutputFormatEnvVar which represents the
+// format of the exported tables: csv, jsonl or parquet
+func GetOutputFormat() string {
+	return Get(OutputFormatEnvVar, "csv")
+}
+
+// GetUsageWindow returns the environment variable value for UsageWindowEnvVar which represents the
+// Prometheus style duration of the usage history queried from Prometheus, e.g. 7d
+func GetUsageWindow() string {
+	return Get(UsageWindowEnvVar, "7d")
+}
+
+// GetUsageResolution returns the environment variable value for UsageResolutionEnvVar which represents the
+// Prometheus style step of the usage range queries, e.g. 5m
+func GetUsageResolution() string {
+	return Get(UsageResolutionEnvVar, "5m")
+}
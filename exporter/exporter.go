@@ -0,0 +1,274 @@
+package exporter
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/model"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/optimizer"
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+const namespace = "perfectscale"
+
+// Options configures the exporter
+type Options struct {
+	// Interval is the min time between two recomputations of the gauges, which
+	// bounds the work done while the cluster churns
+	Interval time.Duration
+
+	// Optimize enables the projected savings of the optimizer, the most
+	// expensive of the gauges
+	Optimize bool
+
+	// Cost configures how the current nodes and the optimizer recommendations
+	// are priced
+	Cost *cost.Options
+}
+
+// DefaultOptions recomputes the gauges at most every 30 seconds, including the
+// projected savings, pricing nodes as on-demand linux nodes
+func DefaultOptions() *Options {
+	return &Options{
+		Interval: 30 * time.Second,
+		Optimize: true,
+		Cost:     cost.DefaultOptions(),
+	}
+}
+
+// Exporter publishes efficiency and waste gauges of the cluster in the
+// Prometheus text format. The gauges are recomputed whenever the pod and node
+// watchers of the cluster cache deliver changes.
+type Exporter struct {
+	cache    clustercache.ClusterCache
+	provider pricing.Provider
+	options  *Options
+
+	model *model.Model
+
+	lock   sync.RWMutex
+	gauges []*Gauge
+
+	updates chan struct{}
+}
+
+// NewExporter creates an exporter of the cache. Cost gauges are priced by the
+// provider and are left out when the provider is nil.
+func NewExporter(cache clustercache.ClusterCache, provider pricing.Provider, options *Options) *Exporter {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if options.Cost == nil {
+		options.Cost = cost.DefaultOptions()
+	}
+	var pricer *cost.Pricer
+	if provider != nil {
+		pricer = cost.NewPricer(provider, options.Cost)
+	}
+	return &Exporter{
+		cache:    cache,
+		provider: provider,
+		options:  options,
+		model:    model.NewModel(pricer),
+		updates:  make(chan struct{}, 1),
+	}
+}
+
+// Watch feeds the model of the exporter with the pod and node changes of the
+// cache, marking the gauges as outdated on every change
+func (e *Exporter) Watch() {
+	e.model.Subscribe(e.cache, e.Notify)
+}
+
+// Notify marks the gauges as outdated. It never blocks; notifications received
+// before the next recomputation are coalesced.
+func (e *Exporter) Notify() {
+	select {
+	case e.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Run computes the gauges and recomputes them after every notification, at most
+// once per interval, until stop is closed
+func (e *Exporter) Run(stop <-chan struct{}) {
+	e.Recompute()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-e.updates:
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(e.options.Interval):
+		}
+		e.Recompute()
+	}
+}
+
+// Recompute computes the gauges from the current state of the cache
+func (e *Exporter) Recompute() {
+	start := time.Now()
+	gauges := e.compute()
+
+	e.lock.Lock()
+	e.gauges = gauges
+	e.lock.Unlock()
+
+	klog.V(3).Infof("Recomputed %d gauges in %s", len(gauges), time.Since(start))
+}
+
+// Gauges returns the last computed gauges
+func (e *Exporter) Gauges() []*Gauge {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.gauges
+}
+
+// ServeHTTP writes the last computed gauges in the Prometheus text format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, e.Gauges()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+func newGauge(name string, help string) *Gauge {
+	return &Gauge{Name: namespace + "_" + name, Help: help}
+}
+
+// compute builds the gauges. The node group utilization and cost gauges are read
+// from the incrementally maintained model, while the allocations, which depend
+// on every pod of every node, are recomputed from the cache.
+func (e *Exporter) compute() []*Gauge {
+	updated := newGauge("last_update_timestamp_seconds", "Time the gauges were last recomputed.")
+	updated.Add(float64(time.Now().Unix()))
+
+	groupNodes := newGauge("node_group_nodes", "Number of nodes of the node group.")
+	groupPods := newGauge("node_group_pods", "Number of pods running on the nodes of the node group.")
+	allocatableCPU := newGauge("node_group_allocatable_cpu_cores", "Allocatable CPU of the nodes of the node group.")
+	requestedCPU := newGauge("node_group_requested_cpu_cores", "CPU requested by the pods running on the nodes of the node group.")
+	allocatableMemory := newGauge("node_group_allocatable_memory_bytes", "Allocatable memory of the nodes of the node group.")
+	requestedMemory := newGauge("node_group_requested_memory_bytes", "Memory requested by the pods running on the nodes of the node group.")
+	unpricedNodes := newGauge("node_group_unpriced_nodes", "Number of nodes of the node group which could not be priced.")
+	groupCost := newGauge("node_group_hourly_cost", "Hourly cost of the priced nodes of the node group.")
+	clusterCost := newGauge("cluster_hourly_cost", "Hourly cost of the priced nodes of the cluster.")
+
+	var hourly float64
+	for _, g := range e.model.NodeGroups() {
+		groupNodes.Add(float64(g.Nodes), "node_group", g.ID)
+		groupPods.Add(float64(g.Pods), "node_group", g.ID)
+		allocatableCPU.Add(float64(g.Allocatable.CPU)/1000, "node_group", g.ID)
+		requestedCPU.Add(float64(g.Requested.CPU)/1000, "node_group", g.ID)
+		allocatableMemory.Add(float64(g.Allocatable.Memory), "node_group", g.ID)
+		requestedMemory.Add(float64(g.Requested.Memory), "node_group", g.ID)
+		unpricedNodes.Add(float64(g.Unpriced), "node_group", g.ID)
+		groupCost.Add(g.Hourly, "node_group", g.ID)
+		hourly += g.Hourly
+	}
+	clusterCost.Add(hourly)
+
+	gauges := []*Gauge{updated, groupNodes, groupPods, allocatableCPU, requestedCPU, allocatableMemory, requestedMemory}
+	if e.provider == nil {
+		return gauges
+	}
+	gauges = append(gauges, unpricedNodes, groupCost, clusterCost)
+
+	nodes := e.cache.GetAllNodes()
+	_, node2group := nodegroup.Group(nodes)
+	resolver := workload.NewResolverFromCache(e.cache)
+
+	var pods []*v1.Pod
+	for _, pod := range e.cache.GetAllPods() {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed || pod.Spec.NodeName == "" {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+
+	report := cost.NewReport(nodes, node2group, e.provider, e.options.Cost)
+	allocated := cost.Allocate(report, nodes, pods, resolver, cost.DefaultAllocationOptions())
+
+	clusterIdle := newGauge("cluster_idle_hourly_cost", "Hourly cost of the node capacity of the cluster not requested by any pod.")
+	clusterIdle.Add(allocated.IdleHourly)
+	groupIdle := newGauge("node_group_idle_hourly_cost", "Hourly cost of the node capacity of the node group not requested by any pod.")
+	for _, ic := range allocated.Idle {
+		groupIdle.Add(ic.Hourly, "node_group", ic.NodeGroup)
+	}
+	namespaceCost := newGauge("namespace_allocated_hourly_cost", "Hourly cost of the nodes allocated to the pods of the namespace by their requests.")
+	for _, a := range allocated.ByNamespace() {
+		namespaceCost.Add(a.Hourly, "namespace", a.Namespace)
+	}
+	gauges = append(gauges, clusterIdle, groupIdle, namespaceCost)
+
+	if e.options.Optimize {
+		gauges = append(gauges, e.savings(report, nodes, pods, node2group, resolver))
+	}
+	return gauges
+}
+
+// savings computes the projected hourly savings of the cheapest recommendation
+// of the optimizer for every node group whose nodes are all priced
+func (e *Exporter) savings(report *cost.Report, nodes []*v1.Node, pods []*v1.Pod, node2group map[string]string, resolver *workload.Resolver) *Gauge {
+	gauge := newGauge("node_group_projected_hourly_savings", "Hourly savings of the cheapest instance type, or mix of instance types, recommended by the optimizer for the node group.")
+
+	key := pricing.Key{
+		OperatingSystem: e.options.Cost.DefaultOperatingSystem,
+		PurchaseOption:  e.options.Cost.PurchaseOption,
+	}
+	for _, node := range nodes {
+		if region, ok := util.GetRegion(node.Labels); ok {
+			key.Region = region
+			break
+		}
+	}
+	priced, err := e.provider.InstanceTypes(key)
+	if err != nil {
+		klog.Warningf("Failed loading instance types of %s, savings are not projected: %s", key, err)
+		return gauge
+	}
+	types := optimizer.NewInstanceTypes(priced)
+
+	current := make(map[string]*cost.GroupCost)
+	for _, gc := range report.NodeGroups {
+		current[gc.NodeGroup] = gc
+	}
+
+	opt := optimizer.NewOptimizer(optimizer.DefaultOptions())
+	for _, problem := range optimizer.NewProblems(pods, nodes, node2group, resolver) {
+		gc, ok := current[problem.NodeGroup]
+		if !ok || gc.Unknown > 0 {
+			continue
+		}
+
+		solutions := opt.Solve(problem, types)
+		best := optimizer.Cheapest(solutions)
+		if best == nil {
+			continue
+		}
+		recommended := best.Cost
+		if mix := opt.SolveMix(problem, solutions); mix != nil && mix.Cost < recommended {
+			recommended = mix.Cost
+		}
+		gauge.Add(gc.Hourly-recommended, "node_group", problem.NodeGroup)
+	}
+	return gauge
+}
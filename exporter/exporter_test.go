@@ -0,0 +1,191 @@
+package exporter
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+)
+
+// stubProvider prices m5.large at 0.1 an hour in every region
+type stubProvider struct{}
+
+func (stubProvider) InstanceTypes(key pricing.Key) ([]*pricing.InstanceType, error) {
+	return []*pricing.InstanceType{{Name: "m5.large", VCPUs: 2, Memory: 8, Hourly: 0.1}}, nil
+}
+
+func TestWriteText(t *testing.T) {
+	nodes := newGauge("node_group_nodes", "Number of nodes.\nOne line per group, see C:\\docs.")
+	nodes.Add(2, "node_group", "0")
+	nodes.Add(1.5, "node_group", `a "quoted"\path`+"\n", "zone", "b")
+	cost := newGauge("cluster_hourly_cost", "Hourly cost.")
+	cost.Add(0.25)
+	empty := newGauge("node_group_idle_hourly_cost", "Idle cost.")
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, []*Gauge{nodes, cost, empty}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP perfectscale_node_group_nodes Number of nodes.\nOne line per group, see C:\\docs.
+# TYPE perfectscale_node_group_nodes gauge
+perfectscale_node_group_nodes{node_group="0"} 2
+perfectscale_node_group_nodes{node_group="a \"quoted\"\\path\n",zone="b"} 1.5
+# HELP perfectscale_cluster_hourly_cost Hourly cost.
+# TYPE perfectscale_cluster_hourly_cost gauge
+perfectscale_cluster_hourly_cost 0.25
+# HELP perfectscale_node_group_idle_hourly_cost Idle cost.
+# TYPE perfectscale_node_group_idle_hourly_cost gauge
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+// scrape returns the samples served on /metrics by sample name and labels, e.g.
+// perfectscale_node_group_nodes{node_group="0"}
+func scrape(t *testing.T, e *Exporter) map[string]float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/plain; version=0.0.4" {
+		t.Fatalf("unexpected response %d of %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	samples := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("invalid sample %q: %s", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func testObjects() []runtime.Object {
+	labels := map[string]string{"topology.kubernetes.io/region": "us-east-1"}
+	return []runtime.Object{
+		clustercachetest.Node("node-a", "m5.large", "2", "8Gi", labels),
+		clustercachetest.Node("node-b", "m5.large", "2", "8Gi", labels),
+		clustercachetest.Pod("default", "web-1", "node-a", "500m", "512Mi", nil),
+		clustercachetest.Pod("default", "web-2", "node-b", "500m", "512Mi", nil),
+	}
+}
+
+// watch creates an exporter watching the harness and waits for the model to
+// hold the pods
+func watch(t *testing.T, h *clustercachetest.Harness, provider pricing.Provider, pods int) *Exporter {
+	t.Helper()
+	e := NewExporter(h.Cache, provider, &Options{Interval: time.Millisecond})
+	e.Watch()
+	waitForPods(t, h, e, pods)
+	return e
+}
+
+// waitForPods waits for the model of the exporter to hold the pods
+func waitForPods(t *testing.T, h *clustercachetest.Harness, e *Exporter, pods int) {
+	t.Helper()
+	err := h.WaitFor(func() bool {
+		var count int
+		for _, g := range e.model.NodeGroups() {
+			count += g.Pods
+		}
+		return count == pods
+	}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected %d pods in the model: %s", pods, err)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+	e := watch(t, h, stubProvider{}, 2)
+
+	e.Recompute()
+	samples := scrape(t, e)
+
+	// every pod is charged the fraction of the CPU and memory half of the node
+	// price it requests
+	allocated := 2 * (0.05*0.25 + 0.05*0.0625)
+	expected := map[string]float64{
+		`perfectscale_node_group_nodes{node_group="0"}`:                     2,
+		`perfectscale_node_group_pods{node_group="0"}`:                      2,
+		`perfectscale_node_group_allocatable_cpu_cores{node_group="0"}`:     4,
+		`perfectscale_node_group_requested_cpu_cores{node_group="0"}`:       1,
+		`perfectscale_node_group_allocatable_memory_bytes{node_group="0"}`:  16 * 1024 * 1024 * 1024,
+		`perfectscale_node_group_requested_memory_bytes{node_group="0"}`:    1024 * 1024 * 1024,
+		`perfectscale_node_group_unpriced_nodes{node_group="0"}`:            0,
+		`perfectscale_node_group_hourly_cost{node_group="0"}`:               0.2,
+		`perfectscale_cluster_hourly_cost`:                                  0.2,
+		`perfectscale_namespace_allocated_hourly_cost{namespace="default"}`: allocated,
+		`perfectscale_node_group_idle_hourly_cost{node_group="0"}`:          0.2 - allocated,
+		`perfectscale_cluster_idle_hourly_cost`:                             0.2 - allocated,
+	}
+	for name, value := range expected {
+		actual, ok := samples[name]
+		if !ok {
+			t.Errorf("expected the sample %s", name)
+		} else if math.Abs(actual-value) > 1e-9 {
+			t.Errorf("%s: expected %v, got %v", name, value, actual)
+		}
+	}
+	if updated := samples["perfectscale_last_update_timestamp_seconds"]; time.Since(time.Unix(int64(updated), 0)) > time.Minute {
+		t.Errorf("expected a recent update timestamp, got %v", updated)
+	}
+
+	// the gauges follow the cluster once recomputed
+	if err := h.Add(clustercachetest.Pod("default", "web-3", "node-a", "1", "1Gi", nil)); err != nil {
+		t.Fatal(err)
+	}
+	waitForPods(t, h, e, 3)
+	if samples := scrape(t, e); samples[`perfectscale_node_group_pods{node_group="0"}`] != 2 {
+		t.Errorf("expected the last computed gauges until recomputed, got %v pods", samples[`perfectscale_node_group_pods{node_group="0"}`])
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go e.Run(stop)
+	err := h.WaitFor(func() bool {
+		for _, g := range e.Gauges() {
+			if g.Name == "perfectscale_node_group_requested_cpu_cores" {
+				return len(g.Samples) == 1 && g.Samples[0].Value == 2
+			}
+		}
+		return false
+	}, 5*time.Second)
+	if err != nil {
+		t.Errorf("expected the gauges to be recomputed with the new pod: %s", err)
+	}
+}
+
+func TestExporterWithoutProvider(t *testing.T) {
+	h := clustercachetest.NewHarness(testObjects()...)
+	defer h.Stop()
+	e := watch(t, h, nil, 2)
+
+	e.Recompute()
+	samples := scrape(t, e)
+
+	if samples[`perfectscale_node_group_nodes{node_group="0"}`] != 2 {
+		t.Errorf("expected the node group gauges, got %v", samples)
+	}
+	for name := range samples {
+		if strings.Contains(name, "cost") || strings.Contains(name, "savings") {
+			t.Errorf("expected no cost gauges without a provider, got %s", name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Label is a single label of a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a gauge
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Gauge is a metric family of gauge samples
+type Gauge struct {
+	Name    string
+	Help    string
+	Samples []*Sample
+}
+
+// Add adds a sample with the label name and value pairs
+func (g *Gauge) Add(value float64, labels ...string) {
+	sample := &Sample{Value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		sample.Labels = append(sample.Labels, Label{Name: labels[i], Value: labels[i+1]})
+	}
+	g.Samples = append(g.Samples, sample)
+}
+
+// WriteText writes the gauges in the Prometheus text exposition format
+func WriteText(w io.Writer, gauges []*Gauge) error {
+	bw := bufio.NewWriter(w)
+	for _, g := range gauges {
+		bw.WriteString("# HELP " + g.Name + " " + escapeHelp(g.Help) + "\n")
+		bw.WriteString("# TYPE " + g.Name + " gauge\n")
+		for _, s := range g.Samples {
+			bw.WriteString(g.Name)
+			if len(s.Labels) > 0 {
+				bw.WriteString("{")
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteString(",")
+					}
+					bw.WriteString(l.Name + `="` + escapeLabelValue(l.Value) + `"`)
+				}
+				bw.WriteString("}")
+			}
+			bw.WriteString(" " + strconv.FormatFloat(s.Value, 'g', -1, 64) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
@@ -2,6 +2,7 @@ module github.com/mikeskali/PerfectScalePoc
 
 require (
 	github.com/aws/aws-sdk-go v1.36.9
+	github.com/xitongsys/parquet-go v1.6.2
 	k8s.io/api v0.18.10
 	k8s.io/apimachinery v0.18.10
 	k8s.io/client-go v0.18.10
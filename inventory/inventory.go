@@ -0,0 +1,155 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+// Resources holds CPU, in milli cores, and memory, in bytes
+type Resources struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+// Add returns the sum of both resources
+func (r Resources) Add(other Resources) Resources {
+	return Resources{CPU: r.CPU + other.CPU, Memory: r.Memory + other.Memory}
+}
+
+// Scale returns the resources multiplied by the factor
+func (r Resources) Scale(factor int64) Resources {
+	return Resources{CPU: r.CPU * factor, Memory: r.Memory * factor}
+}
+
+// Node is the capacity and allocatable resources of a node
+type Node struct {
+	Name         string    `json:"name"`
+	NodeGroup    string    `json:"nodeGroup"`
+	InstanceType string    `json:"instanceType"`
+	Taints       []string  `json:"taints"`
+	Capacity     Resources `json:"capacity"`
+	Allocatable  Resources `json:"allocatable"`
+}
+
+// Pod is the requests and limits of a pod, summed over its containers, along
+// with its workload and usage
+type Pod struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Node      string            `json:"node"`
+	NodeGroup string            `json:"nodeGroup"`
+	Workload  workload.Workload `json:"workload"`
+	Requests  Resources         `json:"requests"`
+	Limits    Resources         `json:"limits"`
+	Usage     *metrics.PodUsage `json:"usage,omitempty"`
+}
+
+// Workload is the total requests and limits of the pods of a workload
+type Workload struct {
+	workload.Workload
+	Pods       int       `json:"pods"`
+	NodeGroups []string  `json:"nodeGroups"`
+	Requests   Resources `json:"requests"`
+	Limits     Resources `json:"limits"`
+}
+
+// Nodes returns the nodes of the groups, in group order
+func Nodes(groups []*nodegroup.NodeGroup) []*Node {
+	nodes := []*Node{}
+	for _, group := range groups {
+		for _, node := range group.Nodes {
+			var taints []string
+			for _, taint := range node.Spec.Taints {
+				taints = append(taints, fmt.Sprintf("%s:%s(%s)", taint.Key, taint.Value, taint.Effect))
+			}
+
+			instanceType, ok := util.GetInstanceType(node.Labels)
+			if !ok {
+				instanceType = "n/a"
+			}
+
+			nodes = append(nodes, &Node{
+				Name:         node.Name,
+				NodeGroup:    group.ID,
+				InstanceType: instanceType,
+				Taints:       taints,
+				Capacity:     resources(node.Status.Capacity),
+				Allocatable:  resources(node.Status.Allocatable),
+			})
+		}
+	}
+	return nodes
+}
+
+// Pods returns the pods with their workload, resolved by the resolver, and
+// their usage, if any, ordered by namespace and name
+func Pods(pods []*v1.Pod, node2group map[string]string, resolver *workload.Resolver, usage *metrics.Usage) []*Pod {
+	result := make([]*Pod, 0, len(pods))
+	for _, pod := range pods {
+		p := &Pod{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Node:      pod.Spec.NodeName,
+			NodeGroup: node2group[pod.Spec.NodeName],
+			Workload:  resolver.Resolve(pod),
+			Usage:     usage.Pod(pod.Namespace, pod.Name),
+		}
+		for _, container := range pod.Spec.Containers {
+			p.Requests = p.Requests.Add(resources(container.Resources.Requests))
+			p.Limits = p.Limits.Add(resources(container.Resources.Limits))
+		}
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Workloads sums the pods per workload, ordered by workload
+func Workloads(pods []*Pod) []*Workload {
+	byWorkload := make(map[workload.Workload]*Workload)
+	groups := make(map[workload.Workload]map[string]bool)
+	for _, pod := range pods {
+		w, ok := byWorkload[pod.Workload]
+		if !ok {
+			w = &Workload{Workload: pod.Workload}
+			byWorkload[pod.Workload] = w
+			groups[pod.Workload] = make(map[string]bool)
+		}
+		w.Pods++
+		w.Requests = w.Requests.Add(pod.Requests)
+		w.Limits = w.Limits.Add(pod.Limits)
+		if pod.NodeGroup != "" && !groups[pod.Workload][pod.NodeGroup] {
+			groups[pod.Workload][pod.NodeGroup] = true
+			w.NodeGroups = append(w.NodeGroups, pod.NodeGroup)
+		}
+	}
+
+	result := make([]*Workload, 0, len(byWorkload))
+	for _, w := range byWorkload {
+		sort.Strings(w.NodeGroups)
+		result = append(result, w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
+
+func resources(list v1.ResourceList) Resources {
+	return Resources{
+		CPU:    list.Cpu().MilliValue(),
+		Memory: list.Memory().Value(),
+	}
+}
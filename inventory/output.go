@@ -0,0 +1,288 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+)
+
+// WriteNodeGroups writes a node_groups.csv formatted list of the node groups
+func WriteNodeGroups(w io.Writer, groups []*nodegroup.NodeGroup) error {
+	records := [][]string{
+		{"group_id", "number_of_nodes", "unique_labels", "ignore_labels"},
+	}
+
+	for _, group := range groups {
+		records = append(records, []string{
+			group.ID,
+			strconv.Itoa(len(group.Nodes)),
+			strings.Join(group.UniqueLabels, " | "),
+			strings.Join(group.IgnoredLabels, " | "),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteNodes writes a nodes.csv formatted list of the nodes
+func WriteNodes(w io.Writer, nodes []*Node) error {
+	records := [][]string{
+		{"group_id", "node_name", "node_type", "taints", "cap_cpu_mili_core", "cap_memory_byte", "alloc_cpu_mili_core", "alloc_bytes"},
+	}
+
+	for _, node := range nodes {
+		records = append(records, []string{
+			node.NodeGroup,
+			node.Name,
+			node.InstanceType,
+			strings.Join(node.Taints, ","),
+			strconv.FormatInt(node.Capacity.CPU, 10),
+			strconv.FormatInt(node.Capacity.Memory, 10),
+			strconv.FormatInt(node.Allocatable.CPU, 10),
+			strconv.FormatInt(node.Allocatable.Memory, 10),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WritePods writes a pods.csv formatted list of the pods. The p50, p95, p99 and
+// max CPU usage is written in milli cores and the memory usage in bytes, left
+// empty for pods without usage.
+func WritePods(w io.Writer, pods []*Pod) error {
+	records := [][]string{
+		{
+			"pod_name", "node_name", "node_group", "namespace", "owner_kind", "owner_name",
+			"req_cpu_milli_core", "req_mem_byte", "limit_cpu_mili_core", "limit_mem_bytes",
+			"usage_cpu_p50_milli_core", "usage_cpu_p95_milli_core", "usage_cpu_p99_milli_core", "usage_cpu_max_milli_core",
+			"usage_mem_p50_byte", "usage_mem_p95_byte", "usage_mem_p99_byte", "usage_mem_max_byte",
+		},
+	}
+
+	for _, pod := range pods {
+		var cpu, memory *metrics.Distribution
+		if pod.Usage != nil {
+			cpu, memory = pod.Usage.CPU, pod.Usage.Memory
+		}
+
+		record := []string{
+			pod.Name,
+			pod.Node,
+			pod.NodeGroup,
+			pod.Namespace,
+			pod.Workload.Kind,
+			pod.Workload.Name,
+			strconv.FormatInt(pod.Requests.CPU, 10),
+			strconv.FormatInt(pod.Requests.Memory, 10),
+			strconv.FormatInt(pod.Limits.CPU, 10),
+			strconv.FormatInt(pod.Limits.Memory, 10),
+		}
+		record = append(record, distributionColumns(cpu, 1000)...)
+		record = append(record, distributionColumns(memory, 1)...)
+		records = append(records, record)
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteWorkloads writes a list of the workloads with their total requests and
+// limits
+func WriteWorkloads(w io.Writer, workloads []*Workload) error {
+	records := [][]string{
+		{
+			"namespace", "workload_kind", "workload_name", "num_pods", "node_groups",
+			"req_cpu_milli_core", "req_mem_byte", "limit_cpu_milli_core", "limit_mem_byte",
+		},
+	}
+
+	for _, wl := range workloads {
+		records = append(records, []string{
+			wl.Namespace,
+			wl.Kind,
+			wl.Name,
+			strconv.Itoa(wl.Pods),
+			strings.Join(wl.NodeGroups, ","),
+			strconv.FormatInt(wl.Requests.CPU, 10),
+			strconv.FormatInt(wl.Requests.Memory, 10),
+			strconv.FormatInt(wl.Limits.CPU, 10),
+			strconv.FormatInt(wl.Limits.Memory, 10),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteDeployments writes a list of the deployments with their replicas,
+// scheduling constraints and containers
+func WriteDeployments(w io.Writer, deployments []*appsv1.Deployment) error {
+	records := [][]string{
+		{
+			"namespace", "name", "replicas", "node_selector", "label_selectors", "expression_selectors",
+			"node_affinity", "pod_affinity", "pod_anti_affinity", "containers",
+		},
+	}
+
+	for _, dep := range deployments {
+		nodeAffinity, podAffinity, podAntiAffinity := AffinityColumns(dep.Spec.Template.Spec.Affinity)
+		records = append(records, []string{
+			dep.Namespace,
+			dep.Name,
+			ReplicasColumn(dep.Spec.Replicas),
+			mapColumn(dep.Spec.Template.Spec.NodeSelector),
+			mapColumn(selectorLabels(dep.Spec.Selector)),
+			expressionsColumn(dep.Spec.Selector),
+			nodeAffinity,
+			podAffinity,
+			podAntiAffinity,
+			containersColumn(dep.Spec.Template.Spec.Containers),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteStatefulSets writes a list of the StatefulSets with their replicas, pod
+// management policy, scheduling constraints and containers
+func WriteStatefulSets(w io.Writer, statefulSets []*appsv1.StatefulSet) error {
+	records := [][]string{
+		{
+			"namespace", "name", "replicas", "pod_management_policy", "node_selector", "label_selectors", "expression_selectors",
+			"node_affinity", "pod_affinity", "pod_anti_affinity", "containers",
+		},
+	}
+
+	for _, sts := range statefulSets {
+		nodeAffinity, podAffinity, podAntiAffinity := AffinityColumns(sts.Spec.Template.Spec.Affinity)
+		records = append(records, []string{
+			sts.Namespace,
+			sts.Name,
+			ReplicasColumn(sts.Spec.Replicas),
+			string(sts.Spec.PodManagementPolicy),
+			mapColumn(sts.Spec.Template.Spec.NodeSelector),
+			mapColumn(selectorLabels(sts.Spec.Selector)),
+			expressionsColumn(sts.Spec.Selector),
+			nodeAffinity,
+			podAffinity,
+			podAntiAffinity,
+			containersColumn(sts.Spec.Template.Spec.Containers),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteDaemonSets writes a list of the DaemonSets with their scheduling
+// constraints and containers
+func WriteDaemonSets(w io.Writer, daemonSets []*appsv1.DaemonSet) error {
+	records := [][]string{
+		{
+			"namespace", "name", "node_selector", "label_selectors", "expression_selectors",
+			"node_affinity", "pod_affinity", "pod_anti_affinity", "containers",
+		},
+	}
+
+	for _, ds := range daemonSets {
+		nodeAffinity, podAffinity, podAntiAffinity := AffinityColumns(ds.Spec.Template.Spec.Affinity)
+		records = append(records, []string{
+			ds.Namespace,
+			ds.Name,
+			mapColumn(ds.Spec.Template.Spec.NodeSelector),
+			mapColumn(selectorLabels(ds.Spec.Selector)),
+			expressionsColumn(ds.Spec.Selector),
+			nodeAffinity,
+			podAffinity,
+			podAntiAffinity,
+			containersColumn(ds.Spec.Template.Spec.Containers),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// ReplicasColumn returns the replicas, or an empty column when they aren't set
+func ReplicasColumn(replicas *int32) string {
+	if replicas == nil {
+		return ""
+	}
+	return strconv.Itoa(int(*replicas))
+}
+
+// mapColumn returns the sorted key:value pairs of the map
+func mapColumn(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+":"+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func selectorLabels(selector *metav1.LabelSelector) map[string]string {
+	if selector == nil {
+		return nil
+	}
+	return selector.MatchLabels
+}
+
+// expressionsColumn returns the key:values of the match expressions of the
+// selector, separated by " | "
+func expressionsColumn(selector *metav1.LabelSelector) string {
+	if selector == nil {
+		return ""
+	}
+	expressions := make([]string, 0, len(selector.MatchExpressions))
+	for _, expr := range selector.MatchExpressions {
+		expressions = append(expressions, expr.Key+":"+strings.Join(expr.Values, ","))
+	}
+	return strings.Join(expressions, " | ")
+}
+
+// AffinityColumns returns the node, pod and pod anti affinity of the pods
+func AffinityColumns(affinity *v1.Affinity) (nodeAffinity string, podAffinity string, podAntiAffinity string) {
+	if affinity == nil {
+		return "", "", ""
+	}
+	if affinity.NodeAffinity != nil {
+		nodeAffinity = affinity.NodeAffinity.String()
+	}
+	if affinity.PodAffinity != nil {
+		podAffinity = affinity.PodAffinity.String()
+	}
+	if affinity.PodAntiAffinity != nil {
+		podAntiAffinity = affinity.PodAntiAffinity.String()
+	}
+	return nodeAffinity, podAffinity, podAntiAffinity
+}
+
+// containersColumn returns the CPU and memory requests of every container,
+// separated by " | "
+func containersColumn(containers []v1.Container) string {
+	columns := make([]string, 0, len(containers))
+	for _, container := range containers {
+		columns = append(columns, container.Name+" cpu="+container.Resources.Requests.Cpu().String()+" memory="+container.Resources.Requests.Memory().String())
+	}
+	return strings.Join(columns, " | ")
+}
+
+// distributionColumns returns the p50, p95, p99 and max of the distribution
+// multiplied by the scale, or empty columns for a nil distribution
+func distributionColumns(d *metrics.Distribution, scale float64) []string {
+	if d == nil {
+		return []string{"", "", "", ""}
+	}
+
+	columns := make([]string, 0, 4)
+	for _, v := range []float64{d.P50, d.P95, d.P99, d.Max} {
+		columns = append(columns, strconv.FormatInt(int64(v*scale), 10))
+	}
+	return columns
+}
@@ -0,0 +1,211 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ContainerResources holds CPU, in milli cores, and memory and ephemeral
+// storage, in bytes
+type ContainerResources struct {
+	CPU              int64 `json:"cpu"`
+	Memory           int64 `json:"memory"`
+	EphemeralStorage int64 `json:"ephemeralStorage"`
+}
+
+// Autoscaler is the horizontal pod autoscaler scaling a workload
+type Autoscaler struct {
+	Name                 string `json:"name"`
+	MinReplicas          int32  `json:"minReplicas"`
+	MaxReplicas          int32  `json:"maxReplicas"`
+	TargetCPUUtilization *int32 `json:"targetCPUUtilization,omitempty"`
+}
+
+// WorkloadContainer is a container of the pod template of a deployment,
+// StatefulSet or DaemonSet, along with the scheduling constraints and scaling of
+// the workload
+type WorkloadContainer struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+
+	// Replicas is the desired number of pods, for DaemonSets the number of
+	// nodes they should run on
+	Replicas            int32       `json:"replicas"`
+	PodManagementPolicy string      `json:"podManagementPolicy,omitempty"`
+	Autoscaler          *Autoscaler `json:"autoscaler,omitempty"`
+
+	NodeSelector    map[string]string `json:"nodeSelector"`
+	NodeAffinity    string            `json:"nodeAffinity"`
+	PodAffinity     string            `json:"podAffinity"`
+	PodAntiAffinity string            `json:"podAntiAffinity"`
+	Tolerations     []string          `json:"tolerations"`
+
+	Container string             `json:"container"`
+	Requests  ContainerResources `json:"requests"`
+	Limits    ContainerResources `json:"limits"`
+}
+
+// WorkloadContainers returns a WorkloadContainer for every container of the
+// deployments, StatefulSets and DaemonSets, ordered by namespace, kind, name and
+// container order. Workloads are linked to the autoscaler whose scale target
+// they are.
+func WorkloadContainers(deployments []*appsv1.Deployment, statefulSets []*appsv1.StatefulSet, daemonSets []*appsv1.DaemonSet, autoscalers []*autoscalingv1.HorizontalPodAutoscaler) []*WorkloadContainer {
+	scaled := make(map[string]*Autoscaler)
+	for _, hpa := range autoscalers {
+		ref := hpa.Spec.ScaleTargetRef
+		minReplicas := int32(1)
+		if hpa.Spec.MinReplicas != nil {
+			minReplicas = *hpa.Spec.MinReplicas
+		}
+		scaled[hpa.Namespace+"/"+ref.Kind+"/"+ref.Name] = &Autoscaler{
+			Name:                 hpa.Name,
+			MinReplicas:          minReplicas,
+			MaxReplicas:          hpa.Spec.MaxReplicas,
+			TargetCPUUtilization: hpa.Spec.TargetCPUUtilizationPercentage,
+		}
+	}
+
+	var containers []*WorkloadContainer
+	add := func(namespace string, kind string, name string, replicas int32, policy string, template *v1.PodTemplateSpec) {
+		nodeAffinity, podAffinity, podAntiAffinity := AffinityColumns(template.Spec.Affinity)
+		for _, container := range template.Spec.Containers {
+			containers = append(containers, &WorkloadContainer{
+				Namespace:           namespace,
+				Kind:                kind,
+				Name:                name,
+				Replicas:            replicas,
+				PodManagementPolicy: policy,
+				Autoscaler:          scaled[namespace+"/"+kind+"/"+name],
+				NodeSelector:        template.Spec.NodeSelector,
+				NodeAffinity:        nodeAffinity,
+				PodAffinity:         podAffinity,
+				PodAntiAffinity:     podAntiAffinity,
+				Tolerations:         tolerations(template.Spec.Tolerations),
+				Container:           container.Name,
+				Requests:            containerResources(container.Resources.Requests),
+				Limits:              containerResources(container.Resources.Limits),
+			})
+		}
+	}
+
+	for _, dep := range deployments {
+		add(dep.Namespace, "Deployment", dep.Name, desiredReplicas(dep.Spec.Replicas), "", &dep.Spec.Template)
+	}
+	for _, sts := range statefulSets {
+		add(sts.Namespace, "StatefulSet", sts.Name, desiredReplicas(sts.Spec.Replicas), string(sts.Spec.PodManagementPolicy), &sts.Spec.Template)
+	}
+	for _, ds := range daemonSets {
+		add(ds.Namespace, "DaemonSet", ds.Name, ds.Status.DesiredNumberScheduled, "", &ds.Spec.Template)
+	}
+
+	// stable sort keeps the containers of a workload in template order
+	sort.SliceStable(containers, func(i, j int) bool {
+		a, b := containers[i], containers[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return containers
+}
+
+// WriteWorkloadContainers writes a workloads.csv formatted list of the workload
+// containers
+func WriteWorkloadContainers(w io.Writer, containers []*WorkloadContainer) error {
+	records := [][]string{
+		{
+			"namespace", "workload_kind", "workload_name", "replicas", "pod_management_policy",
+			"hpa_name", "hpa_min_replicas", "hpa_max_replicas", "hpa_target_cpu_utilization",
+			"node_selector", "node_affinity", "pod_affinity", "pod_anti_affinity", "tolerations",
+			"container", "req_cpu_milli_core", "req_mem_byte", "req_ephemeral_storage_byte",
+			"limit_cpu_milli_core", "limit_mem_byte", "limit_ephemeral_storage_byte",
+		},
+	}
+
+	for _, c := range containers {
+		var hpaName, hpaMin, hpaMax, hpaTarget string
+		if c.Autoscaler != nil {
+			hpaName = c.Autoscaler.Name
+			hpaMin = strconv.Itoa(int(c.Autoscaler.MinReplicas))
+			hpaMax = strconv.Itoa(int(c.Autoscaler.MaxReplicas))
+			if c.Autoscaler.TargetCPUUtilization != nil {
+				hpaTarget = strconv.Itoa(int(*c.Autoscaler.TargetCPUUtilization))
+			}
+		}
+
+		records = append(records, []string{
+			c.Namespace,
+			c.Kind,
+			c.Name,
+			strconv.Itoa(int(c.Replicas)),
+			c.PodManagementPolicy,
+			hpaName,
+			hpaMin,
+			hpaMax,
+			hpaTarget,
+			mapColumn(c.NodeSelector),
+			c.NodeAffinity,
+			c.PodAffinity,
+			c.PodAntiAffinity,
+			strings.Join(c.Tolerations, " | "),
+			c.Container,
+			strconv.FormatInt(c.Requests.CPU, 10),
+			strconv.FormatInt(c.Requests.Memory, 10),
+			strconv.FormatInt(c.Requests.EphemeralStorage, 10),
+			strconv.FormatInt(c.Limits.CPU, 10),
+			strconv.FormatInt(c.Limits.Memory, 10),
+			strconv.FormatInt(c.Limits.EphemeralStorage, 10),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// desiredReplicas returns the replicas of a spec, which default to 1
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func containerResources(list v1.ResourceList) ContainerResources {
+	return ContainerResources{
+		CPU:              list.Cpu().MilliValue(),
+		Memory:           list.Memory().Value(),
+		EphemeralStorage: list.StorageEphemeral().Value(),
+	}
+}
+
+// tolerations formats the tolerations as key=value:effect, or key:effect for
+// tolerations of any value, with * standing for any key or effect
+func tolerations(list []v1.Toleration) []string {
+	formatted := make([]string, 0, len(list))
+	for _, t := range list {
+		key := t.Key
+		if key == "" {
+			key = "*"
+		}
+		if t.Operator != v1.TolerationOpExists {
+			key += "=" + t.Value
+		}
+		effect := string(t.Effect)
+		if effect == "" {
+			effect = "*"
+		}
+		formatted = append(formatted, fmt.Sprintf("%s:%s", key, effect))
+	}
+	return formatted
+}
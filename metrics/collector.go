@@ -0,0 +1,275 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+const (
+	// CPUQuery is the CPU usage of every container, in cores
+	CPUQuery = `sum(rate(container_cpu_usage_seconds_total{container!="",container!="POD"}[5m])) by (namespace, pod, container)`
+
+	// MemoryQuery is the working set memory of every container, in bytes
+	MemoryQuery = `sum(container_memory_working_set_bytes{container!="",container!="POD"}) by (namespace, pod, container)`
+)
+
+// Options configures the time range and resolution of the usage queries
+type Options struct {
+	// Window is the Prometheus style duration of the queried range, e.g. 7d
+	Window string
+
+	// Offset shifts the end of the range back from now, e.g. 1h
+	Offset string
+
+	// Resolution is the step of the range queries
+	Resolution time.Duration
+
+	// ChunkSize splits the range into multiple range queries of at most this
+	// duration, which run concurrently
+	ChunkSize time.Duration
+}
+
+// DefaultOptions queries the last 7 days at a 5 minute resolution, one day per query
+func DefaultOptions() *Options {
+	return &Options{
+		Window:     "7d",
+		Resolution: 5 * time.Minute,
+		ChunkSize:  24 * time.Hour,
+	}
+}
+
+// OptionsFromEnv returns the default options with the window and resolution set
+// from USAGE_WINDOW and USAGE_RESOLUTION
+func OptionsFromEnv() (*Options, error) {
+	options := DefaultOptions()
+	options.Window = env.GetUsageWindow()
+
+	resolution, err := util.ParseDuration(env.GetUsageResolution())
+	if err != nil {
+		return nil, err
+	}
+	options.Resolution = *resolution
+	return options, nil
+}
+
+// ContainerUsage is the CPU, in cores, and working set memory, in bytes, of a
+// single container. Either distribution is nil when no samples were found.
+type ContainerUsage struct {
+	Namespace string        `json:"namespace"`
+	Pod       string        `json:"pod"`
+	Container string        `json:"container"`
+	CPU       *Distribution `json:"cpu"`
+	Memory    *Distribution `json:"memory"`
+}
+
+// PodUsage is the usage of a pod, summed over its containers at every sample,
+// along with the usage of each of its containers
+type PodUsage struct {
+	Namespace  string                     `json:"namespace"`
+	Pod        string                     `json:"pod"`
+	CPU        *Distribution              `json:"cpu"`
+	Memory     *Distribution              `json:"memory"`
+	Containers map[string]*ContainerUsage `json:"containers"`
+}
+
+// Usage holds the usage of every pod found in Prometheus
+type Usage struct {
+	Start time.Time            `json:"start"`
+	End   time.Time            `json:"end"`
+	Pods  map[string]*PodUsage `json:"pods"`
+}
+
+// Pod returns the usage of the pod, or nil if none was found
+func (u *Usage) Pod(namespace string, name string) *PodUsage {
+	if u == nil {
+		return nil
+	}
+	return u.Pods[namespace+"/"+name]
+}
+
+// Container returns the usage of the container of the pod, or nil if none was found
+func (u *Usage) Container(namespace string, pod string, container string) *ContainerUsage {
+	pu := u.Pod(namespace, pod)
+	if pu == nil {
+		return nil
+	}
+	return pu.Containers[container]
+}
+
+// Querier runs a range query. It is implemented by the Prometheus client and
+// allows routing queries to other backends.
+type Querier interface {
+	QueryRange(query string, start, end time.Time, step time.Duration) ([]*Series, error)
+}
+
+// Collector collects the container CPU and memory usage from Prometheus
+type Collector struct {
+	querier Querier
+	options *Options
+}
+
+// NewCollector creates a collector running its queries with the querier
+func NewCollector(querier Querier, options *Options) *Collector {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	return &Collector{
+		querier: querier,
+		options: options,
+	}
+}
+
+// Collect queries the CPU and memory usage of every container over the window.
+// The window is split into chunks which are queried concurrently, bounded by
+// the querier, and merged back per container.
+func (c *Collector) Collect() (*Usage, error) {
+	start, end, err := util.ParseTimeRange(c.options.Window, c.options.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	cpu, err := c.collect(CPUQuery, *start, *end)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := c.collect(MemoryQuery, *start, *end)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{
+		Start: *start,
+		End:   *end,
+		Pods:  make(map[string]*PodUsage),
+	}
+	podCPU := make(map[string][]*util.Vector)
+	podMemory := make(map[string][]*util.Vector)
+
+	container := func(k containerKey) *ContainerUsage {
+		pk := k.namespace + "/" + k.pod
+		pu, ok := usage.Pods[pk]
+		if !ok {
+			pu = &PodUsage{
+				Namespace:  k.namespace,
+				Pod:        k.pod,
+				Containers: make(map[string]*ContainerUsage),
+			}
+			usage.Pods[pk] = pu
+		}
+		cu, ok := pu.Containers[k.container]
+		if !ok {
+			cu = &ContainerUsage{Namespace: k.namespace, Pod: k.pod, Container: k.container}
+			pu.Containers[k.container] = cu
+		}
+		return cu
+	}
+
+	for k, values := range cpu {
+		container(k).CPU = NewDistribution(values)
+		pk := k.namespace + "/" + k.pod
+		podCPU[pk] = util.ApplyVectorOp(podCPU[pk], values, sumOp)
+	}
+	for k, values := range memory {
+		container(k).Memory = NewDistribution(values)
+		pk := k.namespace + "/" + k.pod
+		podMemory[pk] = util.ApplyVectorOp(podMemory[pk], values, sumOp)
+	}
+	for pk, pu := range usage.Pods {
+		pu.CPU = NewDistribution(podCPU[pk])
+		pu.Memory = NewDistribution(podMemory[pk])
+	}
+
+	klog.V(3).Infof("Collected usage of %d pods between %s and %s", len(usage.Pods), start, end)
+	return usage, nil
+}
+
+type containerKey struct {
+	namespace string
+	pod       string
+	container string
+}
+
+// collect runs the query over every chunk of the range concurrently and merges
+// the series of each container
+func (c *Collector) collect(query string, start, end time.Time) (map[containerKey][]*util.Vector, error) {
+	chunks := splitRange(start, end, c.options.ChunkSize, c.options.Resolution)
+
+	results := make([][]*Series, len(chunks))
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk [2]time.Time) {
+			defer wg.Done()
+			results[i], errs[i] = c.querier.QueryRange(query, chunk[0], chunk[1], c.options.Resolution)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	merged := make(map[containerKey][]*util.Vector)
+	for _, result := range results {
+		for _, series := range result {
+			k := containerKey{
+				namespace: series.Labels["namespace"],
+				pod:       series.Labels["pod"],
+				container: series.Labels["container"],
+			}
+			if k.pod == "" || k.container == "" {
+				continue
+			}
+			merged[k] = util.ApplyVectorOp(merged[k], series.Values, mergeOp)
+		}
+	}
+	return merged, nil
+}
+
+// splitRange splits the range into consecutive chunks of at most size, each
+// starting one step after the end of the previous one
+func splitRange(start, end time.Time, size time.Duration, step time.Duration) [][2]time.Time {
+	if size <= 0 {
+		return [][2]time.Time{{start, end}}
+	}
+
+	var chunks [][2]time.Time
+	for s := start; !s.After(end); {
+		e := s.Add(size)
+		if e.After(end) {
+			e = end
+		}
+		chunks = append(chunks, [2]time.Time{s, e})
+		s = e.Add(step)
+	}
+	return chunks
+}
+
+// sumOp adds the values of both series, treating a missing value as zero
+func sumOp(result *util.Vector, x *float64, y *float64) bool {
+	if x != nil {
+		result.Value += *x
+	}
+	if y != nil {
+		result.Value += *y
+	}
+	return true
+}
+
+// mergeOp merges two series, preferring the first one's value where both exist
+func mergeOp(result *util.Vector, x *float64, y *float64) bool {
+	if x != nil {
+		result.Value = *x
+	} else if y != nil {
+		result.Value = *y
+	}
+	return true
+}
@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/metrics/metricstest"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// hourly returns a sample per hour ending an hour ago, the last one holding the
+// last value
+func hourly(values ...float64) []*util.Vector {
+	end := time.Now().Add(-time.Hour).Truncate(time.Hour)
+	series := make([]*util.Vector, len(values))
+	for i, v := range values {
+		ts := end.Add(-time.Duration(len(values)-1-i) * time.Hour)
+		series[i] = &util.Vector{Timestamp: float64(ts.Unix()), Value: v}
+	}
+	return series
+}
+
+// sequence returns the values from 1 to n
+func sequence(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = float64(i + 1)
+	}
+	return values
+}
+
+func constant(n int, value float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+func labels(namespace, pod, container string) map[string]string {
+	return map[string]string{"namespace": namespace, "pod": pod, "container": container}
+}
+
+// testOptions query the last 5 days in chunks of a day. The one second step
+// keeps the chunks from skipping the hourly samples in between them.
+func testOptions() *Options {
+	return &Options{
+		Window:     "5d",
+		Resolution: time.Second,
+		ChunkSize:  24 * time.Hour,
+	}
+}
+
+func assertDistribution(t *testing.T, name string, d *Distribution, expected Distribution) {
+	t.Helper()
+	if d == nil {
+		t.Errorf("%s: expected %+v, got no distribution", name, expected)
+		return
+	}
+	if *d != expected {
+		t.Errorf("%s: expected %+v, got %+v", name, expected, *d)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	server := metricstest.NewServer()
+	defer server.Close()
+
+	// the samples of the app container span several chunks
+	server.Add(CPUQuery, labels("default", "web-0", "app"), hourly(sequence(100)...))
+	server.Add(CPUQuery, labels("default", "web-0", "sidecar"), hourly(constant(100, 1)...))
+	server.Add(MemoryQuery, labels("default", "web-0", "app"), hourly(constant(100, 512)...))
+	// series without a pod or container are skipped
+	server.Add(CPUQuery, map[string]string{"namespace": "default"}, hourly(1, 2, 3))
+	// a pod with cpu samples only
+	server.Add(CPUQuery, labels("kube-system", "dns-0", "dns"), hourly(0.5, 0.25))
+
+	usage, err := NewCollector(NewPrometheusClient(server.URL, 2), testOptions()).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Pods) != 2 {
+		t.Fatalf("expected the usage of 2 pods, got %d", len(usage.Pods))
+	}
+	if len(server.Queries()) < 2*5 {
+		t.Errorf("expected the window to be split into daily queries, got %d queries", len(server.Queries()))
+	}
+
+	app := usage.Container("default", "web-0", "app")
+	if app == nil {
+		t.Fatal("expected the usage of container default/web-0/app")
+	}
+	assertDistribution(t, "app cpu", app.CPU, Distribution{Samples: 100, P50: 50, P95: 95, P99: 99, Max: 100})
+	assertDistribution(t, "app memory", app.Memory, Distribution{Samples: 100, P50: 512, P95: 512, P99: 512, Max: 512})
+
+	sidecar := usage.Container("default", "web-0", "sidecar")
+	assertDistribution(t, "sidecar cpu", sidecar.CPU, Distribution{Samples: 100, P50: 1, P95: 1, P99: 1, Max: 1})
+	if sidecar.Memory != nil {
+		t.Errorf("expected no memory distribution of the sidecar, got %+v", sidecar.Memory)
+	}
+
+	// pod usage sums its containers at every sample
+	pod := usage.Pod("default", "web-0")
+	assertDistribution(t, "pod cpu", pod.CPU, Distribution{Samples: 100, P50: 51, P95: 96, P99: 100, Max: 101})
+	assertDistribution(t, "pod memory", pod.Memory, Distribution{Samples: 100, P50: 512, P95: 512, P99: 512, Max: 512})
+
+	dns := usage.Pod("kube-system", "dns-0")
+	assertDistribution(t, "dns cpu", dns.CPU, Distribution{Samples: 2, P50: 0.25, P95: 0.5, P99: 0.5, Max: 0.5})
+	if dns.Memory != nil {
+		t.Errorf("expected no memory distribution of dns-0, got %+v", dns.Memory)
+	}
+
+	if usage.Pod("default", "missing") != nil || usage.Container("default", "web-0", "missing") != nil {
+		t.Error("expected no usage of missing pods and containers")
+	}
+}
+
+func TestCollectError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"status":"error","errorType":"execution","error":"query timed out"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewCollector(NewPrometheusClient(server.URL, 2), testOptions()).Collect()
+	if err == nil || !strings.Contains(err.Error(), "query timed out") {
+		t.Errorf("expected the query error, got %v", err)
+	}
+}
+
+func TestQueryRange(t *testing.T) {
+	server := metricstest.NewServer()
+	defer server.Close()
+	server.Add("up", map[string]string{"job": "prometheus"}, hourly(1, math.NaN(), math.Inf(1), 0))
+
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+	series, err := NewPrometheusClient(server.URL+"/", 1).QueryRange("up", start, end, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || series[0].Labels["job"] != "prometheus" {
+		t.Fatalf("expected the series of job prometheus, got %v", series)
+	}
+	// samples which are not a number are dropped
+	if len(series[0].Values) != 2 || series[0].Values[0].Value != 1 || series[0].Values[1].Value != 0 {
+		t.Errorf("expected the samples 1 and 0, got %d samples", len(series[0].Values))
+	}
+
+	queries := server.Queries()
+	if len(queries) != 1 {
+		t.Fatalf("expected 1 query, got %d", len(queries))
+	}
+	q := queries[0]
+	if q.Start != float64(start.Unix()) || q.End != float64(end.Unix()) || q.Params["step"] != "60" {
+		t.Errorf("expected a query from %d to %d by 60s, got %+v", start.Unix(), end.Unix(), q)
+	}
+}
+
+func TestQueryRangeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{
+			name:     "error status",
+			status:   http.StatusBadRequest,
+			body:     `{"status":"error","errorType":"bad_data","error":"parse error at char 3"}`,
+			expected: "bad_data: parse error at char 3",
+		},
+		{
+			name:     "not json",
+			status:   http.StatusBadGateway,
+			body:     `<html>502 Bad Gateway</html>`,
+			expected: "failed parsing response (status 502)",
+		},
+		{
+			name:     "not a matrix",
+			status:   http.StatusOK,
+			body:     `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			expected: "returned vector, expected matrix",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			_, err := NewPrometheusClient(server.URL, 1).QueryRange("up", time.Now().Add(-time.Hour), time.Now(), time.Minute)
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected an error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		_, err := NewPrometheusClient(server.URL, 1).QueryRange("up", time.Now().Add(-time.Hour), time.Now(), time.Minute)
+		if err == nil {
+			t.Error("expected an error for an unreachable server")
+		}
+	})
+}
+
+func TestQueryConcurrency(t *testing.T) {
+	server := metricstest.NewServer()
+	defer server.Close()
+	server.SetDelay(50 * time.Millisecond)
+
+	// the 10 daily chunks of every query are sent at once, and held by the
+	// semaphore of the client
+	options := testOptions()
+	options.Window = "10d"
+	if _, err := NewCollector(NewPrometheusClient(server.URL, 3), options).Collect(); err != nil {
+		t.Fatal(err)
+	}
+	if max := server.MaxInFlight(); max != 3 {
+		t.Errorf("expected at most 3 queries at once, got %d", max)
+	}
+}
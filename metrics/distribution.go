@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// Distribution summarizes the samples of a usage time series
+type Distribution struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50"`
+	P95     float64 `json:"p95"`
+	P99     float64 `json:"p99"`
+	Max     float64 `json:"max"`
+}
+
+// NewDistribution computes the percentiles of the values of the series, using
+// the nearest rank method. It returns nil for an empty series.
+func NewDistribution(series []*util.Vector) *Distribution {
+	if len(series) == 0 {
+		return nil
+	}
+
+	values := make([]float64, len(series))
+	for i, v := range series {
+		values[i] = v.Value
+	}
+	sort.Float64s(values)
+
+	return &Distribution{
+		Samples: len(values),
+		P50:     percentile(values, 50),
+		P95:     percentile(values, 95),
+		P99:     percentile(values, 99),
+		Max:     values[len(values)-1],
+	}
+}
+
+// Percentile returns the percentile of the distribution, which must be one of
+// 50, 95, 99 or 100 (the max). Other values are rounded up to the next one.
+func (d *Distribution) Percentile(p float64) float64 {
+	switch {
+	case p <= 50:
+		return d.P50
+	case p <= 95:
+		return d.P95
+	case p <= 99:
+		return d.P99
+	default:
+		return d.Max
+	}
+}
+
+// percentile returns the nearest rank percentile of the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
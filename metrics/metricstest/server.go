@@ -0,0 +1,170 @@
+package metricstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// Server is a Prometheus stand-in serving range queries out of fixed series, for
+// exercising the metrics collector without a Prometheus server.
+type Server struct {
+	*httptest.Server
+
+	lock    sync.Mutex
+	series  map[string][]*series
+	queries []Query
+
+	// delay holds every query for a while, so that concurrent queries overlap
+	delay       time.Duration
+	inFlight    int
+	maxInFlight int
+}
+
+// Query is a range query received by the server
+type Query struct {
+	Query  string
+	Start  float64
+	End    float64
+	Params map[string]string
+}
+
+type series struct {
+	labels map[string]string
+	values []*util.Vector
+}
+
+// NewServer starts a server without any series
+func NewServer() *Server {
+	s := &Server{series: make(map[string][]*series)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Add adds a series returned for the query. Only the samples within the range of
+// each request are returned.
+func (s *Server) Add(query string, labels map[string]string, values []*util.Vector) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.series[query] = append(s.series[query], &series{labels: labels, values: values})
+}
+
+// SetDelay holds every query for the delay before answering it
+func (s *Server) SetDelay(delay time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.delay = delay
+}
+
+// MaxInFlight returns the max number of queries the server answered at once
+func (s *Server) MaxInFlight() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.maxInFlight
+}
+
+// Queries returns the range queries received so far
+func (s *Server) Queries() []Query {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Query(nil), s.queries...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/query_range" {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, err.Error())
+		return
+	}
+
+	start, err := strconv.ParseFloat(r.Form.Get("start"), 64)
+	if err != nil {
+		writeError(w, "invalid start")
+		return
+	}
+	end, err := strconv.ParseFloat(r.Form.Get("end"), 64)
+	if err != nil {
+		writeError(w, "invalid end")
+		return
+	}
+
+	query := Query{
+		Query:  r.Form.Get("query"),
+		Start:  start,
+		End:    end,
+		Params: make(map[string]string),
+	}
+	for k := range r.Form {
+		if k != "query" && k != "start" && k != "end" {
+			query.Params[k] = r.Form.Get(k)
+		}
+	}
+
+	s.lock.Lock()
+	s.queries = append(s.queries, query)
+	matched := s.series[query.Query]
+	delay := s.delay
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.inFlight--
+		s.lock.Unlock()
+	}()
+	time.Sleep(delay)
+
+	type result struct {
+		Metric map[string]string `json:"metric"`
+		Values [][]interface{}   `json:"values"`
+	}
+	results := []result{}
+	for _, ser := range matched {
+		res := result{Metric: ser.labels}
+		for _, v := range ser.values {
+			if v.Timestamp >= start && v.Timestamp <= end {
+				res.Values = append(res.Values, []interface{}{v.Timestamp, strconv.FormatFloat(v.Value, 'f', -1, 64)})
+			}
+		}
+		if len(res.Values) > 0 {
+			results = append(results, res)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"resultType": "matrix",
+			"result":     results,
+		},
+	})
+}
+
+func writeError(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"status":    "error",
+		"errorType": "bad_data",
+		"error":     msg,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// Series is a single time series of a range query result
+type Series struct {
+	Labels map[string]string
+	Values []*util.Vector
+}
+
+// PrometheusClient runs range queries against the Prometheus HTTP API. The number
+// of concurrent queries is bounded by a semaphore shared by all callers.
+type PrometheusClient struct {
+	url       string
+	client    *http.Client
+	semaphore *util.Semaphore
+
+	// params are extra parameters sent with every query
+	params url.Values
+}
+
+// NewPrometheusClient creates a client of the Prometheus server at the url,
+// running at most maxConcurrency queries at once. TLS verification is skipped
+// when INSECURE_SKIP_VERIFY is set.
+func NewPrometheusClient(serverURL string, maxConcurrency int) *PrometheusClient {
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: env.GetInsecureSkipVerify()}
+
+	return &PrometheusClient{
+		url:       strings.TrimSuffix(serverURL, "/"),
+		client:    &http.Client{Transport: transport, Timeout: 5 * time.Minute},
+		semaphore: util.NewSemaphore(maxConcurrency),
+	}
+}
+
+// NewPrometheusClientFromEnv creates a client of PROMETHEUS_SERVER_ENDPOINT bounded
+// by MAX_QUERY_CONCURRENCY. It returns nil if no endpoint is configured.
+func NewPrometheusClientFromEnv() *PrometheusClient {
+	endpoint := env.GetPrometheusServerEndpoint()
+	if endpoint == "" {
+		return nil
+	}
+	return NewPrometheusClient(endpoint, env.GetMaxQueryConcurrency())
+}
+
+// NewThanosClient creates a client of the Thanos querier at the url, which serves
+// the Prometheus HTTP API. Every query is sent with the max_source_resolution,
+// e.g. 0s for raw data or 5m and 1h for downsampled data. The client shares the
+// semaphore of the prometheus client, so the queries of both clients are bounded
+// together.
+func NewThanosClient(serverURL string, prometheus *PrometheusClient, maxSourceResolution string) *PrometheusClient {
+	pc := NewPrometheusClient(serverURL, 1)
+	pc.semaphore = prometheus.semaphore
+	pc.params = url.Values{}
+	if maxSourceResolution != "" {
+		pc.params.Set("max_source_resolution", maxSourceResolution)
+	}
+	return pc
+}
+
+// URL returns the url of the Prometheus server
+func (pc *PrometheusClient) URL() string {
+	return pc.url
+}
+
+type queryRangeResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][]interface{}   `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// QueryRange runs the range query between start and end at the step resolution.
+// Samples which are not a number are dropped.
+func (pc *PrometheusClient) QueryRange(query string, start, end time.Time, step time.Duration) ([]*Series, error) {
+	values := url.Values{}
+	for k, v := range pc.params {
+		values[k] = v
+	}
+	values.Set("query", query)
+	values.Set("start", strconv.FormatInt(start.Unix(), 10))
+	values.Set("end", strconv.FormatInt(end.Unix(), 10))
+	values.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	pc.semaphore.Acquire()
+	defer pc.semaphore.Return()
+
+	resp, err := pc.client.PostForm(pc.url+"/api/v1/query_range", values)
+	if err != nil {
+		return nil, fmt.Errorf("query %s failed: %s", query, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("query %s failed reading response: %s", query, err)
+	}
+
+	var qr queryRangeResponse
+	if err := json.Unmarshal(body, &qr); err != nil {
+		return nil, fmt.Errorf("query %s failed parsing response (status %d): %s", query, resp.StatusCode, err)
+	}
+	if qr.Status != "success" {
+		return nil, fmt.Errorf("query %s failed: %s: %s", query, qr.ErrorType, qr.Error)
+	}
+	if qr.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("query %s returned %s, expected matrix", query, qr.Data.ResultType)
+	}
+
+	result := make([]*Series, 0, len(qr.Data.Result))
+	for _, r := range qr.Data.Result {
+		series := &Series{
+			Labels: r.Metric,
+			Values: make([]*util.Vector, 0, len(r.Values)),
+		}
+		for _, sample := range r.Values {
+			v, ok := parseSample(sample)
+			if ok {
+				series.Values = append(series.Values, v)
+			}
+		}
+		result = append(result, series)
+	}
+	return result, nil
+}
+
+// parseSample parses a [ <unix time>, "<value>" ] sample
+func parseSample(sample []interface{}) (*util.Vector, bool) {
+	if len(sample) != 2 {
+		return nil, false
+	}
+	ts, ok := sample[0].(float64)
+	if !ok {
+		return nil, false
+	}
+	s, ok := sample[1].(string)
+	if !ok {
+		return nil, false
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, false
+	}
+	return &util.Vector{Timestamp: ts, Value: value}, true
+}
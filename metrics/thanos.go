@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// ThanosQuerier routes range queries between Prometheus and Thanos. Prometheus
+// only keeps the last retention of history, so the part of a range older than
+// the retention is queried from Thanos instead. Thanos only has the blocks
+// uploaded by the sidecars, which lag behind by the offset, so its part of the
+// range is capped at the offset and the remainder is left to Prometheus.
+type ThanosQuerier struct {
+	prometheus Querier
+	thanos     Querier
+	retention  time.Duration
+	offset     time.Duration
+
+	// now returns the current time the retention and offset are relative to
+	now func() time.Time
+}
+
+// NewThanosQuerier creates a querier sending the part of every range older than
+// the retention to thanos, and the rest to prometheus
+func NewThanosQuerier(prometheus Querier, thanos Querier, retention time.Duration, offset time.Duration) *ThanosQuerier {
+	return &ThanosQuerier{
+		prometheus: prometheus,
+		thanos:     thanos,
+		retention:  retention,
+		offset:     offset,
+		now:        time.Now,
+	}
+}
+
+// NewThanosQuerierFromEnv creates a querier routing between the prometheus client
+// and a client of THANOS_QUERY_URL, sending THANOS_MAX_SOURCE_RESOLUTION with
+// every query. Both clients share the MAX_QUERY_CONCURRENCY of the prometheus
+// client. The retention is read from PROMETHEUS_RETENTION and the offset from
+// THANOS_QUERY_OFFSET.
+func NewThanosQuerierFromEnv(prometheus *PrometheusClient) (*ThanosQuerier, error) {
+	thanosURL := env.GetThanosQueryUrl()
+	if thanosURL == "" {
+		return nil, fmt.Errorf("%s is required when %s is set", env.ThanosQueryUrlEnvVar, env.ThanosEnabledEnvVar)
+	}
+
+	retention, err := util.ParseDuration(env.GetPrometheusRetention())
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", env.PrometheusRetentionEnvVar, err)
+	}
+	offset, err := util.ParseDuration(env.GetThanosOffset())
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", env.ThanosOffsetEnvVar, err)
+	}
+
+	thanos := NewThanosClient(thanosURL, prometheus, env.GetThanosMaxSourceResolution())
+	return NewThanosQuerier(prometheus, thanos, *retention, *offset), nil
+}
+
+// QueryRange runs the range query against Prometheus, Thanos or both. When both
+// are queried the series with the same labels are merged, preferring the
+// Prometheus samples where the two ranges overlap.
+func (tq *ThanosQuerier) QueryRange(query string, start, end time.Time, step time.Duration) ([]*Series, error) {
+	now := tq.now()
+	retained := now.Add(-tq.retention)
+	if !start.Before(retained) {
+		return tq.prometheus.QueryRange(query, start, end, step)
+	}
+	if !end.After(retained) {
+		return tq.thanos.QueryRange(query, start, end, step)
+	}
+
+	// thanos covers the range up to its offset and prometheus the retained part
+	// of the range, which overlap when the retention exceeds the offset
+	thanosEnd := now.Add(-tq.offset)
+	if end.Before(thanosEnd) {
+		thanosEnd = end
+	}
+	if thanosEnd.Before(retained) {
+		klog.Warningf("Thanos offset %s exceeds the Prometheus retention %s, samples between %s and %s are missing", tq.offset, tq.retention, thanosEnd, retained)
+	}
+
+	var thanosResult, prometheusResult []*Series
+	var thanosErr, prometheusErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		thanosResult, thanosErr = tq.thanos.QueryRange(query, start, thanosEnd, step)
+	}()
+	go func() {
+		defer wg.Done()
+		prometheusResult, prometheusErr = tq.prometheus.QueryRange(query, retained, end, step)
+	}()
+	wg.Wait()
+
+	if thanosErr != nil {
+		return nil, fmt.Errorf("thanos %s", thanosErr)
+	}
+	if prometheusErr != nil {
+		return nil, prometheusErr
+	}
+	return mergeSeries(prometheusResult, thanosResult), nil
+}
+
+// mergeSeries merges the series of both results which have the same labels,
+// preferring the samples of the first result
+func mergeSeries(first []*Series, second []*Series) []*Series {
+	var result []*Series
+	byLabels := make(map[string]*Series)
+	for _, s := range append(append([]*Series(nil), first...), second...) {
+		key := labelsKey(s.Labels)
+		merged, ok := byLabels[key]
+		if !ok {
+			merged = &Series{Labels: s.Labels}
+			byLabels[key] = merged
+			result = append(result, merged)
+		}
+		merged.Values = util.ApplyVectorOp(merged.Values, s.Values, mergeOp)
+	}
+	return result
+}
+
+// labelsKey returns a key identifying the label set
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteString("=")
+		sb.WriteString(labels[name])
+		sb.WriteString(",")
+	}
+	return sb.String()
+}
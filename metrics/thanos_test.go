@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// inFlight counts the queries answered at once across several servers
+type inFlight struct {
+	lock    sync.Mutex
+	current int
+	max     int
+}
+
+// handler answers every query with an empty matrix after the delay
+func (f *inFlight) handler(delay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.lock.Lock()
+		f.current++
+		if f.current > f.max {
+			f.max = f.current
+		}
+		f.lock.Unlock()
+
+		time.Sleep(delay)
+
+		f.lock.Lock()
+		f.current--
+		f.lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	})
+}
+
+func TestThanosQueryConcurrency(t *testing.T) {
+	var f inFlight
+	prometheusServer := httptest.NewServer(f.handler(50 * time.Millisecond))
+	defer prometheusServer.Close()
+	thanosServer := httptest.NewServer(f.handler(50 * time.Millisecond))
+	defer thanosServer.Close()
+
+	prometheus := NewPrometheusClient(prometheusServer.URL, 2)
+	thanos := NewThanosClient(thanosServer.URL, prometheus, "5m")
+	querier := NewThanosQuerier(prometheus, thanos, 2*24*time.Hour, 0)
+
+	// the chunks older than the retention go to thanos, the others to
+	// prometheus, and both share the concurrency of the prometheus client
+	options := testOptions()
+	options.Window = "6d"
+	if _, err := NewCollector(querier, options).Collect(); err != nil {
+		t.Fatal(err)
+	}
+	if f.max != 2 {
+		t.Errorf("expected at most 2 queries at once across prometheus and thanos, got %d", f.max)
+	}
+}
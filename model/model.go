@@ -0,0 +1,307 @@
+package model
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/inventory"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+)
+
+// Node is the utilization and cost of a node
+type Node struct {
+	Name        string              `json:"name"`
+	NodeGroup   string              `json:"nodeGroup"`
+	Pods        int                 `json:"pods"`
+	Allocatable inventory.Resources `json:"allocatable"`
+	Requested   inventory.Resources `json:"requested"`
+	Cost        *cost.NodeCost      `json:"cost,omitempty"`
+}
+
+// Group is the utilization and cost of the nodes of a node group
+type Group struct {
+	ID          string              `json:"id"`
+	Nodes       int                 `json:"nodes"`
+	Unpriced    int                 `json:"unpriced"`
+	Pods        int                 `json:"pods"`
+	Allocatable inventory.Resources `json:"allocatable"`
+	Requested   inventory.Resources `json:"requested"`
+	Hourly      float64             `json:"hourly"`
+}
+
+type nodeState struct {
+	node      *v1.Node
+	signature string
+	cost      *cost.NodeCost
+}
+
+type podState struct {
+	node     string
+	requests inventory.Resources
+}
+
+// nodeUsage is the sum of the active pods scheduled on a node
+type nodeUsage struct {
+	pods      int
+	requested inventory.Resources
+}
+
+// Model maintains the node group membership, the requests of the pods on every
+// node and the node costs from the changes delivered by the cluster cache. Every
+// change only updates the affected node and its group, rather than recomputing
+// them from the full lists of nodes and pods.
+type Model struct {
+	lock   sync.RWMutex
+	pricer *cost.Pricer
+
+	nodes  map[string]*nodeState
+	pods   map[string]*podState
+	usage  map[string]*nodeUsage
+	groups map[string]*Group
+
+	// onChange is called after every change, outside of the lock
+	onChange func()
+}
+
+// NewModel creates an empty model. Nodes are priced by the pricer, or left
+// unpriced when it is nil.
+func NewModel(pricer *cost.Pricer) *Model {
+	return &Model{
+		pricer: pricer,
+		nodes:  make(map[string]*nodeState),
+		pods:   make(map[string]*podState),
+		usage:  make(map[string]*nodeUsage),
+		groups: make(map[string]*Group),
+	}
+}
+
+// Subscribe feeds the model with the nodes and pods of the cache and their
+// changes. onChange, if set, is called after every change.
+func (m *Model) Subscribe(cache clustercache.ClusterCache, onChange func()) {
+	m.onChange = onChange
+	cache.SubscribeNodes(m.OnNode)
+	cache.SubscribePods(m.OnPod)
+}
+
+// OnNode applies the addition (old is nil), update or deletion (new is nil) of
+// a node. Nodes are repriced only when their group labels change. Nodes are
+// priced outside of the lock, so price lookups don't hold up the readers and the
+// other changes of the model.
+func (m *Model) OnNode(old *v1.Node, new *v1.Node) {
+	name := nodeName(old, new)
+	var st *nodeState
+	if new != nil {
+		st = &nodeState{node: new, signature: nodegroup.Signature(new)}
+	}
+
+	// the node is priced when its group labels changed, and the check is
+	// repeated once priced as the node may have changed in the meantime
+	var priced *cost.NodeCost
+	var prev *nodeState
+	var ok bool
+	for {
+		m.lock.Lock()
+		prev, ok = m.nodes[name]
+		if st == nil || m.pricer == nil || priced != nil || (ok && prev.signature == st.signature) {
+			break
+		}
+		m.lock.Unlock()
+		priced = m.pricer.Price(new, "")
+	}
+
+	if ok {
+		m.removeNode(prev)
+		delete(m.nodes, name)
+	}
+	if st != nil {
+		if ok && prev.signature == st.signature {
+			st.cost = prev.cost
+		} else {
+			st.cost = priced
+		}
+		m.nodes[name] = st
+		m.addNode(st)
+	}
+
+	m.lock.Unlock()
+	m.changed()
+}
+
+// OnPod applies the addition (old is nil), update or deletion (new is nil) of a
+// pod. Only pods scheduled on a node which are neither succeeded nor failed
+// count towards the requests of the node.
+func (m *Model) OnPod(old *v1.Pod, new *v1.Pod) {
+	m.lock.Lock()
+
+	key := podKey(old, new)
+	if prev, ok := m.pods[key]; ok {
+		m.addUsage(prev.node, -1, prev.requests.Scale(-1))
+		delete(m.pods, key)
+	}
+	if new != nil && new.Spec.NodeName != "" && new.Status.Phase != v1.PodSucceeded && new.Status.Phase != v1.PodFailed {
+		st := &podState{node: new.Spec.NodeName}
+		for _, container := range new.Spec.Containers {
+			st.requests = st.requests.Add(inventory.Resources{
+				CPU:    container.Resources.Requests.Cpu().MilliValue(),
+				Memory: container.Resources.Requests.Memory().Value(),
+			})
+		}
+		m.pods[key] = st
+		m.addUsage(st.node, 1, st.requests)
+	}
+
+	m.lock.Unlock()
+	m.changed()
+}
+
+// Nodes returns the utilization and cost of every node, ordered by name
+func (m *Model) Nodes() []*Node {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	ids := m.groupIDs()
+	nodes := make([]*Node, 0, len(m.nodes))
+	for name, st := range m.nodes {
+		n := &Node{
+			Name:        name,
+			NodeGroup:   ids[st.signature],
+			Allocatable: allocatable(st.node),
+		}
+		if u, ok := m.usage[name]; ok {
+			n.Pods = u.pods
+			n.Requested = u.requested
+		}
+		if st.cost != nil {
+			c := *st.cost
+			c.NodeGroup = n.NodeGroup
+			n.Cost = &c
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+// NodeGroups returns the utilization and cost of every node group, numbered as
+// by nodegroup.Group
+func (m *Model) NodeGroups() []*Group {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	ids := m.groupIDs()
+	groups := make([]*Group, 0, len(m.groups))
+	for signature, g := range m.groups {
+		group := *g
+		group.ID = ids[signature]
+		groups = append(groups, &group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, _ := strconv.Atoi(groups[i].ID)
+		b, _ := strconv.Atoi(groups[j].ID)
+		return a < b
+	})
+	return groups
+}
+
+// groupIDs numbers the signatures of the current groups in order
+func (m *Model) groupIDs() map[string]string {
+	signatures := make([]string, 0, len(m.groups))
+	for signature := range m.groups {
+		signatures = append(signatures, signature)
+	}
+	sort.Strings(signatures)
+
+	ids := make(map[string]string, len(signatures))
+	for i, signature := range signatures {
+		ids[signature] = strconv.Itoa(i)
+	}
+	return ids
+}
+
+// addNode adds the node, along with the pods already scheduled on it, to its group
+func (m *Model) addNode(st *nodeState) {
+	g, ok := m.groups[st.signature]
+	if !ok {
+		g = &Group{}
+		m.groups[st.signature] = g
+	}
+	m.applyNode(g, st, 1)
+}
+
+// removeNode removes the node, along with its pods, from its group
+func (m *Model) removeNode(st *nodeState) {
+	g := m.groups[st.signature]
+	m.applyNode(g, st, -1)
+	if g.Nodes == 0 {
+		delete(m.groups, st.signature)
+	}
+}
+
+func (m *Model) applyNode(g *Group, st *nodeState, sign int) {
+	g.Nodes += sign
+	g.Allocatable = g.Allocatable.Add(allocatable(st.node).Scale(int64(sign)))
+	if u, ok := m.usage[st.node.Name]; ok {
+		g.Pods += sign * u.pods
+		g.Requested = g.Requested.Add(u.requested.Scale(int64(sign)))
+	}
+	if st.cost == nil || st.cost.Reason != "" {
+		g.Unpriced += sign
+	} else {
+		g.Hourly += float64(sign) * st.cost.Hourly
+	}
+}
+
+// addUsage adds the pods and requests to the node and, when the node is known,
+// to its group
+func (m *Model) addUsage(node string, pods int, requests inventory.Resources) {
+	u, ok := m.usage[node]
+	if !ok {
+		u = &nodeUsage{}
+		m.usage[node] = u
+	}
+	u.pods += pods
+	u.requested = u.requested.Add(requests)
+	if u.pods == 0 {
+		delete(m.usage, node)
+	}
+
+	if st, ok := m.nodes[node]; ok {
+		g := m.groups[st.signature]
+		g.Pods += pods
+		g.Requested = g.Requested.Add(requests)
+	}
+}
+
+func (m *Model) changed() {
+	if m.onChange != nil {
+		m.onChange()
+	}
+}
+
+func allocatable(node *v1.Node) inventory.Resources {
+	return inventory.Resources{
+		CPU:    node.Status.Allocatable.Cpu().MilliValue(),
+		Memory: node.Status.Allocatable.Memory().Value(),
+	}
+}
+
+func nodeName(old *v1.Node, new *v1.Node) string {
+	if new != nil {
+		return new.Name
+	}
+	return old.Name
+}
+
+func podKey(old *v1.Pod, new *v1.Pod) string {
+	if new != nil {
+		return new.Namespace + "/" + new.Name
+	}
+	return old.Namespace + "/" + old.Name
+}
@@ -0,0 +1,283 @@
+package model
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/inventory"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+)
+
+// stubProvider prices m5.large at 0.1 and r5.xlarge at 0.25 an hour in every
+// region. Calls wait for unblock when it is set, once they signal started.
+type stubProvider struct {
+	started chan struct{}
+	unblock chan struct{}
+}
+
+func (p *stubProvider) InstanceTypes(key pricing.Key) ([]*pricing.InstanceType, error) {
+	if p.unblock != nil {
+		close(p.started)
+		<-p.unblock
+	}
+	return []*pricing.InstanceType{
+		{Name: "m5.large", VCPUs: 2, Memory: 8, Hourly: 0.1},
+		{Name: "r5.xlarge", VCPUs: 4, Memory: 32, Hourly: 0.25},
+	}, nil
+}
+
+// cluster is the current nodes and pods fed to a model, recomputed from scratch
+// to check the model against
+type cluster struct {
+	t        *testing.T
+	model    *Model
+	provider pricing.Provider
+	nodes    map[string]*v1.Node
+	pods     map[string]*v1.Pod
+}
+
+func newCluster(t *testing.T) *cluster {
+	provider := &stubProvider{}
+	return &cluster{
+		t:        t,
+		model:    NewModel(cost.NewPricer(provider, nil)),
+		provider: provider,
+		nodes:    make(map[string]*v1.Node),
+		pods:     make(map[string]*v1.Pod),
+	}
+}
+
+func (c *cluster) setNode(node *v1.Node) {
+	old := c.nodes[node.Name]
+	c.nodes[node.Name] = node
+	c.model.OnNode(old, node)
+}
+
+func (c *cluster) deleteNode(name string) {
+	old := c.nodes[name]
+	delete(c.nodes, name)
+	c.model.OnNode(old, nil)
+}
+
+func (c *cluster) setPod(pod *v1.Pod) {
+	key := pod.Namespace + "/" + pod.Name
+	old := c.pods[key]
+	c.pods[key] = pod
+	c.model.OnPod(old, pod)
+}
+
+func (c *cluster) deletePod(namespace string, name string) {
+	key := namespace + "/" + name
+	old := c.pods[key]
+	delete(c.pods, key)
+	c.model.OnPod(old, nil)
+}
+
+// recompute returns the nodes and groups of the current nodes and pods computed
+// from scratch, with node groups numbered and nodes priced like the reports
+func (c *cluster) recompute() ([]*Node, []*Group) {
+	var nodes []*v1.Node
+	for _, node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	groups, node2group := nodegroup.Group(nodes)
+	report := cost.NewReport(nodes, node2group, c.provider, nil)
+	costs := make(map[string]*cost.NodeCost)
+	for _, nc := range append(report.Nodes, report.Unknown...) {
+		costs[nc.Name] = nc
+	}
+
+	byName := make(map[string]*Node)
+	var expectedNodes []*Node
+	for _, node := range nodes {
+		n := &Node{
+			Name:        node.Name,
+			NodeGroup:   node2group[node.Name],
+			Allocatable: allocatable(node),
+			Cost:        costs[node.Name],
+		}
+		byName[node.Name] = n
+		expectedNodes = append(expectedNodes, n)
+	}
+	sort.Slice(expectedNodes, func(i, j int) bool {
+		return expectedNodes[i].Name < expectedNodes[j].Name
+	})
+	for _, pod := range c.pods {
+		n, ok := byName[pod.Spec.NodeName]
+		if !ok || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		n.Pods++
+		for _, container := range pod.Spec.Containers {
+			n.Requested = n.Requested.Add(inventory.Resources{
+				CPU:    container.Resources.Requests.Cpu().MilliValue(),
+				Memory: container.Resources.Requests.Memory().Value(),
+			})
+		}
+	}
+
+	expectedGroups := []*Group{}
+	for i, group := range groups {
+		g := &Group{ID: group.ID, Nodes: len(group.Nodes)}
+		for _, node := range group.Nodes {
+			n := byName[node.Name]
+			g.Pods += n.Pods
+			g.Allocatable = g.Allocatable.Add(n.Allocatable)
+			g.Requested = g.Requested.Add(n.Requested)
+		}
+		g.Unpriced = report.NodeGroups[i].Unknown
+		g.Hourly = report.NodeGroups[i].Hourly
+		expectedGroups = append(expectedGroups, g)
+	}
+	return expectedNodes, expectedGroups
+}
+
+// check compares the model with the recomputed nodes and groups
+func (c *cluster) check(step string) {
+	c.t.Helper()
+	expectedNodes, expectedGroups := c.recompute()
+
+	nodes := c.model.Nodes()
+	if len(nodes) != len(expectedNodes) {
+		c.t.Fatalf("%s: expected %d nodes, got %d", step, len(expectedNodes), len(nodes))
+	}
+	for i, n := range nodes {
+		if !reflect.DeepEqual(n, expectedNodes[i]) {
+			c.t.Errorf("%s: expected the node %+v, got %+v", step, *expectedNodes[i], *n)
+		}
+	}
+
+	groups := c.model.NodeGroups()
+	if len(groups) != len(expectedGroups) {
+		c.t.Fatalf("%s: expected %d node groups, got %d", step, len(expectedGroups), len(groups))
+	}
+	for i, g := range groups {
+		e := expectedGroups[i]
+		// the hourly cost is added and subtracted as nodes come and go
+		hourly := g.Hourly
+		g.Hourly = e.Hourly
+		if !reflect.DeepEqual(g, e) || math.Abs(hourly-e.Hourly) > 1e-9 {
+			g.Hourly = hourly
+			c.t.Errorf("%s: expected the node group %+v, got %+v", step, *e, *g)
+		}
+	}
+}
+
+func node(name string, instanceType string, pool string) *v1.Node {
+	return clustercachetest.Node(name, instanceType, "2", "8Gi", map[string]string{
+		"topology.kubernetes.io/region": "us-east-1",
+		"pool":                          pool,
+	})
+}
+
+func pod(name string, node string, cpu string) *v1.Pod {
+	return clustercachetest.Pod("default", name, node, cpu, "512Mi", nil)
+}
+
+func TestModel(t *testing.T) {
+	c := newCluster(t)
+	c.check("empty")
+
+	c.setNode(node("node-a", "m5.large", "general"))
+	c.setNode(node("node-b", "m5.large", "general"))
+	c.setNode(node("node-c", "r5.xlarge", "memory"))
+	c.setNode(node("node-d", "x9.unknown", "other"))
+	c.check("nodes added")
+
+	c.setPod(pod("web-1", "node-a", "500m"))
+	c.setPod(pod("web-2", "node-b", "500m"))
+	c.setPod(pod("db-0", "node-c", "1"))
+	c.setPod(pod("logs", "node-d", "100m"))
+	c.setPod(pod("pending", "", "250m"))
+	// pods of nodes which aren't known yet count once the node is
+	c.setPod(pod("early", "node-e", "100m"))
+	c.check("pods added")
+
+	c.setPod(pod("pending", "node-a", "250m"))
+	c.check("pod scheduled")
+
+	c.setPod(pod("web-1", "node-a", "1"))
+	c.check("pod requests updated")
+
+	done := pod("db-0", "node-c", "1")
+	done.Status.Phase = v1.PodSucceeded
+	c.setPod(done)
+	c.check("pod succeeded")
+
+	// node-b joins the group of node-c, keeping its price
+	c.setNode(node("node-b", "m5.large", "memory"))
+	c.check("node relabeled")
+
+	// node-c is repriced as its instance type changes
+	c.setNode(node("node-c", "m5.large", "memory"))
+	c.check("node instance type changed")
+
+	// only the allocatable of node-a changes, it keeps its group and price
+	resized := node("node-a", "m5.large", "general")
+	resized.Status.Allocatable = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("1900m"),
+		v1.ResourceMemory: resource.MustParse("7Gi"),
+	}
+	c.setNode(resized)
+	c.check("node allocatable updated")
+
+	c.setNode(node("node-e", "m5.large", "general"))
+	c.check("node of an earlier pod added")
+
+	// the pods of a deleted node count again once it is back
+	c.deleteNode("node-a")
+	c.check("node deleted")
+	c.setNode(node("node-a", "m5.large", "general"))
+	c.check("node added back")
+
+	for key, p := range c.pods {
+		c.deletePod(p.Namespace, p.Name)
+		c.check("pod " + key + " deleted")
+	}
+	for name := range c.nodes {
+		c.deleteNode(name)
+		c.check("node " + name + " deleted")
+	}
+}
+
+func TestModelPricing(t *testing.T) {
+	provider := &stubProvider{started: make(chan struct{}), unblock: make(chan struct{})}
+	m := NewModel(cost.NewPricer(provider, nil))
+
+	done := make(chan struct{})
+	go func() {
+		m.OnNode(nil, node("node-a", "m5.large", "general"))
+		close(done)
+	}()
+	<-provider.started
+
+	// the model is read and updated while the node is priced
+	read := make(chan struct{})
+	go func() {
+		m.OnPod(nil, pod("web-1", "node-a", "500m"))
+		m.Nodes()
+		m.NodeGroups()
+		close(read)
+	}()
+	select {
+	case <-read:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the model to be available while a node is priced")
+	}
+
+	close(provider.unblock)
+	<-done
+	nodes := m.Nodes()
+	if len(nodes) != 1 || nodes[0].Pods != 1 || nodes[0].Cost == nil || nodes[0].Cost.Hourly != 0.1 {
+		t.Errorf("expected the priced node with its pod, got %+v", nodes)
+	}
+}
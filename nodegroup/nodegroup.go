@@ -0,0 +1,110 @@
+package nodegroup
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// IgnoredLabels are the node labels which differ between otherwise identical
+// nodes, such as the hostname and zone, and don't participate in grouping
+var IgnoredLabels = []string{
+	"kubernetes.io/hostname",
+	"topology.kubernetes.io/zone",
+	"failure-domain.beta.kubernetes.io/zone",
+	"logzio/az",
+}
+
+// NodeGroup is a set of nodes sharing the same labels, apart from the ignored
+// labels
+type NodeGroup struct {
+	ID    string     `json:"id"`
+	Nodes []*v1.Node `json:"-"`
+
+	// Labels are the labels of the first node of the group
+	Labels map[string]string `json:"labels"`
+
+	// CommonLabels are the label keys set on every node of the cluster,
+	// UniqueLabels the others and IgnoredLabels the keys which were ignored
+	CommonLabels  []string `json:"commonLabels"`
+	UniqueLabels  []string `json:"uniqueLabels"`
+	IgnoredLabels []string `json:"ignoredLabels"`
+}
+
+// Group groups the nodes by their labels, ignoring IgnoredLabels. Groups are
+// ordered by their labels and numbered from 0, so the same nodes always get the
+// same group ids. It returns the groups along with the group id of every node.
+func Group(nodes []*v1.Node) ([]*NodeGroup, map[string]string) {
+	labelsStats := make(map[string]int)
+	bySignature := make(map[string][]*v1.Node)
+	for _, node := range nodes {
+		for k := range node.Labels {
+			labelsStats[k]++
+		}
+		signature := Signature(node)
+		bySignature[signature] = append(bySignature[signature], node)
+	}
+
+	signatures := make([]string, 0, len(bySignature))
+	for signature := range bySignature {
+		signatures = append(signatures, signature)
+	}
+	sort.Strings(signatures)
+
+	groups := make([]*NodeGroup, 0, len(signatures))
+	node2group := make(map[string]string, len(nodes))
+	for i, signature := range signatures {
+		members := bySignature[signature]
+		sort.Slice(members, func(a, b int) bool {
+			return members[a].Name < members[b].Name
+		})
+
+		group := &NodeGroup{
+			ID:     strconv.Itoa(i),
+			Nodes:  members,
+			Labels: members[0].Labels,
+		}
+		for _, key := range sortedKeys(group.Labels) {
+			if util.Contains(IgnoredLabels, key) {
+				group.IgnoredLabels = append(group.IgnoredLabels, key)
+			}
+			if labelsStats[key] == len(nodes) {
+				group.CommonLabels = append(group.CommonLabels, key)
+			} else {
+				group.UniqueLabels = append(group.UniqueLabels, key)
+			}
+		}
+
+		for _, node := range members {
+			node2group[node.Name] = group.ID
+		}
+		groups = append(groups, group)
+	}
+	return groups, node2group
+}
+
+// Signature returns the labels of the node, apart from the ignored labels, which
+// identify its group. Group ids are assigned by the order of the signatures.
+func Signature(node *v1.Node) string {
+	var nodeLabels []string
+	for k, v := range node.Labels {
+		if !util.Contains(IgnoredLabels, k) {
+			nodeLabels = append(nodeLabels, k+":"+v)
+		}
+	}
+	sort.Strings(nodeLabels)
+	return strings.Join(nodeLabels, ",")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,243 @@
+package optimizer
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// Template holds the labels and taints of the nodes of a node group. Candidate
+// nodes inherit them, with the instance type labels set to the candidate instance
+// type.
+type Template struct {
+	Labels map[string]string `json:"labels"`
+	Taints []v1.Taint        `json:"taints"`
+}
+
+// newTemplate creates a template from an existing node of the group. The hostname
+// and zone labels are dropped since they vary between the nodes of the group.
+func newTemplate(node *v1.Node) *Template {
+	t := &Template{
+		Labels: make(map[string]string),
+		Taints: node.Spec.Taints,
+	}
+	for k, v := range node.Labels {
+		if k == v1.LabelHostname || isZoneKey(k) {
+			continue
+		}
+		t.Labels[k] = v
+	}
+	return t
+}
+
+// LabelsFor returns the labels of a node of the given instance type in the zone.
+// The zone labels are omitted when the zone is empty.
+func (t *Template) LabelsFor(instanceType string, zone string) map[string]string {
+	nodeLabels := make(map[string]string, len(t.Labels)+4)
+	for k, v := range t.Labels {
+		nodeLabels[k] = v
+	}
+	nodeLabels[v1.LabelInstanceType] = instanceType
+	nodeLabels[v1.LabelInstanceTypeStable] = instanceType
+	if zone != "" {
+		nodeLabels[v1.LabelZoneFailureDomain] = zone
+		nodeLabels[v1.LabelZoneFailureDomainStable] = zone
+	}
+	return nodeLabels
+}
+
+// Unschedulable is a pod which cannot be placed on a candidate instance type,
+// along with the constraint it violates.
+type Unschedulable struct {
+	Pod    *Pod   `json:"pod"`
+	Reason string `json:"reason"`
+}
+
+// checkNode returns the first scheduling constraint of the pod violated by a node
+// with the given labels and taints, or an empty string if the pod can be
+// scheduled on it.
+func checkNode(pod *Pod, nodeLabels map[string]string, taints []v1.Taint) string {
+	for k, v := range pod.NodeSelector {
+		if nodeLabels[k] != v {
+			return fmt.Sprintf("node selector %s=%s", k, v)
+		}
+	}
+
+	if pod.Affinity != nil && pod.Affinity.NodeAffinity != nil {
+		required := pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if required != nil && !matchesNodeSelectorTerms(required.NodeSelectorTerms, nodeLabels) {
+			return "required node affinity"
+		}
+	}
+
+	for i := range taints {
+		taint := &taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(pod.Tolerations, taint) {
+			return fmt.Sprintf("untolerated taint %s", taint.ToString())
+		}
+	}
+
+	return ""
+}
+
+func toleratesTaint(tolerations []v1.Toleration, taint *v1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNodeSelectorTerms returns true if any of the terms matches the labels.
+// The expressions of a single term are ANDed.
+func matchesNodeSelectorTerms(terms []v1.NodeSelectorTerm, nodeLabels map[string]string) bool {
+	for _, term := range terms {
+		if len(term.MatchExpressions) == 0 {
+			continue
+		}
+
+		matches := true
+		for _, expr := range term.MatchExpressions {
+			if !matchesNodeSelectorRequirement(expr, nodeLabels) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesNodeSelectorRequirement(req v1.NodeSelectorRequirement, nodeLabels map[string]string) bool {
+	value, exists := nodeLabels[req.Key]
+
+	switch req.Operator {
+	case v1.NodeSelectorOpIn:
+		return exists && util.Contains(req.Values, value)
+	case v1.NodeSelectorOpNotIn:
+		return !exists || !util.Contains(req.Values, value)
+	case v1.NodeSelectorOpExists:
+		return exists
+	case v1.NodeSelectorOpDoesNotExist:
+		return !exists
+	case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+		if !exists || len(req.Values) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		expected, err := strconv.ParseInt(req.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if req.Operator == v1.NodeSelectorOpGt {
+			return actual > expected
+		}
+		return actual < expected
+	default:
+		return false
+	}
+}
+
+// antiAffinityTerm is a parsed required pod anti-affinity term
+type antiAffinityTerm struct {
+	selector    labels.Selector
+	namespaces  []string
+	topologyKey string
+}
+
+// matches returns true if the term selects the pod
+func (t *antiAffinityTerm) matches(pod *Pod) bool {
+	return util.Contains(t.namespaces, pod.Namespace) && t.selector.Matches(labels.Set(pod.Labels))
+}
+
+// antiAffinity evaluates the required pod anti-affinity between the pods of a
+// problem. Only terms with a per node topology key are evaluated when packing.
+type antiAffinity struct {
+	pods  []*Pod
+	terms [][]*antiAffinityTerm
+}
+
+// newAntiAffinity parses the anti-affinity terms of the pods, returning nil if
+// none of the pods declares any.
+func newAntiAffinity(pods []*Pod) *antiAffinity {
+	aa := &antiAffinity{
+		pods:  pods,
+		terms: make([][]*antiAffinityTerm, len(pods)),
+	}
+
+	found := false
+	for i, pod := range pods {
+		if pod.Affinity == nil || pod.Affinity.PodAntiAffinity == nil {
+			continue
+		}
+
+		for _, term := range pod.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if term.TopologyKey != v1.LabelHostname {
+				continue
+			}
+
+			selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+			if err != nil {
+				klog.V(3).Infof("Ignoring invalid anti-affinity of pod %s/%s: %s", pod.Namespace, pod.Name, err)
+				continue
+			}
+
+			namespaces := term.Namespaces
+			if len(namespaces) == 0 {
+				namespaces = []string{pod.Namespace}
+			}
+
+			aa.terms[i] = append(aa.terms[i], &antiAffinityTerm{
+				selector:    selector,
+				namespaces:  namespaces,
+				topologyKey: term.TopologyKey,
+			})
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return aa
+}
+
+// conflicts returns true if placing pod i on a node holding the members violates
+// the anti-affinity of either side.
+func (aa *antiAffinity) conflicts(i int, members []int) bool {
+	if aa == nil {
+		return false
+	}
+
+	for _, j := range members {
+		if len(aa.terms[i]) == 0 && len(aa.terms[j]) == 0 {
+			continue
+		}
+		for _, term := range aa.terms[i] {
+			if term.matches(aa.pods[j]) {
+				return true
+			}
+		}
+		for _, term := range aa.terms[j] {
+			if term.matches(aa.pods[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package optimizer
+
+import (
+	"bytes"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// requiredAffinity returns a required node affinity of a single expression
+func requiredAffinity(key string, op v1.NodeSelectorOperator, values ...string) *v1.Affinity {
+	return &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{{
+				MatchExpressions: []v1.NodeSelectorRequirement{{Key: key, Operator: op, Values: values}},
+			}},
+		},
+	}}
+}
+
+// antiAffinityTo returns a required pod anti-affinity to the app pods
+func antiAffinityTo(app string, topologyKey string) *v1.Affinity {
+	return &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			TopologyKey:   topologyKey,
+		}},
+	}}
+}
+
+func TestCheckNode(t *testing.T) {
+	nodeLabels := map[string]string{"disk": "ssd", "cores": "8"}
+	taints := []v1.Taint{
+		{Key: "dedicated", Value: "batch", Effect: v1.TaintEffectNoSchedule},
+		{Key: "spot", Effect: v1.TaintEffectPreferNoSchedule},
+	}
+	batch := []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "batch", Effect: v1.TaintEffectNoSchedule}}
+
+	tests := []struct {
+		name     string
+		pod      *Pod
+		expected string
+	}{
+		{name: "tolerated", pod: &Pod{Tolerations: batch}},
+		{name: "untolerated taint", pod: &Pod{}, expected: "untolerated taint dedicated=batch:NoSchedule"},
+		{
+			name:     "tolerated other value",
+			pod:      &Pod{Tolerations: []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "web"}}},
+			expected: "untolerated taint dedicated=batch:NoSchedule",
+		},
+		{name: "node selector", pod: &Pod{Tolerations: batch, NodeSelector: map[string]string{"disk": "ssd"}}},
+		{
+			name:     "node selector mismatch",
+			pod:      &Pod{Tolerations: batch, NodeSelector: map[string]string{"disk": "hdd"}},
+			expected: "node selector disk=hdd",
+		},
+		{name: "in", pod: &Pod{Tolerations: batch, Affinity: requiredAffinity("disk", v1.NodeSelectorOpIn, "hdd", "ssd")}},
+		{
+			name:     "not in",
+			pod:      &Pod{Tolerations: batch, Affinity: requiredAffinity("disk", v1.NodeSelectorOpNotIn, "ssd")},
+			expected: "required node affinity",
+		},
+		{name: "not in missing label", pod: &Pod{Tolerations: batch, Affinity: requiredAffinity("gpu", v1.NodeSelectorOpNotIn, "a100")}},
+		{name: "exists", pod: &Pod{Tolerations: batch, Affinity: requiredAffinity("disk", v1.NodeSelectorOpExists)}},
+		{
+			name:     "does not exist",
+			pod:      &Pod{Tolerations: batch, Affinity: requiredAffinity("disk", v1.NodeSelectorOpDoesNotExist)},
+			expected: "required node affinity",
+		},
+		{name: "gt", pod: &Pod{Tolerations: batch, Affinity: requiredAffinity("cores", v1.NodeSelectorOpGt, "4")}},
+		{
+			name:     "lt",
+			pod:      &Pod{Tolerations: batch, Affinity: requiredAffinity("cores", v1.NodeSelectorOpLt, "4")},
+			expected: "required node affinity",
+		},
+		{
+			name:     "gt not a number",
+			pod:      &Pod{Tolerations: batch, Affinity: requiredAffinity("disk", v1.NodeSelectorOpGt, "4")},
+			expected: "required node affinity",
+		},
+	}
+
+	for _, test := range tests {
+		if reason := checkNode(test.pod, nodeLabels, taints); reason != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, reason)
+		}
+	}
+}
+
+func TestAntiAffinity(t *testing.T) {
+	pods := appPods(3, "db", Resources{CPU: 1000, Memory: bytesPerGiB})
+	for _, pod := range pods {
+		pod.Affinity = antiAffinityTo("db", v1.LabelHostname)
+	}
+	// a db pod of another namespace, and a pod only spread across zones, may
+	// share a node with any of them
+	other := appPods(1, "db", Resources{CPU: 1000, Memory: bytesPerGiB})[0]
+	other.Namespace = "staging"
+	zonal := appPods(1, "cache", Resources{CPU: 1000, Memory: bytesPerGiB})[0]
+	zonal.Affinity = antiAffinityTo("db", v1.LabelZoneFailureDomainStable)
+	problem := &Problem{NodeGroup: "0", Pods: append(pods, other, zonal)}
+	types := []*InstanceType{cpuType("c12", 12, 1)}
+
+	for _, strategy := range Strategies() {
+		options := DefaultOptions()
+		options.Strategy = strategy
+		o := NewOptimizer(options)
+
+		solutions := o.Solve(problem, types)
+		if len(solutions) != 1 || !solutions[0].Feasible() {
+			t.Fatalf("%s: expected a feasible solution, got %+v", strategy, solutions)
+		}
+		checkNodes(t, problem, solutions[0].Nodes, nil)
+		if n := solutions[0].NumNodes(); n != 3 {
+			t.Errorf("%s: expected a node per db pod, got %d", strategy, n)
+		}
+		for _, node := range solutions[0].Nodes {
+			db := 0
+			for _, pod := range node.Pods {
+				if pod.Namespace == "default" && pod.Labels["app"] == "db" {
+					db++
+				}
+			}
+			if db != 1 {
+				t.Errorf("%s: expected a single db pod on %s, got %d", strategy, node.Name, db)
+			}
+		}
+
+		mix := o.SolveMix(problem, solutions)
+		if mix == nil || len(mix.Nodes) != 3 {
+			t.Errorf("%s: expected a mix of 3 nodes, got %+v", strategy, mix)
+		}
+	}
+}
+
+func TestUnschedulable(t *testing.T) {
+	template := &Template{
+		Labels: map[string]string{"pool": "batch"},
+		Taints: []v1.Taint{{Key: "dedicated", Value: "batch", Effect: v1.TaintEffectNoSchedule}},
+	}
+	batch := []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpExists}}
+
+	pods := cpuPods(1, 1, 1, 1)
+	pods[0].Tolerations = batch
+	pods[0].NodeSelector = map[string]string{"pool": "batch"}
+	pods[1].Tolerations = batch
+	pods[1].Affinity = requiredAffinity(v1.LabelInstanceTypeStable, v1.NodeSelectorOpIn, "b6")
+	pods[2].Tolerations = batch
+	pods[2].NodeSelector = map[string]string{"pool": "web"}
+	problem := &Problem{NodeGroup: "0", Pods: pods, Template: template}
+	types := []*InstanceType{cpuType("a4", 4, 1), cpuType("b6", 6, 1.4)}
+
+	expected := map[string]map[string]string{
+		"a4": {
+			pods[1].Name: "required node affinity",
+			pods[2].Name: "node selector pool=web",
+			pods[3].Name: "untolerated taint dedicated=batch:NoSchedule",
+		},
+		"b6": {
+			pods[2].Name: "node selector pool=web",
+			pods[3].Name: "untolerated taint dedicated=batch:NoSchedule",
+		},
+	}
+
+	solutions := NewOptimizer(DefaultOptions()).Solve(problem, types)
+	if len(solutions) != 2 {
+		t.Fatalf("expected a solution per instance type, got %d", len(solutions))
+	}
+	for _, s := range solutions {
+		if s.Feasible() {
+			t.Errorf("%s: expected an infeasible solution", s.Type.Name)
+		}
+		checkNodes(t, problem, s.Nodes, s.Unschedulable)
+
+		reasons := make(map[string]string)
+		for _, u := range s.Unschedulable {
+			reasons[u.Pod.Name] = u.Reason
+		}
+		for name, reason := range expected[s.Type.Name] {
+			if reasons[name] != reason {
+				t.Errorf("%s/%s: expected %q, got %q", s.Type.Name, name, reason, reasons[name])
+			}
+		}
+		if len(reasons) != len(expected[s.Type.Name]) {
+			t.Errorf("%s: expected %d unschedulable pods, got %v", s.Type.Name, len(expected[s.Type.Name]), reasons)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUnschedulable(&buf, solutions); err != nil {
+		t.Fatal(err)
+	}
+	if records := readCSV(t, buf.Bytes()); len(records) != 6 {
+		t.Errorf("expected a row per unschedulable pod and instance type, got %v", records)
+	}
+	if Cheapest(solutions) != nil {
+		t.Error("expected no feasible solution")
+	}
+}
+
+func TestSolveMixConstraints(t *testing.T) {
+	// the pinned pod can only be placed on a4, while the others are cheaper on b6
+	pods := cpuPods(3, 3, 1)
+	pods[2].NodeSelector = map[string]string{v1.LabelInstanceTypeStable: "a4"}
+	problem := &Problem{NodeGroup: "0", Pods: pods}
+	types := []*InstanceType{cpuType("a4", 4, 1), cpuType("b6", 6, 1.4)}
+	o := NewOptimizer(DefaultOptions())
+
+	solutions := o.Solve(problem, types)
+	mix := o.SolveMix(problem, solutions)
+	if mix == nil {
+		t.Fatal("expected a mix")
+	}
+	checkNodes(t, problem, mix.Nodes, nil)
+	for _, node := range mix.Nodes {
+		for _, pod := range node.Pods {
+			if pod == pods[2] && node.Type.Name != "a4" {
+				t.Errorf("expected the pinned pod on a4, got %s", node.Type.Name)
+			}
+		}
+	}
+	if mix.Cost > Cheapest(solutions).Cost {
+		t.Errorf("expected the mix to cost at most the %v of the cheapest solution, got %v", Cheapest(solutions).Cost, mix.Cost)
+	}
+}
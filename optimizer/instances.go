@@ -0,0 +1,24 @@
+package optimizer
+
+import (
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+)
+
+const bytesPerGiB = 1024 * 1024 * 1024
+
+// NewInstanceTypes converts priced instance types into packing candidates, using
+// the hourly price as the cost.
+func NewInstanceTypes(priced []*pricing.InstanceType) []*InstanceType {
+	types := make([]*InstanceType, 0, len(priced))
+	for _, t := range priced {
+		types = append(types, &InstanceType{
+			Name: t.Name,
+			Capacity: Resources{
+				CPU:    int64(t.VCPUs * 1000),
+				Memory: int64(t.Memory * bytesPerGiB),
+			},
+			Cost: t.Hourly,
+		})
+	}
+	return types
+}
@@ -0,0 +1,337 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Mix is a heterogeneous solution, placing the pods of a node group on nodes of up
+// to Options.MaxInstanceTypes distinct instance types.
+type Mix struct {
+	NodeGroup string  `json:"nodeGroup"`
+	Nodes     []*Node `json:"nodes"`
+	Cost      float64 `json:"cost"`
+
+	// Strategy is the packing heuristic which produced the placement
+	Strategy string `json:"strategy"`
+
+	// Zones is the number of nodes per zone
+	Zones []*ZoneCount `json:"zones,omitempty"`
+}
+
+// TypeCount is the number of nodes of a single instance type within a mix
+type TypeCount struct {
+	Type  *InstanceType `json:"type"`
+	Count int           `json:"count"`
+}
+
+// Counts returns the number of nodes per instance type, sorted by instance type name
+func (m *Mix) Counts() []*TypeCount {
+	counts := make(map[string]*TypeCount)
+	for _, node := range m.Nodes {
+		tc, ok := counts[node.Type.Name]
+		if !ok {
+			tc = &TypeCount{Type: node.Type}
+			counts[node.Type.Name] = tc
+		}
+		tc.Count++
+	}
+
+	var result []*TypeCount
+	for _, tc := range counts {
+		result = append(result, tc)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Type.Name < result[j].Type.Name
+	})
+	return result
+}
+
+// String returns a short description of the mix, e.g. "3 x m5.large + 1 x m5.xlarge"
+func (m *Mix) String() string {
+	var s string
+	for i, tc := range m.Counts() {
+		if i > 0 {
+			s += " + "
+		}
+		s += fmt.Sprintf("%d x %s", tc.Count, tc.Type.Name)
+	}
+	return s
+}
+
+// SolveMix searches for the cheapest combination of instance types which fits
+// the pods of the problem. The candidate instance types are the cheapest
+// Options.MixCandidates instance types of the homogeneous solutions, and every
+// combination of up to Options.MaxInstanceTypes of them is packed. It returns
+// nil if no combination fits all of the pods.
+func (o *Optimizer) SolveMix(problem *Problem, solutions []*Solution) *Mix {
+	// instance types which cannot schedule all of the pods on their own are
+	// candidates as well, since they may be complemented by other types
+	ranked := make([]*Solution, 0, len(solutions))
+	for _, s := range solutions {
+		if s.NumNodes() > 0 {
+			ranked = append(ranked, s)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Cost < ranked[j].Cost
+	})
+
+	var candidates []*InstanceType
+	for _, s := range ranked {
+		if len(candidates) >= o.options.MixCandidates {
+			break
+		}
+		candidates = append(candidates, s.Type)
+	}
+
+	var best *Mix
+	combinations(len(candidates), o.options.MaxInstanceTypes, func(indices []int) {
+		types := make([]*InstanceType, len(indices))
+		for i, idx := range indices {
+			types[i] = candidates[idx]
+		}
+
+		mix := o.packMix(problem, types)
+		if mix != nil && (best == nil || mix.Cost < best.Cost) {
+			best = mix
+		}
+	})
+
+	return best
+}
+
+// packMix packs the problem onto nodes of the provided instance types using the
+// configured heuristic, or the cheapest of all heuristics for the auto and exact
+// strategies.
+func (o *Optimizer) packMix(problem *Problem, types []*InstanceType) *Mix {
+	strategies := []string{o.options.Strategy}
+	if o.options.Strategy == Auto || o.options.Strategy == Exact {
+		strategies = []string{FirstFitDecreasing, BestFitDecreasing, DotProduct}
+	}
+
+	var best *Mix
+	for _, strategy := range strategies {
+		nodes := greedyMix(problem, types, heuristics[strategy])
+		if nodes == nil {
+			return nil
+		}
+
+		// zones are padded with the cheapest instance type of the mix
+		cheapest := nodes[0].Type
+		for _, node := range nodes {
+			if node.Type.Cost < cheapest.Cost {
+				cheapest = node.Type
+			}
+		}
+		nodes = append(nodes, padZones(problem, nodes, cheapest, o.options.MinNodesPerZone)...)
+
+		mix := &Mix{
+			NodeGroup: problem.NodeGroup,
+			Nodes:     nodes,
+			Strategy:  strategy,
+			Zones:     zoneCounts(problem, nodes),
+		}
+		for _, node := range nodes {
+			mix.Cost += node.Type.Cost
+		}
+
+		if best == nil || mix.Cost < best.Cost {
+			best = mix
+		}
+	}
+	return best
+}
+
+// greedyMix places every pod, largest first, on the open node with the highest
+// score which the pod can be scheduled on. When there is none, a node of the most
+// cost efficient instance type fitting the pod is opened, where efficiency is the
+// hourly cost per average pod of the problem. Once all pods are placed, every
+// node is downsized to the cheapest instance type fitting its pods. It returns nil
+// if some pod cannot be scheduled on any of the instance types, or in any zone.
+func greedyMix(problem *Problem, types []*InstanceType, score scoreFunc) []*Node {
+	pods := problem.Pods
+	n := int64(len(pods))
+	if n == 0 {
+		return nil
+	}
+
+	// allowed[t][i] is true if pod i can be scheduled on instance type t. The
+	// zones a pod can be placed in are taken from the first instance type
+	// allowing it, since zone restrictions don't depend on the instance type.
+	allowed := make([][]bool, len(types))
+	allowedZones := make([][]string, len(pods))
+	for t, it := range types {
+		allowed[t] = make([]bool, len(pods))
+		_, schedulable, zones := problem.checkInstanceType(it)
+		for j, i := range schedulable {
+			if !allowed[t][i] && allowedZones[i] == nil {
+				allowedZones[i] = zones[j]
+			}
+			allowed[t][i] = true
+		}
+	}
+	aa := newAntiAffinity(pods)
+	topo := newTopology(pods, problem.zones(), allowedZones)
+
+	capacities := make([]Resources, len(types))
+	var largest Resources
+	for i, t := range types {
+		capacities[i] = t.Capacity.Sub(problem.Overhead)
+		if capacities[i].CPU > largest.CPU {
+			largest.CPU = capacities[i].CPU
+		}
+		if capacities[i].Memory > largest.Memory {
+			largest.Memory = capacities[i].Memory
+		}
+	}
+
+	demand := problem.Demand()
+	average := Resources{CPU: demand.CPU / n, Memory: demand.Memory / n}
+	costPerPod := make([]float64, len(types))
+	for i, t := range types {
+		perNode := math.Inf(1)
+		if average.CPU > 0 {
+			perNode = math.Min(perNode, ratio(capacities[i].CPU, average.CPU))
+		}
+		if average.Memory > 0 {
+			perNode = math.Min(perNode, ratio(capacities[i].Memory, average.Memory))
+		}
+		costPerPod[i] = t.Cost / perNode
+	}
+
+	order := decreasingOrder(pods, largest)
+	smallest := smallestRemaining(pods, order, largest)
+
+	fits := func(t int, idx int, used Resources) bool {
+		return allowed[t][idx] && used.Add(pods[idx].Requests).Fits(capacities[t])
+	}
+
+	// the pods are placed again with the bins opened last reserved when the
+	// hostname spread does not hold across them, as in greedy
+	for {
+		var used []Resources
+		var typeOf []int
+		var bins [][]int
+		var open []int
+
+		for i, idx := range order {
+			requests := pods[idx].Requests
+
+			chosen := -1
+			var best float64
+			for _, b := range open {
+				if !fits(typeOf[b], idx, used[b]) || aa.conflicts(idx, bins[b]) || !topo.allows(idx, b) {
+					continue
+				}
+				free := capacities[typeOf[b]].Sub(used[b])
+				if score == nil {
+					chosen = b
+					break
+				}
+				if s := score(free, requests, capacities[typeOf[b]]); chosen == -1 || s > best {
+					chosen, best = b, s
+				}
+			}
+
+			if chosen == -1 {
+				t := -1
+				for i := range types {
+					if fits(i, idx, Resources{}) && (t == -1 || costPerPod[i] < costPerPod[t]) {
+						t = i
+					}
+				}
+				if t == -1 {
+					return nil
+				}
+
+				zone, ok := topo.newBinZone(idx)
+				if !ok {
+					return nil
+				}
+
+				chosen = len(used)
+				used = append(used, Resources{})
+				typeOf = append(typeOf, t)
+				bins = append(bins, nil)
+				open = append(open, chosen)
+				topo.open(zone)
+			}
+			topo.place(idx, chosen)
+
+			used[chosen] = used[chosen].Add(requests)
+			bins[chosen] = append(bins[chosen], idx)
+
+			// drop the nodes which can no longer fit any of the remaining pods
+			if i+1 < len(order) {
+				next := smallest[i+1]
+				kept := open[:0]
+				for _, b := range open {
+					if (b != chosen && next == smallest[i]) || next.Fits(capacities[typeOf[b]].Sub(used[b])) {
+						kept = append(kept, b)
+					}
+				}
+				open = kept
+			}
+		}
+
+		if !topo.spreadHolds() && len(bins) > topo.reserved {
+			topo.reset(len(bins))
+			continue
+		}
+
+		nodes := make([]*Node, len(bins))
+		for b, bin := range bins {
+			t := typeOf[b]
+			for i := range types {
+				if types[i].Cost < types[t].Cost && used[b].Fits(capacities[i]) && allowsAll(allowed[i], bin) {
+					t = i
+				}
+			}
+
+			node := &Node{
+				Name:     fmt.Sprintf("node%d", b),
+				Type:     types[t],
+				Zone:     topo.binZones[b],
+				Capacity: capacities[t],
+				Used:     used[b],
+			}
+			for _, idx := range bin {
+				node.Pods = append(node.Pods, pods[idx])
+			}
+			nodes[b] = node
+		}
+
+		return nodes
+	}
+}
+
+func allowsAll(allowed []bool, indices []int) bool {
+	for _, idx := range indices {
+		if !allowed[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// combinations invokes f with every combination of 1 to k indices out of n
+func combinations(n int, k int, f func([]int)) {
+	var indices []int
+	var walk func(start int)
+	walk = func(start int) {
+		if len(indices) > 0 {
+			f(indices)
+		}
+		if len(indices) == k {
+			return
+		}
+		for i := start; i < n; i++ {
+			indices = append(indices, i)
+			walk(i + 1)
+			indices = indices[:len(indices)-1]
+		}
+	}
+	walk(0)
+}
@@ -0,0 +1,79 @@
+package optimizer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSolveMix(t *testing.T) {
+	// two pods fill a b6 node, and the third one is cheaper on its own a4 node
+	// than on either 2 x b6 or 3 x a4
+	types := []*InstanceType{cpuType("a4", 4, 1), cpuType("b6", 6, 1.4)}
+
+	tests := []struct {
+		name          string
+		maxTypes      int
+		mixCandidates int
+		mix           string
+		cost          float64
+	}{
+		{name: "mix", maxTypes: 3, mixCandidates: 8, mix: "1 x a4 + 1 x b6", cost: 2.4},
+		{name: "single type", maxTypes: 1, mixCandidates: 8, mix: "2 x b6", cost: 2.8},
+		{name: "single candidate", maxTypes: 3, mixCandidates: 1, mix: "2 x b6", cost: 2.8},
+	}
+
+	for _, test := range tests {
+		problem := &Problem{NodeGroup: "0", Pods: cpuPods(3, 3, 3)}
+		options := DefaultOptions()
+		options.MaxInstanceTypes = test.maxTypes
+		options.MixCandidates = test.mixCandidates
+		o := NewOptimizer(options)
+
+		solutions := o.Solve(problem, types)
+		if best := Cheapest(solutions); best == nil || best.Type.Name != "b6" || best.Cost != 2.8 {
+			t.Fatalf("%s: expected 2 x b6 to be the cheapest single type, got %+v", test.name, best)
+		}
+
+		mix := o.SolveMix(problem, solutions)
+		if mix == nil {
+			t.Fatalf("%s: expected a mix", test.name)
+		}
+		checkNodes(t, problem, mix.Nodes, nil)
+		if mix.String() != test.mix {
+			t.Errorf("%s: expected %s, got %s", test.name, test.mix, mix)
+		}
+		if mix.Cost != test.cost {
+			t.Errorf("%s: expected a cost of %v, got %v", test.name, test.cost, mix.Cost)
+		}
+	}
+}
+
+func TestWriteMix(t *testing.T) {
+	a4, b6 := cpuType("a4", 4, 1), cpuType("b6", 6, 1.4)
+	mix := &Mix{Nodes: []*Node{{Type: b6}, {Type: a4}, {Type: b6}}}
+
+	var buf bytes.Buffer
+	if err := WriteMix(&buf, mix); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"name", "cpu", "memory", "num_nodes", "unit_cost", "cost"},
+		{"a4", "4000", "17179.869184", "1", "1.0000", "1.0000"},
+		{"b6", "6000", "25769.803776", "2", "1.4000", "2.8000"},
+	}
+	if records := readCSV(t, buf.Bytes()); !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestCombinations(t *testing.T) {
+	var actual [][]int
+	combinations(3, 2, func(indices []int) {
+		actual = append(actual, append([]int(nil), indices...))
+	})
+	expected := [][]int{{0}, {0, 1}, {0, 2}, {1}, {1, 2}, {2}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
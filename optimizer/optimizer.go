@@ -0,0 +1,218 @@
+package optimizer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"k8s.io/klog"
+)
+
+// Options configures the optimizer
+type Options struct {
+	// Strategy is the packing strategy, one of Strategies()
+	Strategy string
+
+	// SearchLimit bounds the number of branch and bound nodes explored per
+	// instance type by the exact strategy. Zero or less means unbounded.
+	SearchLimit int
+
+	// Concurrency is the number of instance types solved in parallel
+	Concurrency int
+
+	// MaxInstanceTypes is the max number of distinct instance types of a mix
+	MaxInstanceTypes int
+
+	// MixCandidates is the number of cheapest homogeneous instance types which
+	// are combined into mixes
+	MixCandidates int
+
+	// MinNodesPerZone is the min number of nodes recommended in each of the zones
+	// the node group currently has nodes in
+	MinNodesPerZone int
+}
+
+// DefaultOptions returns the default optimizer options
+func DefaultOptions() Options {
+	return Options{
+		Strategy:         Auto,
+		SearchLimit:      DefaultSearchLimit,
+		Concurrency:      4,
+		MaxInstanceTypes: 3,
+		MixCandidates:    8,
+		MinNodesPerZone:  1,
+	}
+}
+
+// Optimizer recommends the instance type that fits the pods of a node group for
+// the lowest hourly cost.
+type Optimizer struct {
+	options Options
+}
+
+// NewOptimizer creates a new Optimizer using the provided options
+func NewOptimizer(options Options) *Optimizer {
+	if options.Strategy == "" {
+		options.Strategy = Auto
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	return &Optimizer{options: options}
+}
+
+// Solve packs the problem onto each of the candidate instance types and returns
+// a solution per suitable instance type, sorted by increasing cost. An instance
+// type is suitable if, after the per node overhead, it fits the largest request
+// of the node group.
+func (o *Optimizer) Solve(problem *Problem, types []*InstanceType) []*Solution {
+	maxRequest := problem.MaxRequest()
+
+	var suitable []*InstanceType
+	for _, t := range types {
+		if maxRequest.Fits(t.Capacity.Sub(problem.Overhead)) {
+			suitable = append(suitable, t)
+		}
+	}
+	klog.Infof("Node group %s: detected %d suitable instance types out of %d total", problem.NodeGroup, len(suitable), len(types))
+
+	// instance types with the same capacity and the same schedulable pods share
+	// the same packing
+	type packingKey struct {
+		capacity    Resources
+		schedulable string
+	}
+	type subset struct {
+		indices      []int
+		allowedZones [][]string
+	}
+
+	zones := problem.zones()
+	keys := make([]packingKey, len(suitable))
+	subsets := make(map[packingKey]*subset)
+	unschedulable := make([][]*Unschedulable, len(suitable))
+	for i, t := range suitable {
+		var indices []int
+		var allowedZones [][]string
+		unschedulable[i], indices, allowedZones = problem.checkInstanceType(t)
+		keys[i] = packingKey{
+			capacity:    t.Capacity.Sub(problem.Overhead),
+			schedulable: fmt.Sprint(indices, allowedZones),
+		}
+		subsets[keys[i]] = &subset{indices: indices, allowedZones: allowedZones}
+	}
+
+	var lock sync.Mutex
+	packings := make(map[packingKey]*packing)
+
+	var wg sync.WaitGroup
+	sem := util.NewSemaphore(o.options.Concurrency)
+	for key, s := range subsets {
+		wg.Add(1)
+		go func(key packingKey, s *subset) {
+			defer wg.Done()
+			sem.Acquire()
+			defer sem.Return()
+
+			pods := make([]*Pod, len(s.indices))
+			for i, idx := range s.indices {
+				pods[i] = problem.Pods[idx]
+			}
+			p := pack(o.options.Strategy, pods, key.capacity, zones, s.allowedZones, o.options.SearchLimit)
+
+			lock.Lock()
+			packings[key] = p
+			lock.Unlock()
+		}(key, s)
+	}
+	wg.Wait()
+
+	var solutions []*Solution
+	for i, t := range suitable {
+		p := packings[keys[i]]
+		if p == nil {
+			continue
+		}
+		solution := o.newSolution(problem, t, keys[i].capacity, subsets[keys[i]].indices, p)
+		solution.Unschedulable = append(unschedulable[i], p.unschedulable...)
+		solutions = append(solutions, solution)
+	}
+
+	// feasible solutions first, by increasing cost
+	sort.SliceStable(solutions, func(i, j int) bool {
+		if solutions[i].Feasible() != solutions[j].Feasible() {
+			return solutions[i].Feasible()
+		}
+		return solutions[i].Cost < solutions[j].Cost
+	})
+
+	for _, s := range solutions {
+		if len(s.Unschedulable) > 0 {
+			klog.V(3).Infof("Node group %s: %d pods cannot be scheduled on %s", problem.NodeGroup, len(s.Unschedulable), s.Type.Name)
+		}
+	}
+
+	return solutions
+}
+
+// Cheapest returns the feasible solution with the lowest cost, or nil if no such
+// solution exists.
+func Cheapest(solutions []*Solution) *Solution {
+	var best *Solution
+	for _, s := range solutions {
+		if !s.Feasible() {
+			continue
+		}
+		if best == nil || s.Cost < best.Cost {
+			best = s
+		}
+	}
+	return best
+}
+
+// newSolution creates the solution of a packing, where subset maps the indices of
+// the packed pods to their indices within the problem. Zones holding less than
+// the min number of nodes per zone are padded with empty nodes.
+func (o *Optimizer) newSolution(problem *Problem, t *InstanceType, capacity Resources, subset []int, p *packing) *Solution {
+	solution := &Solution{
+		NodeGroup:  problem.NodeGroup,
+		Type:       t,
+		Capacity:   capacity,
+		Strategy:   p.strategy,
+		LowerBound: p.lowerBound,
+		Gap:        p.gap(),
+		Optimal:    p.optimal,
+	}
+
+	for i, bin := range p.bins {
+		node := &Node{
+			Name:     fmt.Sprintf("node%d", i),
+			Type:     t,
+			Zone:     p.zones[i],
+			Capacity: capacity,
+		}
+		for _, idx := range bin {
+			pod := problem.Pods[subset[idx]]
+			node.Pods = append(node.Pods, pod)
+			node.Used = node.Used.Add(pod.Requests)
+		}
+		solution.Nodes = append(solution.Nodes, node)
+	}
+
+	if len(solution.Nodes) > 0 {
+		padded := padZones(problem, solution.Nodes, t, o.options.MinNodesPerZone)
+		solution.Nodes = append(solution.Nodes, padded...)
+
+		if bound := len(problem.zones()) * o.options.MinNodesPerZone; bound > solution.LowerBound {
+			solution.LowerBound = bound
+			solution.Gap = float64(len(solution.Nodes)-bound) / float64(bound)
+		}
+		solution.Optimal = (solution.Optimal && len(padded) == 0) || len(solution.Nodes) == solution.LowerBound
+	}
+
+	solution.Cost = float64(len(solution.Nodes)) * t.Cost
+	solution.Zones = zoneCounts(problem, solution.Nodes)
+
+	return solution
+}
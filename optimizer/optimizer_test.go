@@ -0,0 +1,202 @@
+package optimizer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+// cpuType is an instance type with the cpus and 4GiB of memory per cpu
+func cpuType(name string, cpus int64, cost float64) *InstanceType {
+	return &InstanceType{
+		Name:     name,
+		Capacity: Resources{CPU: cpus * 1000, Memory: cpus * 4 * bytesPerGiB},
+		Cost:     cost,
+	}
+}
+
+// cpuPods returns an app pod per cpu request, in cores, with 1GiB of memory each
+func cpuPods(cpus ...int64) []*Pod {
+	pods := appPods(len(cpus), "app", Resources{Memory: bytesPerGiB})
+	for i, cpu := range cpus {
+		pods[i].Requests.CPU = cpu * 1000
+	}
+	return pods
+}
+
+// checkNodes fails the test unless every pod of the problem, except for the
+// unschedulable ones, is placed exactly once, and the nodes hold no more than
+// their capacity
+func checkNodes(t *testing.T, problem *Problem, nodes []*Node, unschedulable []*Unschedulable) {
+	t.Helper()
+	placed := make(map[*Pod]int)
+	for _, node := range nodes {
+		var used Resources
+		for _, pod := range node.Pods {
+			placed[pod]++
+			used = used.Add(pod.Requests)
+		}
+		if used != node.Used {
+			t.Errorf("%s: expected used %s, got %s", node.Name, used, node.Used)
+		}
+		if !used.Fits(node.Capacity) {
+			t.Errorf("%s: %s exceeds the capacity %s", node.Name, used, node.Capacity)
+		}
+	}
+	for _, u := range unschedulable {
+		placed[u.Pod]++
+	}
+	for _, pod := range problem.Pods {
+		if placed[pod] != 1 {
+			t.Errorf("%s: expected to be placed once, got %d", pod.Name, placed[pod])
+		}
+	}
+}
+
+func readCSV(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestNewProblems(t *testing.T) {
+	zone := func(z string) map[string]string {
+		return map[string]string{v1.LabelZoneFailureDomainStable: z}
+	}
+	nodes := []*v1.Node{
+		clustercachetest.Node("node-a", "m5.large", "2", "8Gi", zone("us-east-1a")),
+		clustercachetest.Node("node-b", "m5.large", "2", "8Gi", zone("us-east-1b")),
+		clustercachetest.Node("node-c", "m5.large", "2", "8Gi", zone("us-east-1b")),
+		clustercachetest.Node("node-d", "r5.large", "2", "16Gi", nil),
+	}
+	node2group := map[string]string{"node-a": "0", "node-b": "0", "node-c": "0"}
+
+	dep := clustercachetest.Deployment("default", "web", 2)
+	rs := clustercachetest.ReplicaSet("default", "web-5d4f", clustercachetest.OwnerReference("Deployment", dep))
+	ds := clustercachetest.DaemonSet("kube-system", "logs")
+	done := clustercachetest.Pod("default", "job-done", "node-a", "1", "1Gi", nil)
+	done.Status.Phase = v1.PodSucceeded
+	pods := []*v1.Pod{
+		clustercachetest.Pod("default", "web-5d4f-1", "node-a", "500m", "1Gi", clustercachetest.OwnerReference("ReplicaSet", rs)),
+		clustercachetest.Pod("default", "web-5d4f-2", "node-b", "500m", "1Gi", clustercachetest.OwnerReference("ReplicaSet", rs)),
+		clustercachetest.Pod("kube-system", "logs-a", "node-a", "100m", "128Mi", clustercachetest.OwnerReference("DaemonSet", ds)),
+		clustercachetest.Pod("kube-system", "logs-b", "node-b", "300m", "128Mi", clustercachetest.OwnerReference("DaemonSet", ds)),
+		clustercachetest.Pod("default", "other", "node-d", "1", "1Gi", nil),
+		done,
+	}
+
+	problems := NewProblems(pods, nodes, node2group, workload.NewResolver(nil, nil))
+	if len(problems) != 1 {
+		t.Fatalf("expected a single problem, got %d", len(problems))
+	}
+	problem := problems[0]
+
+	var names []string
+	for _, pod := range problem.Pods {
+		names = append(names, pod.Name)
+		if pod.Requests != (Resources{CPU: 500, Memory: bytesPerGiB}) {
+			t.Errorf("%s: unexpected requests %s", pod.Name, pod.Requests)
+		}
+	}
+	if expected := []string{"web-5d4f-1", "web-5d4f-2"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected pods %v, got %v", expected, names)
+	}
+
+	// the DaemonSet pods are replaced with their mean requests
+	if expected := (Resources{CPU: 200, Memory: 128 * 1024 * 1024}); problem.Overhead != expected {
+		t.Errorf("expected overhead %s, got %s", expected, problem.Overhead)
+	}
+	if expected := map[string]int{"us-east-1a": 1, "us-east-1b": 2}; !reflect.DeepEqual(problem.CurrentZones, expected) {
+		t.Errorf("expected zones %v, got %v", expected, problem.CurrentZones)
+	}
+	if problem.Template.Labels[v1.LabelHostname] != "" {
+		t.Error("expected the template to drop the hostname label")
+	}
+}
+
+func TestSolve(t *testing.T) {
+	// 4 cores of pods, on top of a 1 core overhead per node
+	problem := &Problem{
+		NodeGroup: "0",
+		Pods:      cpuPods(2, 1, 1),
+		Overhead:  Resources{CPU: 1000},
+	}
+	types := []*InstanceType{
+		cpuType("c2", 2, 0.1),
+		cpuType("c3", 3, 0.12),
+		cpuType("c5", 5, 0.3),
+		cpuType("c9", 9, 0.5),
+	}
+
+	solutions := NewOptimizer(DefaultOptions()).Solve(problem, types)
+
+	// c2 does not fit the largest pod next to the overhead
+	var names []string
+	var nodes []int
+	for _, s := range solutions {
+		names = append(names, s.Type.Name)
+		nodes = append(nodes, s.NumNodes())
+		checkNodes(t, problem, s.Nodes, s.Unschedulable)
+		if expected := s.Type.Capacity.Sub(problem.Overhead); s.Capacity != expected {
+			t.Errorf("%s: expected capacity %s, got %s", s.Type.Name, expected, s.Capacity)
+		}
+	}
+	if expected := []string{"c3", "c5", "c9"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected solutions %v by cost, got %v", expected, names)
+	}
+	if expected := []int{2, 1, 1}; !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected %v nodes, got %v", expected, nodes)
+	}
+
+	best := Cheapest(solutions)
+	if best == nil || best.Type.Name != "c3" || best.Cost != 0.24 {
+		t.Fatalf("expected 2 x c3 for 0.24, got %+v", best)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSolutions(&buf, solutions); err != nil {
+		t.Fatal(err)
+	}
+	records := readCSV(t, buf.Bytes())
+	if expected := []string{"c3", "2000", "12884.901888", "2", "0.2400", "ffd", "2", "0.0000", "true", "0"}; !reflect.DeepEqual(records[1], expected) {
+		t.Errorf("expected the solution row %v, got %v", expected, records[1])
+	}
+
+	buf.Reset()
+	if err := WritePlacements(&buf, problem, best.Nodes); err != nil {
+		t.Fatal(err)
+	}
+	records = readCSV(t, buf.Bytes())
+	if len(records) != len(problem.Pods)+1 {
+		t.Fatalf("expected a placement row per pod, got %v", records)
+	}
+	if expected := []string{"0", "default", "", "1073.741824", "2000", "node0", "c3", "2000", "12884.901888", ""}; !reflect.DeepEqual(records[1], expected) {
+		t.Errorf("expected the placement row %v, got %v", expected, records[1])
+	}
+}
+
+func TestCheapest(t *testing.T) {
+	feasible := &Solution{Type: cpuType("c3", 3, 1), Nodes: []*Node{{}}, Cost: 2}
+	infeasible := &Solution{
+		Type:          cpuType("c2", 2, 1),
+		Nodes:         []*Node{{}},
+		Cost:          1,
+		Unschedulable: []*Unschedulable{{Pod: &Pod{Name: "app"}, Reason: "required node affinity"}},
+	}
+
+	if best := Cheapest([]*Solution{infeasible, feasible}); best != feasible {
+		t.Errorf("expected the cheapest feasible solution, got %+v", best)
+	}
+	if best := Cheapest([]*Solution{infeasible}); best != nil {
+		t.Errorf("expected no solution, got %+v", best)
+	}
+}
@@ -0,0 +1,160 @@
+package optimizer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const bytesPerMB = 1000000
+
+// WriteSolutions writes a solutions.csv formatted summary of the solutions, one
+// row per instance type. Memory is written in MB, matching the playground output,
+// followed by the strategy used, its lower bound gap and the number of pods which
+// cannot be scheduled on the instance type.
+func WriteSolutions(w io.Writer, solutions []*Solution) error {
+	records := [][]string{
+		{"name", "cpu", "memory", "num_nodes", "cost", "strategy", "lower_bound", "gap", "optimal", "unschedulable"},
+	}
+
+	for _, s := range solutions {
+		records = append(records, []string{
+			s.Type.Name,
+			strconv.FormatInt(s.Capacity.CPU, 10),
+			formatMB(s.Capacity.Memory),
+			strconv.Itoa(s.NumNodes()),
+			strconv.FormatFloat(s.Cost, 'f', 4, 64),
+			s.Strategy,
+			strconv.Itoa(s.LowerBound),
+			strconv.FormatFloat(s.Gap, 'f', 4, 64),
+			strconv.FormatBool(s.Optimal),
+			strconv.Itoa(len(s.Unschedulable)),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WritePlacements writes an all_placements.csv formatted placement of the pods on
+// the nodes, one row per pod. The first, unnamed, column is the index of the pod
+// within its problem. The zone of the node is written last.
+func WritePlacements(w io.Writer, problem *Problem, nodes []*Node) error {
+	records := [][]string{
+		{"", "namespace", "owner_name", "req_mem_mb", "req_cpu_milli_core", "node_name", "node_type", "node_cpu", "node_memory", "node_zone"},
+	}
+
+	index := make(map[*Pod]int, len(problem.Pods))
+	for i, pod := range problem.Pods {
+		index[pod] = i
+	}
+
+	for _, node := range nodes {
+		for _, pod := range node.Pods {
+			records = append(records, []string{
+				strconv.Itoa(index[pod]),
+				pod.Namespace,
+				pod.OwnerName,
+				formatMB(pod.Requests.Memory),
+				strconv.FormatInt(pod.Requests.CPU, 10),
+				node.Name,
+				node.Type.Name,
+				strconv.FormatInt(node.Capacity.CPU, 10),
+				formatMB(node.Capacity.Memory),
+				node.Zone,
+			})
+		}
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteUnschedulable writes the pods which cannot be scheduled on each of the
+// instance types of the solutions, along with the violated constraint.
+func WriteUnschedulable(w io.Writer, solutions []*Solution) error {
+	records := [][]string{
+		{"instance_type", "namespace", "pod_name", "owner_kind", "owner_name", "reason"},
+	}
+
+	for _, s := range solutions {
+		for _, u := range s.Unschedulable {
+			records = append(records, []string{
+				s.Type.Name,
+				u.Pod.Namespace,
+				u.Pod.Name,
+				u.Pod.OwnerKind,
+				u.Pod.OwnerName,
+				u.Reason,
+			})
+		}
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteMix writes the number of nodes per instance type of the mix, one row per
+// instance type.
+func WriteMix(w io.Writer, mix *Mix) error {
+	records := [][]string{
+		{"name", "cpu", "memory", "num_nodes", "unit_cost", "cost"},
+	}
+
+	for _, tc := range mix.Counts() {
+		records = append(records, []string{
+			tc.Type.Name,
+			strconv.FormatInt(tc.Type.Capacity.CPU, 10),
+			formatMB(tc.Type.Capacity.Memory),
+			strconv.Itoa(tc.Count),
+			strconv.FormatFloat(tc.Type.Cost, 'f', 4, 64),
+			strconv.FormatFloat(float64(tc.Count)*tc.Type.Cost, 'f', 4, 64),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// WriteZones writes the current and recommended number of nodes per zone of the
+// best homogeneous solution and, when not nil, of the mix.
+func WriteZones(w io.Writer, best *Solution, mix *Mix) error {
+	records := [][]string{
+		{"recommendation", "zone", "current_nodes", "recommended_nodes"},
+	}
+
+	appendZones := func(recommendation string, zones []*ZoneCount) {
+		for _, zc := range zones {
+			records = append(records, []string{
+				recommendation,
+				zc.Zone,
+				strconv.Itoa(zc.Current),
+				strconv.Itoa(zc.Count),
+			})
+		}
+	}
+	appendZones(best.Type.Name, best.Zones)
+	if mix != nil {
+		appendZones(mix.String(), mix.Zones)
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// FormatZones returns a short description of the node count per zone, e.g.
+// "us-east-1a: 3 (was 4), us-east-1b: 2 (was 4)"
+func FormatZones(zones []*ZoneCount) string {
+	var s string
+	for i, zc := range zones {
+		if i > 0 {
+			s += ", "
+		}
+		zone := zc.Zone
+		if zone == "" {
+			zone = "<none>"
+		}
+		s += fmt.Sprintf("%s: %d (was %d)", zone, zc.Count, zc.Current)
+	}
+	return s
+}
+
+func formatMB(bytes int64) string {
+	return strconv.FormatFloat(float64(bytes)/bytesPerMB, 'f', -1, 64)
+}
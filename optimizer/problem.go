@@ -0,0 +1,225 @@
+package optimizer
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+// Problem is the packing problem of a single node group: the pods that need to
+// be placed, the per node overhead that every node will carry regardless of the
+// instance type (i.e. daemonsets), and the labels and taints of the group nodes.
+type Problem struct {
+	NodeGroup string
+	Pods      []*Pod
+	Overhead  Resources
+	Template  *Template
+
+	// CurrentZones is the number of nodes the group currently has per zone
+	CurrentZones map[string]int
+}
+
+// zones returns the zones the group currently has nodes in
+func (p *Problem) zones() []string {
+	var zones []string
+	for zone := range p.CurrentZones {
+		if zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// template returns the labels and taints of the group nodes, which is empty when
+// the problem was not built out of cluster nodes.
+func (p *Problem) template() *Template {
+	if p.Template == nil {
+		return &Template{}
+	}
+	return p.Template
+}
+
+// checkInstanceType returns the pods which cannot be scheduled on a node of the
+// instance type in any of the zones, and the indices of the pods that can. For
+// each of the schedulable pods, it also returns the zones it can be scheduled in,
+// nil meaning all of them.
+func (p *Problem) checkInstanceType(t *InstanceType) ([]*Unschedulable, []int, [][]string) {
+	template := p.template()
+	zones := p.zones()
+	if len(zones) == 0 {
+		zones = []string{""}
+	}
+
+	nodeLabels := make([]map[string]string, len(zones))
+	for z, zone := range zones {
+		nodeLabels[z] = template.LabelsFor(t.Name, zone)
+	}
+
+	var unschedulable []*Unschedulable
+	var schedulable []int
+	var allowedZones [][]string
+	for i, pod := range p.Pods {
+		var allowed []string
+		var reason string
+		for z, zone := range zones {
+			if r := checkNode(pod, nodeLabels[z], template.Taints); r != "" {
+				if reason == "" {
+					reason = r
+				}
+				continue
+			}
+			allowed = append(allowed, zone)
+		}
+
+		if len(allowed) == 0 {
+			unschedulable = append(unschedulable, &Unschedulable{Pod: pod, Reason: reason})
+			continue
+		}
+		if len(allowed) == len(zones) {
+			allowed = nil
+		}
+		schedulable = append(schedulable, i)
+		allowedZones = append(allowedZones, allowed)
+	}
+	return unschedulable, schedulable, allowedZones
+}
+
+// Demand returns the total resources requested by the pods of the problem
+func (p *Problem) Demand() Resources {
+	var total Resources
+	for _, pod := range p.Pods {
+		total = total.Add(pod.Requests)
+	}
+	return total
+}
+
+// MaxRequest returns the largest CPU and memory requests of any single pod. The
+// values may come from different pods.
+func (p *Problem) MaxRequest() Resources {
+	var max Resources
+	for _, pod := range p.Pods {
+		if pod.Requests.CPU > max.CPU {
+			max.CPU = pod.Requests.CPU
+		}
+		if pod.Requests.Memory > max.Memory {
+			max.Memory = pod.Requests.Memory
+		}
+	}
+	return max
+}
+
+// NewPod converts a kubernetes pod of the workload into a packing item, summing
+// the requests of all its containers.
+func NewPod(pod *v1.Pod, nodeGroup string, w workload.Workload) *Pod {
+	var requests Resources
+	for _, container := range pod.Spec.Containers {
+		requests.CPU += container.Resources.Requests.Cpu().MilliValue()
+		requests.Memory += container.Resources.Requests.Memory().Value()
+	}
+
+	p := &Pod{
+		Name:         pod.Name,
+		Namespace:    pod.Namespace,
+		OwnerKind:    w.Kind,
+		OwnerName:    w.Name,
+		NodeGroup:    nodeGroup,
+		Requests:     requests,
+		Labels:       pod.Labels,
+		NodeSelector: pod.Spec.NodeSelector,
+		Affinity:     pod.Spec.Affinity,
+		Tolerations:  pod.Spec.Tolerations,
+
+		TopologySpreadConstraints: pod.Spec.TopologySpreadConstraints,
+	}
+	return p
+}
+
+// NewProblems builds a packing problem per node group out of the cluster pods and
+// nodes. node2group maps a node name to its node group id. Pods which are not
+// running on a grouped node, as well as terminated pods, are skipped. DaemonSet
+// pods are not packed; instead, the mean requests of each DaemonSet are summed
+// into the per node overhead of the group. The current number of nodes per zone
+// of each group is recorded so that recommendations keep the group multi zonal.
+// The owner of each pod is the workload it resolves to.
+func NewProblems(pods []*v1.Pod, nodes []*v1.Node, node2group map[string]string, resolver *workload.Resolver) []*Problem {
+	problems := make(map[string]*Problem)
+	daemonSets := make(map[string]map[string][]Resources)
+
+	// the template of a group is taken from its first node by name
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	templates := make(map[string]*Template)
+	zones := make(map[string]map[string]int)
+	for _, node := range nodes {
+		group, ok := node2group[node.Name]
+		if !ok {
+			continue
+		}
+		if _, ok := templates[group]; !ok {
+			templates[group] = newTemplate(node)
+			zones[group] = make(map[string]int)
+		}
+		zone, _ := GetZone(node.Labels)
+		zones[group][zone]++
+	}
+
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		group, ok := node2group[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+
+		problem, ok := problems[group]
+		if !ok {
+			problem = &Problem{
+				NodeGroup:    group,
+				Template:     templates[group],
+				CurrentZones: zones[group],
+			}
+			problems[group] = problem
+			daemonSets[group] = make(map[string][]Resources)
+		}
+
+		p := NewPod(pod, group, resolver.Resolve(pod))
+		if p.OwnerKind == workload.KindDaemonSet {
+			daemonSets[group][p.OwnerName] = append(daemonSets[group][p.OwnerName], p.Requests)
+			continue
+		}
+		problem.Pods = append(problem.Pods, p)
+	}
+
+	var result []*Problem
+	for group, problem := range problems {
+		for _, requests := range daemonSets[group] {
+			problem.Overhead = problem.Overhead.Add(mean(requests))
+		}
+		result = append(result, problem)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NodeGroup < result[j].NodeGroup
+	})
+
+	return result
+}
+
+func mean(values []Resources) Resources {
+	if len(values) == 0 {
+		return Resources{}
+	}
+
+	var total Resources
+	for _, v := range values {
+		total = total.Add(v)
+	}
+	n := int64(len(values))
+	return Resources{CPU: total.CPU / n, Memory: total.Memory / n}
+}
@@ -0,0 +1,170 @@
+package optimizer
+
+// DefaultSearchLimit is the default number of branch and bound nodes explored
+// before the search settles on the best placement found so far.
+const DefaultSearchLimit = 1000000
+
+// exactPacker searches for the minimal number of equally sized bins holding a set
+// of pods using a depth first branch and bound, which replaces the SCIP model of
+// the playground. The search starts from the best heuristic placement and stops
+// once it either proves optimality or exhausts its search limit.
+type exactPacker struct {
+	pods     []*Pod
+	order    []int
+	capacity Resources
+	limit    int
+	aa       *antiAffinity
+	topo     *topology
+
+	prefix   []Resources
+	suffix   []Resources
+	used     []Resources
+	members  [][]int
+	assign   []int
+	explored int
+	aborted  bool
+
+	best      []int
+	bestZones []string
+	bestBins  int
+}
+
+func newExactPacker(pods []*Pod, capacity Resources, limit int, aa *antiAffinity, topo *topology) *exactPacker {
+	order := decreasingOrder(pods, capacity)
+
+	n := len(order)
+	prefix := make([]Resources, n+1)
+	suffix := make([]Resources, n+1)
+	for i := 0; i < n; i++ {
+		prefix[i+1] = prefix[i].Add(pods[order[i]].Requests)
+	}
+	for i := n - 1; i >= 0; i-- {
+		suffix[i] = suffix[i+1].Add(pods[order[i]].Requests)
+	}
+
+	return &exactPacker{
+		pods:     pods,
+		order:    order,
+		capacity: capacity,
+		limit:    limit,
+		aa:       aa,
+		topo:     topo,
+		prefix:   prefix,
+		suffix:   suffix,
+		assign:   make([]int, n),
+	}
+}
+
+// pack improves upon the incumbent assignment and returns the best assignment
+// found and the zones of its bins, along with whether it was proven optimal.
+func (ep *exactPacker) pack(incumbent []int, zones []string) ([]int, []string, bool) {
+	ep.best = append([]int(nil), incumbent...)
+	ep.bestZones = zones
+	ep.bestBins = numBins(ep.best)
+
+	if ep.bestBins > ep.lowerBound(0, 0) {
+		ep.search(0, 0)
+	}
+
+	return ep.best, ep.bestZones, !ep.aborted
+}
+
+// lowerBound returns the minimal number of bins required when the first i pods
+// (in search order) are placed on open bins.
+func (ep *exactPacker) lowerBound(i int, open int) int {
+	free := Resources{
+		CPU:    int64(open)*ep.capacity.CPU - ep.prefix[i].CPU,
+		Memory: int64(open)*ep.capacity.Memory - ep.prefix[i].Memory,
+	}
+	remaining := ep.suffix[i].Sub(free)
+
+	extra := ceilDiv(remaining.CPU, ep.capacity.CPU)
+	if m := ceilDiv(remaining.Memory, ep.capacity.Memory); m > extra {
+		extra = m
+	}
+	return open + extra
+}
+
+func (ep *exactPacker) search(i int, open int) {
+	if i == len(ep.order) {
+		if open < ep.bestBins {
+			ep.bestBins = open
+			copy(ep.best, ep.assign)
+			ep.bestZones = append([]string(nil), ep.topo.binZones...)
+		}
+		return
+	}
+
+	ep.explored++
+	if ep.limit > 0 && ep.explored > ep.limit {
+		ep.aborted = true
+		return
+	}
+
+	if ep.lowerBound(i, open) >= ep.bestBins {
+		return
+	}
+
+	idx := ep.order[i]
+	requests := ep.pods[idx].Requests
+
+	// bins with identical usage lead to symmetric sub trees, try only one of them.
+	// This does not hold when anti-affinity or topology tells the bins apart.
+	symmetric := ep.aa == nil && !ep.topo.constrained()
+	tried := make(map[Resources]bool)
+	for b := 0; b < open; b++ {
+		if tried[ep.used[b]] || !ep.used[b].Add(requests).Fits(ep.capacity) {
+			continue
+		}
+		if ep.aa.conflicts(idx, ep.members[b]) || !ep.topo.allows(idx, b) {
+			continue
+		}
+		if symmetric {
+			tried[ep.used[b]] = true
+		}
+
+		ep.used[b] = ep.used[b].Add(requests)
+		ep.assign[idx] = b
+		ep.push(b, idx)
+		ep.search(i+1, open)
+		ep.pop(b, idx)
+		ep.used[b] = ep.used[b].Sub(requests)
+
+		if ep.aborted {
+			return
+		}
+	}
+
+	if open+1 < ep.bestBins {
+		zone, ok := ep.topo.newBinZone(idx)
+		if !ok {
+			return
+		}
+		ep.used = append(ep.used[:open], requests)
+		ep.members = append(ep.members[:open], nil)
+		ep.assign[idx] = open
+		ep.topo.open(zone)
+		ep.push(open, idx)
+		ep.search(i+1, open+1)
+		ep.pop(open, idx)
+		ep.topo.close()
+		ep.used = ep.used[:open]
+		ep.members = ep.members[:open]
+	}
+}
+
+// push and pop track the placement of a pod on a bin within the topology, and the
+// members of each bin, which are only required to evaluate anti-affinity.
+func (ep *exactPacker) push(b int, idx int) {
+	ep.topo.place(idx, b)
+	if ep.aa != nil {
+		ep.members[b] = append(ep.members[b], idx)
+	}
+}
+
+func (ep *exactPacker) pop(b int, idx int) {
+	ep.topo.remove(idx, b)
+	if ep.aa != nil {
+		ep.members[b] = ep.members[b][:len(ep.members[b])-1]
+	}
+}
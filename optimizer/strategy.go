@@ -0,0 +1,356 @@
+package optimizer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Packing strategies
+const (
+	// FirstFitDecreasing places each pod, largest first, on the first node it fits
+	FirstFitDecreasing = "ffd"
+
+	// BestFitDecreasing places each pod, largest first, on the node left with the
+	// least free capacity after placing it
+	BestFitDecreasing = "bfd"
+
+	// DotProduct places each pod, largest first, on the node whose free capacity
+	// is best aligned with the pod requests, balancing CPU and memory usage
+	DotProduct = "dot"
+
+	// Auto runs all of the heuristics and keeps the one using the fewest nodes
+	Auto = "auto"
+
+	// Exact improves upon the heuristics with a branch and bound search, which is
+	// bounded by the search limit
+	Exact = "exact"
+)
+
+// heuristics maps each greedy strategy to its node scoring function. The pod is
+// placed on the node with the highest score, so a nil scoring function means the
+// first node that fits.
+var heuristics = map[string]scoreFunc{
+	FirstFitDecreasing: nil,
+	BestFitDecreasing:  bestFitScore,
+	DotProduct:         dotProductScore,
+}
+
+// Strategies returns the names of the supported packing strategies
+func Strategies() []string {
+	return []string{FirstFitDecreasing, BestFitDecreasing, DotProduct, Auto, Exact}
+}
+
+// ValidateStrategy returns an error if the strategy is not supported
+func ValidateStrategy(strategy string) error {
+	for _, s := range Strategies() {
+		if s == strategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown strategy %q, expected one of: %s", strategy, strings.Join(Strategies(), ", "))
+}
+
+// packing is the result of packing pods onto equally sized bins. Each bin holds
+// the indices of the pods placed on it, and is assigned a zone. Pods which no
+// zone allows are unschedulable.
+type packing struct {
+	strategy      string
+	bins          [][]int
+	zones         []string
+	lowerBound    int
+	optimal       bool
+	unschedulable []*Unschedulable
+}
+
+// gap returns the relative distance of the packing from its lower bound, e.g. 0.1
+// means the packing uses at most 10% more nodes than the optimal one.
+func (p *packing) gap() float64 {
+	if p.lowerBound == 0 {
+		return 0
+	}
+	return float64(len(p.bins)-p.lowerBound) / float64(p.lowerBound)
+}
+
+// pack packs the pods onto bins of the given capacity using the strategy, spreading
+// the bins across the zones. allowedZones optionally restricts, per pod, the zones
+// it can be placed in, and pods left without an allowed zone by the topology
+// spread of the others are reported as unschedulable. It returns nil if some pod
+// does not fit the capacity at all.
+func pack(strategy string, pods []*Pod, capacity Resources, zones []string, allowedZones [][]string, limit int) *packing {
+	for _, pod := range pods {
+		if !pod.Requests.Fits(capacity) {
+			return nil
+		}
+	}
+
+	order := decreasingOrder(pods, capacity)
+	bound := lowerBound(pods, capacity)
+	aa := newAntiAffinity(pods)
+
+	var assign []int
+	var binZones []string
+	var topo *topology
+	name := strategy
+	switch strategy {
+	case Auto, Exact:
+		// the heuristic placing the most pods wins, then the one using the
+		// fewest nodes
+		for _, h := range []string{FirstFitDecreasing, BestFitDecreasing, DotProduct} {
+			t := newTopology(pods, zones, allowedZones)
+			a := greedy(pods, order, capacity, heuristics[h], aa, t)
+			if assign == nil || numUnplaced(a) < numUnplaced(assign) ||
+				(numUnplaced(a) == numUnplaced(assign) && numBins(a) < numBins(assign)) {
+				assign, binZones, name, topo = a, t.binZones, h, t
+			}
+		}
+	default:
+		topo = newTopology(pods, zones, allowedZones)
+		assign = greedy(pods, order, capacity, heuristics[strategy], aa, topo)
+		binZones = topo.binZones
+	}
+
+	var unschedulable []*Unschedulable
+	for idx, b := range assign {
+		if b < 0 {
+			unschedulable = append(unschedulable, &Unschedulable{Pod: pods[idx], Reason: topo.zoneReason(idx)})
+		}
+	}
+
+	// the exact search places every pod, so it only improves upon complete
+	// placements
+	optimal := numBins(assign) <= bound && len(unschedulable) == 0
+	if strategy == Exact && !optimal && len(unschedulable) == 0 {
+		var proven bool
+		// the search only accepts fewer bins than the incumbent, which the
+		// hostname spread is evaluated against
+		topo := newTopology(pods, zones, allowedZones)
+		topo.reset(numBins(assign))
+		assign, binZones, proven = newExactPacker(pods, capacity, limit, aa, topo).pack(assign, binZones)
+		optimal = proven || numBins(assign) <= bound
+		name = Exact
+	}
+
+	return &packing{
+		strategy:      name,
+		bins:          binsOf(assign, numBins(assign)),
+		zones:         binZones,
+		lowerBound:    bound,
+		optimal:       optimal,
+		unschedulable: unschedulable,
+	}
+}
+
+// lowerBound returns a lower bound on the number of bins required to pack the
+// pods: the larger of the total demand over the capacity in each dimension, and
+// the number of pods which are larger than half of the capacity in a dimension,
+// since no two of them can share a bin.
+func lowerBound(pods []*Pod, capacity Resources) int {
+	var demand Resources
+	largeCPU, largeMemory := 0, 0
+	for _, pod := range pods {
+		demand = demand.Add(pod.Requests)
+		if 2*pod.Requests.CPU > capacity.CPU {
+			largeCPU++
+		}
+		if 2*pod.Requests.Memory > capacity.Memory {
+			largeMemory++
+		}
+	}
+
+	bound := ceilDiv(demand.CPU, capacity.CPU)
+	for _, b := range []int{ceilDiv(demand.Memory, capacity.Memory), largeCPU, largeMemory} {
+		if b > bound {
+			bound = b
+		}
+	}
+	return bound
+}
+
+// scoreFunc scores placing a pod with the given requests on a bin with the given
+// free capacity.
+type scoreFunc func(free Resources, requests Resources, capacity Resources) float64
+
+// bestFitScore prefers the bin with the least normalized free capacity left
+func bestFitScore(free Resources, requests Resources, capacity Resources) float64 {
+	left := free.Sub(requests)
+	return -(ratio(left.CPU, capacity.CPU) + ratio(left.Memory, capacity.Memory))
+}
+
+// dotProductScore prefers the bin whose normalized free capacity vector has the
+// largest dot product with the normalized requests vector
+func dotProductScore(free Resources, requests Resources, capacity Resources) float64 {
+	return ratio(free.CPU, capacity.CPU)*ratio(requests.CPU, capacity.CPU) +
+		ratio(free.Memory, capacity.Memory)*ratio(requests.Memory, capacity.Memory)
+}
+
+// greedy places every pod, in the provided order, on the open bin with the highest
+// score which fits it without violating the anti-affinity and topology spread of
+// the pods, opening a new bin when there is none, and returns the bin index
+// assigned to each pod. The zone of each bin is recorded by the topology. Pods
+// which need a new bin while no zone allows them are assigned -1. When the
+// hostname spread does not hold across the bins opened last, the pods are placed
+// again with all of these bins reserved from the start.
+func greedy(pods []*Pod, order []int, capacity Resources, score scoreFunc, aa *antiAffinity, topo *topology) []int {
+	for {
+		assign := greedyPass(pods, order, capacity, score, aa, topo)
+		if topo.spreadHolds() || len(topo.binZones) <= topo.reserved {
+			return assign
+		}
+		topo.reset(len(topo.binZones))
+	}
+}
+
+// greedyPass is a single placement of greedy. Bins that cannot fit any of the
+// remaining pods are closed so that they are not scanned again.
+func greedyPass(pods []*Pod, order []int, capacity Resources, score scoreFunc, aa *antiAffinity, topo *topology) []int {
+	n := len(order)
+	smallest := smallestRemaining(pods, order, capacity)
+
+	assign := make([]int, len(pods))
+	var free []Resources
+	var members [][]int
+	var open []int
+
+	for i, idx := range order {
+		requests := pods[idx].Requests
+
+		chosen := -1
+		var best float64
+		for _, b := range open {
+			if !requests.Fits(free[b]) || aa.conflicts(idx, members[b]) || !topo.allows(idx, b) {
+				continue
+			}
+			if score == nil {
+				chosen = b
+				break
+			}
+			if s := score(free[b], requests, capacity); chosen == -1 || s > best {
+				chosen, best = b, s
+			}
+		}
+
+		if chosen == -1 {
+			zone, ok := topo.newBinZone(idx)
+			if !ok {
+				assign[idx] = -1
+				continue
+			}
+			chosen = len(free)
+			free = append(free, capacity)
+			members = append(members, nil)
+			open = append(open, chosen)
+			topo.open(zone)
+		}
+		topo.place(idx, chosen)
+		free[chosen] = free[chosen].Sub(requests)
+		assign[idx] = chosen
+		if aa != nil {
+			members[chosen] = append(members[chosen], idx)
+		}
+
+		// drop the bins which can no longer fit any of the remaining pods. Only the
+		// chosen bin changed, unless the smallest remaining requests changed too.
+		if i+1 < n {
+			next := smallest[i+1]
+			kept := open[:0]
+			for _, b := range open {
+				if (b != chosen && next == smallest[i]) || next.Fits(free[b]) {
+					kept = append(kept, b)
+				}
+			}
+			open = kept
+		}
+	}
+
+	return assign
+}
+
+// smallestRemaining returns, for every position i of the order, the smallest
+// requests per dimension of the pods from position i onward. The last entry,
+// past the end of the order, is the capacity.
+func smallestRemaining(pods []*Pod, order []int, capacity Resources) []Resources {
+	n := len(order)
+	smallest := make([]Resources, n+1)
+	smallest[n] = capacity
+	for i := n - 1; i >= 0; i-- {
+		smallest[i] = smallest[i+1]
+		requests := pods[order[i]].Requests
+		if requests.CPU < smallest[i].CPU {
+			smallest[i].CPU = requests.CPU
+		}
+		if requests.Memory < smallest[i].Memory {
+			smallest[i].Memory = requests.Memory
+		}
+	}
+	return smallest
+}
+
+// decreasingOrder returns the pod indices sorted by decreasing size, where the
+// size of a pod is its largest request relative to the capacity.
+func decreasingOrder(pods []*Pod, capacity Resources) []int {
+	order := make([]int, len(pods))
+	for i := range order {
+		order[i] = i
+	}
+
+	size := func(r Resources) float64 {
+		cpu := ratio(r.CPU, capacity.CPU)
+		memory := ratio(r.Memory, capacity.Memory)
+		if cpu > memory {
+			return cpu
+		}
+		return memory
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return size(pods[order[i]].Requests) > size(pods[order[j]].Requests)
+	})
+
+	return order
+}
+
+func numBins(assign []int) int {
+	n := 0
+	for _, b := range assign {
+		if b+1 > n {
+			n = b + 1
+		}
+	}
+	return n
+}
+
+func binsOf(assign []int, n int) [][]int {
+	bins := make([][]int, n)
+	for idx, b := range assign {
+		if b >= 0 {
+			bins[b] = append(bins[b], idx)
+		}
+	}
+	return bins
+}
+
+// numUnplaced returns the number of pods which are not assigned a bin
+func numUnplaced(assign []int) int {
+	n := 0
+	for _, b := range assign {
+		if b < 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func ratio(x int64, y int64) float64 {
+	if y == 0 {
+		return 0
+	}
+	return float64(x) / float64(y)
+}
+
+func ceilDiv(x int64, y int64) int {
+	if x <= 0 || y <= 0 {
+		return 0
+	}
+	return int((x + y - 1) / y)
+}
@@ -0,0 +1,146 @@
+package optimizer
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestStrategies(t *testing.T) {
+	// the decreasing heuristics all pair 6 with 5 and end up with a third node,
+	// while 6+4+2 and 5+4+3 fill two nodes
+	pods := cpuPods(6, 5, 4, 4, 3, 2)
+	types := []*InstanceType{cpuType("c12", 12, 1)}
+
+	tests := []struct {
+		strategy    string
+		searchLimit int
+		nodes       int
+		name        string
+		gap         float64
+		optimal     bool
+	}{
+		{strategy: FirstFitDecreasing, nodes: 3, name: FirstFitDecreasing, gap: 0.5},
+		{strategy: BestFitDecreasing, nodes: 3, name: BestFitDecreasing, gap: 0.5},
+		{strategy: DotProduct, nodes: 3, name: DotProduct, gap: 0.5},
+		{strategy: Auto, nodes: 3, name: FirstFitDecreasing, gap: 0.5},
+		{strategy: Exact, searchLimit: DefaultSearchLimit, nodes: 2, name: Exact, optimal: true},
+		// the search gives up before improving upon the heuristics
+		{strategy: Exact, searchLimit: 1, nodes: 3, name: Exact, gap: 0.5},
+	}
+
+	for _, test := range tests {
+		problem := &Problem{NodeGroup: "0", Pods: pods}
+		options := DefaultOptions()
+		options.Strategy = test.strategy
+		options.SearchLimit = test.searchLimit
+
+		solutions := NewOptimizer(options).Solve(problem, types)
+		if len(solutions) != 1 {
+			t.Fatalf("%s: expected a solution, got %d", test.strategy, len(solutions))
+		}
+		s := solutions[0]
+		checkNodes(t, problem, s.Nodes, s.Unschedulable)
+
+		if s.NumNodes() != test.nodes {
+			t.Errorf("%s/%d: expected %d nodes, got %d", test.strategy, test.searchLimit, test.nodes, s.NumNodes())
+		}
+		if s.Strategy != test.name {
+			t.Errorf("%s/%d: expected strategy %s, got %s", test.strategy, test.searchLimit, test.name, s.Strategy)
+		}
+		if s.LowerBound != 2 || s.Gap != test.gap {
+			t.Errorf("%s/%d: expected lower bound 2 with a gap of %v, got %d with %v", test.strategy, test.searchLimit, test.gap, s.LowerBound, s.Gap)
+		}
+		if s.Optimal != test.optimal {
+			t.Errorf("%s/%d: expected optimal %v, got %v", test.strategy, test.searchLimit, test.optimal, s.Optimal)
+		}
+	}
+}
+
+func TestLowerBound(t *testing.T) {
+	capacity := Resources{CPU: 10000, Memory: 10 * bytesPerGiB}
+
+	tests := []struct {
+		name     string
+		requests []Resources
+		expected int
+	}{
+		{name: "empty", expected: 0},
+		{
+			name:     "cpu",
+			requests: []Resources{{CPU: 4000}, {CPU: 4000}, {CPU: 4000}},
+			expected: 2,
+		},
+		{
+			name:     "memory",
+			requests: []Resources{{Memory: 6 * bytesPerGiB}, {Memory: 5 * bytesPerGiB}, {Memory: 5 * bytesPerGiB}, {Memory: 5 * bytesPerGiB}},
+			expected: 3,
+		},
+		{
+			// no two pods larger than half of the capacity share a node
+			name:     "large pods",
+			requests: []Resources{{CPU: 6000}, {CPU: 6000}, {CPU: 6000}, {Memory: 6 * bytesPerGiB}},
+			expected: 3,
+		},
+	}
+
+	for _, test := range tests {
+		pods := make([]*Pod, len(test.requests))
+		for i, requests := range test.requests {
+			pods[i] = &Pod{Requests: requests}
+		}
+		if bound := lowerBound(pods, capacity); bound != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, bound)
+		}
+	}
+}
+
+func TestStrategiesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pods := make([]*Pod, 2000)
+	for i := range pods {
+		pods[i] = &Pod{
+			Name:     "app",
+			Requests: Resources{CPU: 100 + r.Int63n(3900), Memory: (1 + r.Int63n(15)) * bytesPerGiB / 2},
+		}
+	}
+	problem := &Problem{NodeGroup: "0", Pods: pods}
+	types := []*InstanceType{cpuType("c16", 16, 1)}
+
+	nodes := make(map[string]int)
+	for _, strategy := range Strategies() {
+		options := DefaultOptions()
+		options.Strategy = strategy
+		options.SearchLimit = 10000
+
+		solutions := NewOptimizer(options).Solve(problem, types)
+		if len(solutions) != 1 {
+			t.Fatalf("%s: expected a solution, got %d", strategy, len(solutions))
+		}
+		s := solutions[0]
+		checkNodes(t, problem, s.Nodes, s.Unschedulable)
+		if s.NumNodes() < s.LowerBound {
+			t.Errorf("%s: %d nodes are below the lower bound %d", strategy, s.NumNodes(), s.LowerBound)
+		}
+		nodes[strategy] = s.NumNodes()
+	}
+
+	for _, h := range []string{FirstFitDecreasing, BestFitDecreasing, DotProduct} {
+		if nodes[Auto] > nodes[h] {
+			t.Errorf("expected auto to use at most the %d nodes of %s, got %d", nodes[h], h, nodes[Auto])
+		}
+	}
+	if nodes[Exact] > nodes[Auto] {
+		t.Errorf("expected exact to use at most the %d nodes of auto, got %d", nodes[Auto], nodes[Exact])
+	}
+}
+
+func TestValidateStrategy(t *testing.T) {
+	for _, strategy := range Strategies() {
+		if err := ValidateStrategy(strategy); err != nil {
+			t.Errorf("%s: %s", strategy, err)
+		}
+	}
+	if err := ValidateStrategy("scip"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
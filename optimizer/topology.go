@@ -0,0 +1,365 @@
+package optimizer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// GetZone returns the zone of a node from its labels
+func GetZone(nodeLabels map[string]string) (string, bool) {
+	if zone, ok := nodeLabels[v1.LabelZoneFailureDomainStable]; ok {
+		return zone, true
+	} else if zone, ok := nodeLabels[v1.LabelZoneFailureDomain]; ok {
+		return zone, true
+	}
+	return "", false
+}
+
+func isZoneKey(key string) bool {
+	return key == v1.LabelZoneFailureDomainStable || key == v1.LabelZoneFailureDomain
+}
+
+// spreadConstraint is a parsed DoNotSchedule topology spread constraint, shared by
+// all the pods which declare the same constraint.
+type spreadConstraint struct {
+	maxSkew int
+	perZone bool
+
+	// matches is true for the pods counted by the constraint
+	matches []bool
+
+	zoneCounts map[string]int
+	nodeCounts []int
+}
+
+// topology assigns zones to the nodes of a packing and evaluates the topology
+// spread constraints of the pods. Nodes are opened in the allowed zone holding
+// the fewest nodes, which keeps the node count balanced across zones.
+type topology struct {
+	zones []string
+
+	// allowedZones holds, per pod, the zones its node affinity allows, nil
+	// meaning all of them
+	allowedZones [][]string
+	declared     [][]*spreadConstraint
+	counted      [][]*spreadConstraint
+	constraints  []*spreadConstraint
+
+	binZones  []string
+	zoneNodes map[string]int
+
+	// reserved is the number of bins the hostname spread is evaluated against,
+	// the ones which are not open yet holding none of the pods
+	reserved int
+}
+
+// newTopology parses the spread constraints of the pods. Only constraints keyed by
+// zone or hostname are evaluated.
+func newTopology(pods []*Pod, zones []string, allowedZones [][]string) *topology {
+	if len(zones) == 0 {
+		zones = []string{""}
+	}
+	if allowedZones == nil {
+		allowedZones = make([][]string, len(pods))
+	}
+
+	t := &topology{
+		zones:        zones,
+		allowedZones: allowedZones,
+		declared:     make([][]*spreadConstraint, len(pods)),
+		counted:      make([][]*spreadConstraint, len(pods)),
+		zoneNodes:    make(map[string]int),
+	}
+
+	shared := make(map[string]*spreadConstraint)
+	for i, pod := range pods {
+		for _, tsc := range pod.TopologySpreadConstraints {
+			if tsc.WhenUnsatisfiable != v1.DoNotSchedule {
+				continue
+			}
+			if !isZoneKey(tsc.TopologyKey) && tsc.TopologyKey != v1.LabelHostname {
+				continue
+			}
+
+			selector, err := metav1.LabelSelectorAsSelector(tsc.LabelSelector)
+			if err != nil {
+				klog.V(3).Infof("Ignoring invalid topology spread constraint of pod %s/%s: %s", pod.Namespace, pod.Name, err)
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s/%s/%d", pod.Namespace, selector, tsc.TopologyKey, tsc.MaxSkew)
+			c, ok := shared[key]
+			if !ok {
+				c = &spreadConstraint{
+					maxSkew:    int(tsc.MaxSkew),
+					perZone:    isZoneKey(tsc.TopologyKey),
+					matches:    make([]bool, len(pods)),
+					zoneCounts: make(map[string]int),
+				}
+				for j, other := range pods {
+					if other.Namespace == pod.Namespace && selector.Matches(labels.Set(other.Labels)) {
+						c.matches[j] = true
+						t.counted[j] = append(t.counted[j], c)
+					}
+				}
+				shared[key] = c
+				t.constraints = append(t.constraints, c)
+			}
+			t.declared[i] = append(t.declared[i], c)
+		}
+	}
+
+	return t
+}
+
+// zoneAllowed returns true if pod i can be placed in the zone
+func (t *topology) zoneAllowed(i int, zone string) bool {
+	if t.allowedZones[i] != nil && !util.Contains(t.allowedZones[i], zone) {
+		return false
+	}
+
+	for _, c := range t.declared[i] {
+		if !c.perZone {
+			continue
+		}
+		if c.zoneCounts[zone]+t.self(c, i)-c.minZoneCount(t.zones) > c.maxSkew {
+			return false
+		}
+	}
+	return true
+}
+
+// allows returns true if pod i can be placed on the open bin b
+func (t *topology) allows(i int, b int) bool {
+	if !t.zoneAllowed(i, t.binZones[b]) {
+		return false
+	}
+
+	for _, c := range t.declared[i] {
+		if c.perZone {
+			continue
+		}
+		if c.nodeCounts[b]+t.self(c, i)-c.minNodeCount(t.reserved) > c.maxSkew {
+			return false
+		}
+	}
+	return true
+}
+
+// newBinZone returns the zone of a new bin for pod i: the allowed zone with the
+// fewest nodes. It returns false when the node affinity and the zone spread of
+// the pod allow none of the zones, in which case the pod cannot be placed.
+func (t *topology) newBinZone(i int) (string, bool) {
+	zones := append([]string(nil), t.zones...)
+	sort.SliceStable(zones, func(a, b int) bool {
+		return t.zoneNodes[zones[a]] < t.zoneNodes[zones[b]]
+	})
+
+	for _, zone := range zones {
+		if t.zoneAllowed(i, zone) {
+			return zone, true
+		}
+	}
+	return "", false
+}
+
+// zoneReason returns the unschedulable reason of pod i when no zone allows it,
+// which is the max skew of its zone spread within the zones its node affinity
+// allows
+func (t *topology) zoneReason(i int) string {
+	reason := "zone topology spread"
+	for _, c := range t.declared[i] {
+		if c.perZone {
+			reason = fmt.Sprintf("zone topology spread max skew %d", c.maxSkew)
+			break
+		}
+	}
+	if t.allowedZones[i] != nil {
+		reason += " within zones " + strings.Join(t.allowedZones[i], ",")
+	}
+	return reason
+}
+
+// open adds a new bin in the zone
+func (t *topology) open(zone string) {
+	t.binZones = append(t.binZones, zone)
+	t.zoneNodes[zone]++
+	for _, c := range t.constraints {
+		if !c.perZone {
+			c.nodeCounts = append(c.nodeCounts, 0)
+		}
+	}
+}
+
+// close removes the last bin, which must be empty
+func (t *topology) close() {
+	last := len(t.binZones) - 1
+	t.zoneNodes[t.binZones[last]]--
+	t.binZones = t.binZones[:last]
+	for _, c := range t.constraints {
+		if !c.perZone {
+			c.nodeCounts = c.nodeCounts[:last]
+		}
+	}
+}
+
+// place records pod i on bin b
+func (t *topology) place(i int, b int) {
+	t.update(i, b, 1)
+}
+
+// remove reverts the placement of pod i on bin b
+func (t *topology) remove(i int, b int) {
+	t.update(i, b, -1)
+}
+
+func (t *topology) update(i int, b int, delta int) {
+	for _, c := range t.counted[i] {
+		if c.perZone {
+			c.zoneCounts[t.binZones[b]] += delta
+		} else {
+			c.nodeCounts[b] += delta
+		}
+	}
+}
+
+// spreadHolds returns true if the hostname spread of the pods holds across all of
+// the open bins. It may not when pods were stacked on the first bins before the
+// others were opened, unless enough bins were reserved.
+func (t *topology) spreadHolds() bool {
+	for _, c := range t.constraints {
+		if c.perZone {
+			continue
+		}
+		max := 0
+		for _, n := range c.nodeCounts {
+			if n > max {
+				max = n
+			}
+		}
+		if max-c.minNodeCount(0) > c.maxSkew {
+			return false
+		}
+	}
+	return true
+}
+
+// reset removes all of the bins and reserves the given number of bins for the
+// hostname spread. Placements made against at least as many bins as end up open
+// keep the spread across all of them.
+func (t *topology) reset(reserved int) {
+	t.binZones = nil
+	t.zoneNodes = make(map[string]int)
+	t.reserved = reserved
+	for _, c := range t.constraints {
+		c.zoneCounts = make(map[string]int)
+		c.nodeCounts = nil
+	}
+}
+
+// constrained returns true if any of the pods is restricted to some zones or
+// declares a spread constraint, in which case bins are not interchangeable.
+func (t *topology) constrained() bool {
+	if len(t.constraints) > 0 {
+		return true
+	}
+	for _, zones := range t.allowedZones {
+		if zones != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *topology) self(c *spreadConstraint, i int) int {
+	if c.matches[i] {
+		return 1
+	}
+	return 0
+}
+
+func (c *spreadConstraint) minZoneCount(zones []string) int {
+	min := -1
+	for _, zone := range zones {
+		if n := c.zoneCounts[zone]; min == -1 || n < min {
+			min = n
+		}
+	}
+	return min
+}
+
+// minNodeCount returns the fewest pods counted on a bin, which is 0 while fewer
+// than reserved bins are open
+func (c *spreadConstraint) minNodeCount(reserved int) int {
+	if len(c.nodeCounts) < reserved {
+		return 0
+	}
+	min := 0
+	for i, n := range c.nodeCounts {
+		if i == 0 || n < min {
+			min = n
+		}
+	}
+	return min
+}
+
+// ZoneCount is the number of nodes within a single zone
+type ZoneCount struct {
+	Zone    string `json:"zone"`
+	Current int    `json:"current"`
+	Count   int    `json:"count"`
+}
+
+// zoneCounts returns the number of nodes per zone of the problem, along with the
+// current number of nodes in each zone.
+func zoneCounts(problem *Problem, nodes []*Node) []*ZoneCount {
+	counts := make(map[string]*ZoneCount)
+	for _, zone := range problem.zones() {
+		counts[zone] = &ZoneCount{Zone: zone, Current: problem.CurrentZones[zone]}
+	}
+	for _, node := range nodes {
+		zc, ok := counts[node.Zone]
+		if !ok {
+			zc = &ZoneCount{Zone: node.Zone}
+			counts[node.Zone] = zc
+		}
+		zc.Count++
+	}
+
+	var result []*ZoneCount
+	for _, zc := range counts {
+		result = append(result, zc)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Zone < result[j].Zone
+	})
+	return result
+}
+
+// padZones adds empty nodes of the instance type to the zones holding less than
+// min nodes and returns the added nodes.
+func padZones(problem *Problem, nodes []*Node, t *InstanceType, min int) []*Node {
+	var added []*Node
+	for _, zc := range zoneCounts(problem, nodes) {
+		if zc.Zone == "" {
+			continue
+		}
+		for n := zc.Count; n < min; n++ {
+			added = append(added, &Node{
+				Name:     fmt.Sprintf("node%d", len(nodes)+len(added)),
+				Type:     t,
+				Zone:     zc.Zone,
+				Capacity: t.Capacity.Sub(problem.Overhead),
+			})
+		}
+	}
+	return added
+}
@@ -0,0 +1,250 @@
+package optimizer
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// spread is a DoNotSchedule topology spread constraint of the app pods across
+// the topology key
+func spread(topologyKey string, maxSkew int32, app string) []v1.TopologySpreadConstraint {
+	return []v1.TopologySpreadConstraint{{
+		MaxSkew:           maxSkew,
+		TopologyKey:       topologyKey,
+		WhenUnsatisfiable: v1.DoNotSchedule,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+	}}
+}
+
+// appPods returns n pods of the app with the same requests
+func appPods(n int, app string, requests Resources) []*Pod {
+	pods := make([]*Pod, n)
+	for i := range pods {
+		pods[i] = &Pod{
+			Name:      fmt.Sprintf("%s-%d", app, i),
+			Namespace: "default",
+			NodeGroup: "0",
+			Requests:  requests,
+			Labels:    map[string]string{"app": app},
+		}
+	}
+	return pods
+}
+
+// podsPerNode returns the number of pods on each of the nodes
+func podsPerNode(nodes []*Node) []int {
+	counts := make([]int, len(nodes))
+	for i, node := range nodes {
+		counts[i] = len(node.Pods)
+	}
+	return counts
+}
+
+// appPodsPerZone returns the number of app pods in each of the zones
+func appPodsPerZone(nodes []*Node, zones []string, app string) []int {
+	counts := make([]int, len(zones))
+	for _, node := range nodes {
+		for z, zone := range zones {
+			if node.Zone != zone {
+				continue
+			}
+			for _, pod := range node.Pods {
+				if pod.Labels["app"] == app {
+					counts[z]++
+				}
+			}
+		}
+	}
+	return counts
+}
+
+func skew(counts []int) int {
+	if len(counts) == 0 {
+		return 0
+	}
+	min, max := counts[0], counts[0]
+	for _, n := range counts {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max - min
+}
+
+func TestHostnameSpread(t *testing.T) {
+	// a single node fits 6 of the 7 pods, which must still be spread evenly
+	// across both nodes
+	pods := appPods(7, "web", Resources{CPU: 1000, Memory: bytesPerGiB})
+	for _, pod := range pods {
+		pod.TopologySpreadConstraints = spread(v1.LabelHostname, 1, "web")
+	}
+	problem := &Problem{NodeGroup: "0", Pods: pods}
+	types := []*InstanceType{{Name: "c6", Capacity: Resources{CPU: 6000, Memory: 16 * bytesPerGiB}, Cost: 1}}
+
+	for _, strategy := range Strategies() {
+		t.Run(strategy, func(t *testing.T) {
+			options := DefaultOptions()
+			options.Strategy = strategy
+			o := NewOptimizer(options)
+
+			solutions := o.Solve(problem, types)
+			if len(solutions) != 1 || !solutions[0].Feasible() {
+				t.Fatalf("expected a feasible solution, got %+v", solutions)
+			}
+			counts := podsPerNode(solutions[0].Nodes)
+			if len(counts) != 2 || skew(counts) > 1 {
+				t.Errorf("expected 2 nodes within a skew of 1, got %v pods per node", counts)
+			}
+
+			mix := o.SolveMix(problem, solutions)
+			if mix == nil {
+				t.Fatal("expected a mix")
+			}
+			if counts := podsPerNode(mix.Nodes); len(counts) != 2 || skew(counts) > 1 {
+				t.Errorf("expected a mix of 2 nodes within a skew of 1, got %v pods per node", counts)
+			}
+		})
+	}
+}
+
+func TestZones(t *testing.T) {
+	zones := map[string]int{"us-east-1a": 3, "us-east-1b": 1, "us-east-1c": 1}
+	types := []*InstanceType{cpuType("c4", 4, 1)}
+
+	tests := []struct {
+		name            string
+		pods            []*Pod
+		minNodesPerZone int
+		nodes           int
+		zones           string
+	}{
+		{
+			name:            "padding",
+			pods:            cpuPods(1),
+			minNodesPerZone: 1,
+			nodes:           3,
+			zones:           "us-east-1a: 1 (was 3), us-east-1b: 1 (was 1), us-east-1c: 1 (was 1)",
+		},
+		{
+			name:            "min nodes per zone",
+			pods:            cpuPods(1),
+			minNodesPerZone: 2,
+			nodes:           6,
+			zones:           "us-east-1a: 2 (was 3), us-east-1b: 2 (was 1), us-east-1c: 2 (was 1)",
+		},
+		{
+			name:  "no padding",
+			pods:  cpuPods(1),
+			nodes: 1,
+			zones: "us-east-1a: 1 (was 3), us-east-1b: 0 (was 1), us-east-1c: 0 (was 1)",
+		},
+		{
+			// new nodes are opened in the zone holding the fewest
+			name:  "balance",
+			pods:  cpuPods(4, 4, 4, 4, 4),
+			nodes: 5,
+			zones: "us-east-1a: 2 (was 3), us-east-1b: 2 (was 1), us-east-1c: 1 (was 1)",
+		},
+	}
+
+	for _, test := range tests {
+		problem := &Problem{NodeGroup: "0", Pods: test.pods, CurrentZones: zones}
+		options := DefaultOptions()
+		options.MinNodesPerZone = test.minNodesPerZone
+		o := NewOptimizer(options)
+
+		solutions := o.Solve(problem, types)
+		if len(solutions) != 1 {
+			t.Fatalf("%s: expected a solution, got %d", test.name, len(solutions))
+		}
+		s := solutions[0]
+		checkNodes(t, problem, s.Nodes, s.Unschedulable)
+		if s.NumNodes() != test.nodes || s.LowerBound != test.nodes || !s.Optimal {
+			t.Errorf("%s: expected an optimal %d nodes, got %d out of a lower bound of %d", test.name, test.nodes, s.NumNodes(), s.LowerBound)
+		}
+		if z := FormatZones(s.Zones); z != test.zones {
+			t.Errorf("%s: expected %s, got %s", test.name, test.zones, z)
+		}
+
+		mix := o.SolveMix(problem, solutions)
+		if mix == nil {
+			t.Fatalf("%s: expected a mix", test.name)
+		}
+		if z := FormatZones(mix.Zones); z != test.zones {
+			t.Errorf("%s: expected a mix of %s, got %s", test.name, test.zones, z)
+		}
+
+		var buf bytes.Buffer
+		if err := WriteZones(&buf, s, mix); err != nil {
+			t.Fatal(err)
+		}
+		if records := readCSV(t, buf.Bytes()); len(records) != 7 || records[1][0] != "c4" || records[4][0] != mix.String() {
+			t.Errorf("%s: expected the zones of the solution and of the mix, got %v", test.name, records)
+		}
+	}
+}
+
+func TestZoneSpread(t *testing.T) {
+	zones := []string{"us-east-1a", "us-east-1b", "us-east-1c"}
+	currentZones := map[string]int{"us-east-1a": 1, "us-east-1b": 1, "us-east-1c": 1}
+	types := []*InstanceType{cpuType("c16", 16, 1)}
+
+	// a single node fits all of the pods, which must still be spread across
+	// the zones
+	pods := appPods(7, "web", Resources{CPU: 1000, Memory: bytesPerGiB})
+	for _, pod := range pods {
+		pod.TopologySpreadConstraints = spread(v1.LabelZoneFailureDomainStable, 1, "web")
+	}
+	problem := &Problem{NodeGroup: "0", Pods: pods, CurrentZones: currentZones}
+
+	for _, strategy := range Strategies() {
+		options := DefaultOptions()
+		options.Strategy = strategy
+		o := NewOptimizer(options)
+
+		solutions := o.Solve(problem, types)
+		if len(solutions) != 1 || !solutions[0].Feasible() {
+			t.Fatalf("%s: expected a feasible solution, got %+v", strategy, solutions)
+		}
+		checkNodes(t, problem, solutions[0].Nodes, nil)
+		if counts := appPodsPerZone(solutions[0].Nodes, zones, "web"); skew(counts) > 1 {
+			t.Errorf("%s: expected the pods within a skew of 1 across zones, got %v", strategy, counts)
+		}
+		if n := solutions[0].NumNodes(); n != 3 {
+			t.Errorf("%s: expected a node per zone, got %d", strategy, n)
+		}
+
+		mix := o.SolveMix(problem, solutions)
+		if mix == nil {
+			t.Fatalf("%s: expected a mix", strategy)
+		}
+		if counts := appPodsPerZone(mix.Nodes, zones, "web"); skew(counts) > 1 {
+			t.Errorf("%s: expected the mix pods within a skew of 1 across zones, got %v", strategy, counts)
+		}
+	}
+
+	// pods restricted to a single zone cannot spread, and all but the first
+	// are unschedulable
+	for _, pod := range pods {
+		pod.Affinity = requiredAffinity(v1.LabelZoneFailureDomainStable, v1.NodeSelectorOpIn, "us-east-1a")
+	}
+	solutions := NewOptimizer(DefaultOptions()).Solve(problem, types)
+	if len(solutions) != 1 {
+		t.Fatalf("expected a solution, got %d", len(solutions))
+	}
+	s := solutions[0]
+	checkNodes(t, problem, s.Nodes, s.Unschedulable)
+	if len(s.Unschedulable) != 6 {
+		t.Fatalf("expected 6 unschedulable pods, got %d", len(s.Unschedulable))
+	}
+	if reason := s.Unschedulable[0].Reason; reason != "zone topology spread max skew 1 within zones us-east-1a" {
+		t.Errorf("unexpected reason %q", reason)
+	}
+}
@@ -0,0 +1,111 @@
+package optimizer
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Resources represents a two dimensional (CPU, memory) resource vector. CPU is
+// expressed in milli cores and memory in bytes, matching the req_cpu_milli_core
+// and req_mem_byte columns of the pods export.
+type Resources struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+// Add returns the sum of both resource vectors
+func (r Resources) Add(o Resources) Resources {
+	return Resources{CPU: r.CPU + o.CPU, Memory: r.Memory + o.Memory}
+}
+
+// Sub returns the difference of both resource vectors
+func (r Resources) Sub(o Resources) Resources {
+	return Resources{CPU: r.CPU - o.CPU, Memory: r.Memory - o.Memory}
+}
+
+// Fits returns true if r fits within the capacity c
+func (r Resources) Fits(c Resources) bool {
+	return r.CPU <= c.CPU && r.Memory <= c.Memory
+}
+
+// String returns a human readable representation of the resources
+func (r Resources) String() string {
+	return fmt.Sprintf("cpu: %dm, memory: %d", r.CPU, r.Memory)
+}
+
+// Pod is a single item to be packed
+type Pod struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	OwnerKind string `json:"ownerKind"`
+	OwnerName string `json:"ownerName"`
+	NodeGroup string `json:"nodeGroup"`
+
+	Requests Resources `json:"requests"`
+
+	// Scheduling constraints of the pod
+	Labels       map[string]string `json:"labels,omitempty"`
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	Affinity     *v1.Affinity      `json:"affinity,omitempty"`
+	Tolerations  []v1.Toleration   `json:"tolerations,omitempty"`
+
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+}
+
+// InstanceType is a candidate node type along with its hourly cost
+type InstanceType struct {
+	Name     string    `json:"name"`
+	Capacity Resources `json:"capacity"`
+	Cost     float64   `json:"cost"`
+}
+
+// Node is a single bin of a solution, holding the pods placed on it
+type Node struct {
+	Name string        `json:"name"`
+	Type *InstanceType `json:"type"`
+	Zone string        `json:"zone"`
+	Pods []*Pod        `json:"pods"`
+
+	// Capacity is the capacity available to the packed pods, i.e. the instance
+	// type capacity minus the per node overhead
+	Capacity Resources `json:"capacity"`
+	Used     Resources `json:"used"`
+}
+
+// Solution is the result of packing a node group onto a single instance type
+type Solution struct {
+	NodeGroup string        `json:"nodeGroup"`
+	Type      *InstanceType `json:"type"`
+	Capacity  Resources     `json:"capacity"`
+	Nodes     []*Node       `json:"nodes"`
+	Cost      float64       `json:"cost"`
+
+	// Strategy is the packing strategy which produced the placement
+	Strategy string `json:"strategy"`
+
+	// LowerBound is a lower bound on the number of nodes of any placement, and
+	// Gap is the relative distance of the placement from it
+	LowerBound int     `json:"lowerBound"`
+	Gap        float64 `json:"gap"`
+
+	// Optimal is true if no placement with fewer nodes exists
+	Optimal bool `json:"optimal"`
+
+	// Unschedulable are the pods which cannot be placed on the instance type due
+	// to their scheduling constraints
+	Unschedulable []*Unschedulable `json:"unschedulable,omitempty"`
+
+	// Zones is the number of nodes per zone
+	Zones []*ZoneCount `json:"zones,omitempty"`
+}
+
+// NumNodes returns the number of nodes used by the solution
+func (s *Solution) NumNodes() int {
+	return len(s.Nodes)
+}
+
+// Feasible returns true if all the pods of the node group were placed
+func (s *Solution) Feasible() bool {
+	return len(s.Nodes) > 0 && len(s.Unschedulable) == 0
+}
@@ -0,0 +1,92 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// WriteCSV writes the table as a CSV with a header row
+func WriteCSV(w io.Writer, t *Table) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	return cw.WriteAll(t.Rows)
+}
+
+// WriteJSONL writes every row of the table as a JSON object on its own line,
+// keyed by the column names in column order. Numeric columns are written as
+// numbers, and empty cells and cells of numeric columns which aren't a finite
+// number as null.
+func WriteJSONL(w io.Writer, t *Table) error {
+	types := columnTypes(t)
+	keys := make([][]byte, len(t.Columns))
+	for i, column := range t.Columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, row := range t.Rows {
+		bw.WriteByte('{')
+		for i := range t.Columns {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.Write(keys[i])
+			bw.WriteByte(':')
+
+			encoded, err := json.Marshal(typedValue(cell(row, i), types[i]))
+			if err != nil {
+				return err
+			}
+			bw.Write(encoded)
+		}
+		bw.WriteString("}\n")
+	}
+	return bw.Flush()
+}
+
+// WriteParquet writes the table as a Parquet file with an optional column per
+// table column, typed INT64, DOUBLE or UTF8 strings. Empty cells and cells of
+// numeric columns which aren't a finite number are null.
+func WriteParquet(w io.Writer, t *Table) error {
+	types := columnTypes(t)
+	schema := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		switch types[i] {
+		case intColumn:
+			schema[i] = fmt.Sprintf("name=%s, type=INT64, repetitiontype=OPTIONAL", column)
+		case floatColumn:
+			schema[i] = fmt.Sprintf("name=%s, type=DOUBLE, repetitiontype=OPTIONAL", column)
+		default:
+			schema[i] = fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", column)
+		}
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(schema, w, 1)
+	if err != nil {
+		return fmt.Errorf("failed creating parquet writer: %s", err)
+	}
+	for _, row := range t.Rows {
+		values := make([]interface{}, len(t.Columns))
+		for i := range t.Columns {
+			values[i] = typedValue(cell(row, i), types[i])
+		}
+		if err := pw.Write(values); err != nil {
+			return fmt.Errorf("failed writing parquet row: %s", err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("failed writing parquet footer: %s", err)
+	}
+	return nil
+}
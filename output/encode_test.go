@@ -0,0 +1,112 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// testTable holds names and numbers which look alike
+func testTable() *Table {
+	return &Table{
+		Name:    "pods",
+		Columns: []string{"namespace", "pod_name", "req_cpu_milli_core", "hourly_cost"},
+		Rows: [][]string{
+			{"2024", "007", "+5", "NaN"},
+			{"default", "0123456789abcdef", "007", "+Inf"},
+			{"kube-system", "dns-0", "", "0.25"},
+			{"3", "1e3", "not a number", "1e3"},
+		},
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSONL(&buf, testTable()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]interface{}{
+		{"namespace": "2024", "pod_name": "007", "req_cpu_milli_core": 5.0, "hourly_cost": nil},
+		{"namespace": "default", "pod_name": "0123456789abcdef", "req_cpu_milli_core": 7.0, "hourly_cost": nil},
+		{"namespace": "kube-system", "pod_name": "dns-0", "req_cpu_milli_core": nil, "hourly_cost": 0.25},
+		{"namespace": "3", "pod_name": "1e3", "req_cpu_milli_core": nil, "hourly_cost": 1000.0},
+	}
+	scanner := bufio.NewScanner(&buf)
+	for i := 0; scanner.Scan(); i++ {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("line %d is not valid JSON: %s: %s", i+1, err, scanner.Text())
+		}
+		if i >= len(expected) {
+			t.Fatalf("expected %d lines, got more", len(expected))
+		}
+		if !reflect.DeepEqual(row, expected[i]) {
+			t.Errorf("line %d: expected %v, got %v", i+1, expected[i], row)
+		}
+	}
+}
+
+// bufferFile reads a parquet file from memory
+type bufferFile struct {
+	*bytes.Reader
+}
+
+func (f bufferFile) Write(p []byte) (int, error)                    { return 0, io.ErrShortWrite }
+func (f bufferFile) Close() error                                   { return nil }
+func (f bufferFile) Open(name string) (source.ParquetFile, error)   { return f, nil }
+func (f bufferFile) Create(name string) (source.ParquetFile, error) { return nil, io.ErrShortWrite }
+
+func TestWriteParquet(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, testTable()); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := reader.NewParquetColumnReader(bufferFile{bytes.NewReader(buf.Bytes())}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+
+	// the types follow the columns, not their values
+	types := make(map[string]parquet.Type)
+	for i, element := range pr.SchemaHandler.SchemaElements {
+		if element.Type != nil {
+			types[pr.SchemaHandler.Infos[i].ExName] = *element.Type
+		}
+	}
+	expectedTypes := map[string]parquet.Type{
+		"namespace":          parquet.Type_BYTE_ARRAY,
+		"pod_name":           parquet.Type_BYTE_ARRAY,
+		"req_cpu_milli_core": parquet.Type_INT64,
+		"hourly_cost":        parquet.Type_DOUBLE,
+	}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Errorf("expected the column types %v, got %v", expectedTypes, types)
+	}
+
+	rows := int64(len(testTable().Rows))
+	expectedValues := map[string][]interface{}{
+		"namespace":          {"2024", "default", "kube-system", "3"},
+		"req_cpu_milli_core": {int64(5), int64(7), nil, nil},
+		"hourly_cost":        {nil, nil, 0.25, 1000.0},
+	}
+	for column, expected := range expectedValues {
+		values, _, _, err := pr.ReadColumnByPath(common.PathToStr([]string{pr.SchemaHandler.GetRootExName(), column}), rows)
+		if err != nil {
+			t.Fatalf("failed reading %s: %s", column, err)
+		}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("%s: expected %v, got %v", column, expected, values)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The supported formats of the tables
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// Stdout is the directory of the sinks writing to the standard output
+const Stdout = "-"
+
+// Sink writes the tables and files exported by the commands
+type Sink interface {
+	// WriteTable writes the table
+	WriteTable(t *Table) error
+
+	// WriteFile writes an output which isn't a table, such as a JSON report, as
+	// is
+	WriteFile(name string, data []byte) error
+}
+
+// NewSink creates a sink writing the tables in the format into the directory,
+// which is created when missing. Tables named e.g. pods.csv are written to
+// pods.csv, pods.jsonl or pods.parquet. The Stdout directory writes tables and
+// files one after the other to the standard output, each preceded by its name,
+// and doesn't support Parquet.
+func NewSink(format string, dir string) (Sink, error) {
+	var encode func(io.Writer, *Table) error
+	switch format {
+	case FormatCSV:
+		encode = WriteCSV
+	case FormatJSONL:
+		encode = WriteJSONL
+	case FormatParquet:
+		encode = WriteParquet
+	default:
+		return nil, fmt.Errorf("unsupported output format %q, expected %s, %s or %s", format, FormatCSV, FormatJSONL, FormatParquet)
+	}
+
+	if dir == Stdout {
+		if format == FormatParquet {
+			return nil, fmt.Errorf("parquet output can't be written to the standard output")
+		}
+		return &streamSink{w: os.Stdout, format: format, encode: encode}, nil
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed creating output directory %s: %s", dir, err)
+	}
+	return &dirSink{dir: dir, format: format, encode: encode}, nil
+}
+
+// dirSink writes every table and file to its own file in a directory
+type dirSink struct {
+	dir    string
+	format string
+	encode func(io.Writer, *Table) error
+}
+
+func (s *dirSink) WriteTable(t *Table) error {
+	file := filepath.Join(s.dir, fileName(t.Name, s.format))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := s.encode(f, t); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *dirSink) WriteFile(name string, data []byte) error {
+	file := filepath.Join(s.dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// streamSink writes the tables and files one after the other to a writer
+type streamSink struct {
+	w      io.Writer
+	format string
+	encode func(io.Writer, *Table) error
+}
+
+func (s *streamSink) WriteTable(t *Table) error {
+	var buf bytes.Buffer
+	if err := s.encode(&buf, t); err != nil {
+		return err
+	}
+	return s.WriteFile(fileName(t.Name, s.format), buf.Bytes())
+}
+
+func (s *streamSink) WriteFile(name string, data []byte) error {
+	if _, err := fmt.Fprintf(s.w, "==> %s <==\n", name); err != nil {
+		return err
+	}
+	_, err := s.w.Write(data)
+	return err
+}
+
+// fileName replaces the extension of the table name with the one of the format
+func fileName(name string, format string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "." + format
+}
+
+// prefixSink writes the tables and files to a sink, with their names prefixed by
+// a directory
+type prefixSink struct {
+	sink   Sink
+	prefix string
+}
+
+// NewPrefixSink creates a sink writing the tables and files into the prefix
+// directory of the sink, e.g. a pods.csv table as prefix/pods.csv
+func NewPrefixSink(sink Sink, prefix string) Sink {
+	return &prefixSink{sink: sink, prefix: prefix}
+}
+
+func (s *prefixSink) WriteTable(t *Table) error {
+	prefixed := *t
+	prefixed.Name = path.Join(s.prefix, t.Name)
+	return s.sink.WriteTable(&prefixed)
+}
+
+func (s *prefixSink) WriteFile(name string, data []byte) error {
+	return s.sink.WriteFile(path.Join(s.prefix, name), data)
+}
+
+// MemorySink keeps the tables and files written to it by name, for running the
+// commands without writing files
+type MemorySink struct {
+	Tables map[string]*Table
+	Files  map[string][]byte
+}
+
+// NewMemorySink creates an empty MemorySink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{
+		Tables: make(map[string]*Table),
+		Files:  make(map[string][]byte),
+	}
+}
+
+func (s *MemorySink) WriteTable(t *Table) error {
+	s.Tables[t.Name] = t
+	return nil
+}
+
+func (s *MemorySink) WriteFile(name string, data []byte) error {
+	s.Files[name] = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,168 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Table is a named table of cells, as written to a CSV file with a header row
+type Table struct {
+	Name    string
+	Columns []string
+	Rows    [][]string
+}
+
+// ReadCSV reads a table from a CSV whose first record holds the column names
+func ReadCSV(name string, r io.Reader) (*Table, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %s: %s", name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("failed reading %s: missing header", name)
+	}
+	return &Table{Name: name, Columns: records[0], Rows: records[1:]}, nil
+}
+
+// PrependColumn inserts a first column holding the value on every row
+func (t *Table) PrependColumn(name string, value string) {
+	t.Columns = append([]string{name}, t.Columns...)
+	for i, row := range t.Rows {
+		t.Rows[i] = append([]string{value}, row...)
+	}
+}
+
+// Append appends the rows of other, which must have the same columns
+func (t *Table) Append(other *Table) error {
+	if len(t.Columns) != len(other.Columns) {
+		return fmt.Errorf("failed appending to %s: %d columns, expected %d", t.Name, len(other.Columns), len(t.Columns))
+	}
+	for i, column := range t.Columns {
+		if other.Columns[i] != column {
+			return fmt.Errorf("failed appending to %s: column %d is %s, expected %s", t.Name, i+1, other.Columns[i], column)
+		}
+	}
+	t.Rows = append(t.Rows, other.Rows...)
+	return nil
+}
+
+// columnType is the type of the values of a column
+type columnType int
+
+const (
+	stringColumn columnType = iota
+	intColumn
+	floatColumn
+)
+
+// intColumns hold counts, milli cores and bytes
+var intColumns = map[string]bool{
+	"number_of_nodes":                  true,
+	"num_nodes":                        true,
+	"num_pods":                         true,
+	"unknown_nodes":                    true,
+	"current_nodes":                    true,
+	"recommended_nodes":                true,
+	"replicas":                         true,
+	"hpa_min_replicas":                 true,
+	"hpa_max_replicas":                 true,
+	"hpa_target_cpu_utilization":       true,
+	"lower_bound":                      true,
+	"unschedulable":                    true,
+	"cpu":                              true,
+	"node_cpu":                         true,
+	"cap_cpu_mili_core":                true,
+	"alloc_cpu_mili_core":              true,
+	"cap_memory_byte":                  true,
+	"alloc_bytes":                      true,
+	"req_cpu_milli_core":               true,
+	"req_mem_byte":                     true,
+	"req_ephemeral_storage_byte":       true,
+	"limit_cpu_milli_core":             true,
+	"limit_cpu_mili_core":              true,
+	"limit_mem_byte":                   true,
+	"limit_mem_bytes":                  true,
+	"limit_ephemeral_storage_byte":     true,
+	"usage_cpu_milli_core":             true,
+	"usage_mem_byte":                   true,
+	"usage_cpu_p50_milli_core":         true,
+	"usage_cpu_p95_milli_core":         true,
+	"usage_cpu_p99_milli_core":         true,
+	"usage_cpu_max_milli_core":         true,
+	"usage_mem_p50_byte":               true,
+	"usage_mem_p95_byte":               true,
+	"usage_mem_p99_byte":               true,
+	"usage_mem_max_byte":               true,
+	"recommended_req_cpu_milli_core":   true,
+	"recommended_req_mem_byte":         true,
+	"recommended_limit_cpu_milli_core": true,
+	"recommended_limit_mem_byte":       true,
+}
+
+// floatColumns hold costs, savings, megabytes and ratios
+var floatColumns = map[string]bool{
+	"cost":            true,
+	"unit_cost":       true,
+	"hourly_cost":     true,
+	"monthly_cost":    true,
+	"cpu_cost":        true,
+	"memory_cost":     true,
+	"hourly_savings":  true,
+	"monthly_savings": true,
+	"memory":          true,
+	"node_memory":     true,
+	"req_mem_mb":      true,
+	"gap":             true,
+}
+
+// columnTypes returns the type of every column by its name. Columns holding
+// names, such as a numeric namespace, are strings whatever their values are.
+func columnTypes(t *Table) []columnType {
+	types := make([]columnType, len(t.Columns))
+	for i, column := range t.Columns {
+		switch {
+		case intColumns[column]:
+			types[i] = intColumn
+		case floatColumns[column]:
+			types[i] = floatColumn
+		default:
+			types[i] = stringColumn
+		}
+	}
+	return types
+}
+
+// typedValue parses the cell of a column of the type, returning nil for empty
+// cells and for cells of numeric columns which aren't a finite number
+func typedValue(value string, t columnType) interface{} {
+	if value == "" {
+		return nil
+	}
+	switch t {
+	case intColumn:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil
+		}
+		return v
+	case floatColumn:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// cell returns the cell of the row in the column, empty when the row is short
+func cell(row []string, column int) string {
+	if column < len(row) {
+		return row[column]
+	}
+	return ""
+}
@@ -0,0 +1,448 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	awspricing "github.com/aws/aws-sdk-go/service/pricing"
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// DefaultCachePath is the default location of the AWS pricing cache file
+const DefaultCachePath = "aws_pricing_cache.json"
+
+// hoursPerYear is used to spread the upfront fees of reserved instances
+const hoursPerYear = util.HoursPerMonth * 12
+
+// ReservedTerm selects the reserved instance offering used for the reserved
+// purchase option. The values match the term attributes of the price list.
+type ReservedTerm struct {
+	// LeaseContractLength is either 1yr or 3yr
+	LeaseContractLength string
+
+	// OfferingClass is either standard or convertible
+	OfferingClass string
+
+	// PurchaseOption is one of No Upfront, Partial Upfront or All Upfront
+	PurchaseOption string
+}
+
+// AWSOptions configures the AWS pricing provider
+type AWSOptions struct {
+	// PricingEndpoint and EC2Endpoint override the endpoints of the Price List and
+	// EC2 APIs, i.e. to run against a local stand-in. The default endpoints are
+	// used when empty.
+	PricingEndpoint string
+	EC2Endpoint     string
+
+	// Credentials used for both APIs. When nil, the access key of the
+	// environment is used if set, falling back to the default credential chain.
+	Credentials *credentials.Credentials
+
+	// CachePath is the local cache file of the fetched prices. Caching is
+	// disabled when empty.
+	CachePath string
+
+	// CacheTTL is the age after which cached prices are fetched again. Stale
+	// prices are still used when fetching fails.
+	CacheTTL time.Duration
+
+	// Offline only uses the cached prices, without calling the APIs
+	Offline bool
+
+	// ReservedTerm is the offering used for the reserved purchase option
+	ReservedTerm ReservedTerm
+}
+
+// DefaultAWSOptions returns the default options: prices are cached for a day in
+// DefaultCachePath and reserved prices are for a one year, standard, no upfront
+// term.
+func DefaultAWSOptions() *AWSOptions {
+	return &AWSOptions{
+		CachePath: DefaultCachePath,
+		CacheTTL:  24 * time.Hour,
+		ReservedTerm: ReservedTerm{
+			LeaseContractLength: "1yr",
+			OfferingClass:       "standard",
+			PurchaseOption:      "No Upfront",
+		},
+	}
+}
+
+// AWSProvider prices EC2 instance types using the AWS Price List API for the
+// on-demand and reserved purchase options, and the EC2 spot price history for
+// the spot purchase option.
+type AWSProvider struct {
+	options *AWSOptions
+	session *session.Session
+	cache   *Cache
+}
+
+// NewAWSProvider creates an AWS pricing provider, loading the cache file if any
+func NewAWSProvider(options *AWSOptions) (*AWSProvider, error) {
+	if options == nil {
+		options = DefaultAWSOptions()
+	}
+
+	creds := options.Credentials
+	if creds == nil && env.GetAWSAccessKeyID() != "" {
+		creds = credentials.NewStaticCredentials(env.GetAWSAccessKeyID(), env.GetAWSAccessKeySecret(), "")
+	}
+
+	// the price list api is only served from a few regions, us-east-1 being one
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Region:      aws.String(endpoints.UsEast1RegionID),
+			Credentials: creds,
+		},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &AWSProvider{
+		options: options,
+		session: sess,
+	}
+
+	if options.CachePath != "" {
+		p.cache, err = NewCache(options.CachePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// InstanceTypes returns the instance types of the region priced for the os and
+// purchase option of the key. Cached prices are returned while fresh, or when
+// fetching them fails.
+func (p *AWSProvider) InstanceTypes(key Key) ([]*InstanceType, error) {
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	var cached []*InstanceType
+	var found bool
+	if p.cache != nil {
+		var updated time.Time
+		cached, updated, found = p.cache.Get(key)
+		if found && (p.options.Offline || time.Since(updated) < p.options.CacheTTL) {
+			return cached, nil
+		}
+	}
+	if p.options.Offline {
+		return nil, fmt.Errorf("no cached prices for %s", key)
+	}
+
+	types, err := p.fetch(key)
+	if err != nil {
+		if found {
+			klog.Warningf("Failed fetching prices for %s, using cached prices: %s", key, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	if p.cache != nil {
+		if err := p.cache.Set(key, types); err != nil {
+			klog.Warningf("Failed writing pricing cache %s: %s", p.options.CachePath, err)
+		}
+	}
+	return types, nil
+}
+
+func (p *AWSProvider) fetch(key Key) ([]*InstanceType, error) {
+	products, err := p.getProducts(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var types []*InstanceType
+	switch key.PurchaseOption {
+	case OnDemand:
+		for _, product := range products {
+			if hourly, ok := product.onDemandHourly(); ok {
+				types = append(types, product.instanceType(hourly))
+			}
+		}
+	case Reserved:
+		for _, product := range products {
+			if hourly, ok := product.reservedHourly(p.options.ReservedTerm); ok {
+				types = append(types, product.instanceType(hourly))
+			}
+		}
+	case Spot:
+		prices, err := p.getSpotPrices(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			if hourly, ok := prices[product.Product.Attributes.InstanceType]; ok {
+				types = append(types, product.instanceType(hourly))
+			}
+		}
+	}
+
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no prices found for %s", key)
+	}
+	sortByName(types)
+	return types, nil
+}
+
+// getProducts returns the shared tenancy products of the region and os, one per
+// instance type.
+func (p *AWSProvider) getProducts(key Key) ([]*awsProduct, error) {
+	filter := func(field string, value string) *awspricing.Filter {
+		return &awspricing.Filter{
+			Type:  aws.String(awspricing.FilterTypeTermMatch),
+			Field: aws.String(field),
+			Value: aws.String(value),
+		}
+	}
+
+	input := &awspricing.GetProductsInput{
+		FormatVersion: aws.String("aws_v1"),
+		ServiceCode:   aws.String("AmazonEC2"),
+		Filters: []*awspricing.Filter{
+			filter("regionCode", key.Region),
+			filter("operatingSystem", awsOperatingSystem(key.OperatingSystem)),
+			filter("licenseModel", "No License required"),
+			filter("tenancy", "Shared"),
+			filter("preInstalledSw", "NA"),
+			filter("capacitystatus", "Used"),
+		},
+	}
+
+	cfg := &aws.Config{}
+	if p.options.PricingEndpoint != "" {
+		cfg.Endpoint = aws.String(p.options.PricingEndpoint)
+	}
+	svc := awspricing.New(p.session, cfg)
+
+	seen := make(map[string]bool)
+	var products []*awsProduct
+	var parseErr error
+	err := svc.GetProductsPages(input, func(page *awspricing.GetProductsOutput, lastPage bool) bool {
+		for _, item := range page.PriceList {
+			product, err := parseProduct(item)
+			if err != nil {
+				parseErr = err
+				return false
+			}
+
+			name := product.Product.Attributes.InstanceType
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			products = append(products, product)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed getting products for %s: %s", key, err)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed parsing products for %s: %s", key, parseErr)
+	}
+
+	klog.V(3).Infof("Fetched %d products for %s", len(products), key)
+	return products, nil
+}
+
+// getSpotPrices returns the mean current spot price of each instance type over
+// the availability zones of the region.
+func (p *AWSProvider) getSpotPrices(key Key) (map[string]float64, error) {
+	cfg := &aws.Config{Region: aws.String(key.Region)}
+	if p.options.EC2Endpoint != "" {
+		cfg.Endpoint = aws.String(p.options.EC2Endpoint)
+	}
+	svc := ec2.New(p.session, cfg)
+
+	// a start time of now returns the current price of every zone
+	input := &ec2.DescribeSpotPriceHistoryInput{
+		StartTime:           aws.Time(time.Now()),
+		ProductDescriptions: []*string{aws.String(awsProductDescription(key.OperatingSystem))},
+	}
+
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	err := svc.DescribeSpotPriceHistoryPages(input, func(page *ec2.DescribeSpotPriceHistoryOutput, lastPage bool) bool {
+		for _, sp := range page.SpotPriceHistory {
+			price, err := strconv.ParseFloat(aws.StringValue(sp.SpotPrice), 64)
+			if err != nil {
+				continue
+			}
+			name := aws.StringValue(sp.InstanceType)
+			sums[name] += price
+			counts[name]++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed getting spot prices for %s: %s", key, err)
+	}
+
+	prices := make(map[string]float64, len(sums))
+	for name, sum := range sums {
+		prices[name] = sum / float64(counts[name])
+	}
+	return prices, nil
+}
+
+// awsOperatingSystem returns the operatingSystem attribute of the price list
+// products for the os
+func awsOperatingSystem(os string) string {
+	switch os {
+	case Windows:
+		return "Windows"
+	case RHEL:
+		return "RHEL"
+	case SUSE:
+		return "SUSE"
+	default:
+		return "Linux"
+	}
+}
+
+// awsProductDescription returns the spot price history product description of
+// the os
+func awsProductDescription(os string) string {
+	switch os {
+	case Windows:
+		return "Windows"
+	case RHEL:
+		return "Red Hat Enterprise Linux"
+	case SUSE:
+		return "SUSE Linux"
+	default:
+		return "Linux/UNIX"
+	}
+}
+
+// awsProduct is the subset of a price list product used for pricing
+type awsProduct struct {
+	Product struct {
+		Attributes struct {
+			InstanceType string `json:"instanceType"`
+			VCPU         string `json:"vcpu"`
+			Memory       string `json:"memory"`
+		} `json:"attributes"`
+	} `json:"product"`
+	Terms struct {
+		OnDemand map[string]*awsTerm `json:"OnDemand"`
+		Reserved map[string]*awsTerm `json:"Reserved"`
+	} `json:"terms"`
+}
+
+type awsTerm struct {
+	TermAttributes  map[string]string             `json:"termAttributes"`
+	PriceDimensions map[string]*awsPriceDimension `json:"priceDimensions"`
+}
+
+type awsPriceDimension struct {
+	Unit         string            `json:"unit"`
+	PricePerUnit map[string]string `json:"pricePerUnit"`
+}
+
+func parseProduct(item aws.JSONValue) (*awsProduct, error) {
+	// the price list items are decoded into generic maps by the sdk
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	product := &awsProduct{}
+	if err := json.Unmarshal(data, product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (p *awsProduct) instanceType(hourly float64) *InstanceType {
+	attributes := p.Product.Attributes
+	vcpus, _ := strconv.ParseFloat(attributes.VCPU, 64)
+	return &InstanceType{
+		Name:   attributes.InstanceType,
+		VCPUs:  vcpus,
+		Memory: parseGiB(attributes.Memory),
+		Hourly: hourly,
+	}
+}
+
+func (p *awsProduct) onDemandHourly() (float64, bool) {
+	for _, term := range p.Terms.OnDemand {
+		if hourly, ok := term.hourly(0); ok {
+			return hourly, true
+		}
+	}
+	return 0, false
+}
+
+func (p *awsProduct) reservedHourly(rt ReservedTerm) (float64, bool) {
+	years, err := strconv.Atoi(strings.TrimSuffix(rt.LeaseContractLength, "yr"))
+	if err != nil || years <= 0 {
+		return 0, false
+	}
+
+	for _, term := range p.Terms.Reserved {
+		attributes := term.TermAttributes
+		if attributes["LeaseContractLength"] != rt.LeaseContractLength ||
+			attributes["OfferingClass"] != rt.OfferingClass ||
+			attributes["PurchaseOption"] != rt.PurchaseOption {
+			continue
+		}
+		if hourly, ok := term.hourly(float64(years) * hoursPerYear); ok {
+			return hourly, true
+		}
+	}
+	return 0, false
+}
+
+// hourly normalizes the price dimensions of the term to an hourly cost, spreading
+// the upfront fees over the term hours. ok is false if the term has no priced
+// dimension.
+func (t *awsTerm) hourly(termHours float64) (float64, bool) {
+	var hourly float64
+	found := false
+	for _, dim := range t.PriceDimensions {
+		price, err := strconv.ParseFloat(dim.PricePerUnit["USD"], 64)
+		if err != nil {
+			continue
+		}
+
+		switch dim.Unit {
+		case "Hrs":
+			hourly += price
+			found = true
+		case "Quantity":
+			if termHours > 0 {
+				hourly += price / termHours
+				found = true
+			}
+		}
+	}
+	return hourly, found
+}
+
+// parseGiB parses a price list memory attribute, e.g. "1,952 GiB"
+func parseGiB(s string) float64 {
+	s = strings.TrimSpace(strings.TrimSuffix(s, "GiB"))
+	gib, _ := strconv.ParseFloat(strings.Replace(s, ",", "", -1), 64)
+	return gib
+}
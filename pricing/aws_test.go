@@ -0,0 +1,359 @@
+package pricing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// standIn serves the recorded Price List and spot price history responses of
+// testdata in place of the AWS APIs, counting the requests it receives
+type standIn struct {
+	pricing *httptest.Server
+	ec2     *httptest.Server
+
+	// failing makes every request fail
+	failing  int32
+	requests int32
+}
+
+func newStandIn(t *testing.T) *standIn {
+	products, err := ioutil.ReadFile(filepath.Join("testdata", "get_products.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spotPrices, err := ioutil.ReadFile(filepath.Join("testdata", "describe_spot_price_history.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &standIn{}
+	s.pricing = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+		var input struct {
+			ServiceCode string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || atomic.LoadInt32(&s.failing) == 1 ||
+			r.Header.Get("X-Amz-Target") != "AWSPriceListService.GetProducts" || input.ServiceCode != "AmazonEC2" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"InvalidParameterException","message":"unexpected request"}`))
+			return
+		}
+		w.Write(products)
+	}))
+	s.ec2 = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		if err := r.ParseForm(); err != nil || atomic.LoadInt32(&s.failing) == 1 ||
+			r.Form.Get("Action") != "DescribeSpotPriceHistory" || r.Form.Get("ProductDescription.1") != "Linux/UNIX" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<Response><Errors><Error><Code>InvalidParameterValue</Code><Message>unexpected request</Message></Error></Errors><RequestID>1</RequestID></Response>`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(spotPrices)
+	}))
+	return s
+}
+
+func (s *standIn) Close() {
+	s.pricing.Close()
+	s.ec2.Close()
+}
+
+// options returns the options of a provider calling the stand-in and caching
+// its prices to the path
+func (s *standIn) options(cachePath string) *AWSOptions {
+	options := DefaultAWSOptions()
+	options.PricingEndpoint = s.pricing.URL
+	options.EC2Endpoint = s.ec2.URL
+	options.Credentials = credentials.NewStaticCredentials("AKIDEXAMPLE", "secret", "")
+	options.CachePath = cachePath
+	return options
+}
+
+func tempCachePath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "pricing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, DefaultCachePath), func() { os.RemoveAll(dir) }
+}
+
+func assertPrices(t *testing.T, types []*InstanceType, expected map[string]float64) {
+	t.Helper()
+	if len(types) != len(expected) {
+		t.Fatalf("expected %d instance types, got %d: %v", len(expected), len(types), names(types))
+	}
+	for i, it := range types {
+		if i > 0 && types[i-1].Name >= it.Name {
+			t.Errorf("instance types are not sorted by name: %v", names(types))
+		}
+		hourly, ok := expected[it.Name]
+		if !ok {
+			t.Errorf("unexpected instance type %s", it.Name)
+			continue
+		}
+		if math.Abs(it.Hourly-hourly) > 1e-9 {
+			t.Errorf("expected %s to cost %f an hour, got %f", it.Name, hourly, it.Hourly)
+		}
+	}
+}
+
+func names(types []*InstanceType) []string {
+	var result []string
+	for _, it := range types {
+		result = append(result, it.Name)
+	}
+	return result
+}
+
+func TestAWSProviderPurchaseOptions(t *testing.T) {
+	s := newStandIn(t)
+	defer s.Close()
+
+	partialUpfront := s.options("")
+	partialUpfront.ReservedTerm.PurchaseOption = "Partial Upfront"
+
+	tests := []struct {
+		name     string
+		options  *AWSOptions
+		key      Key
+		expected map[string]float64
+	}{
+		{
+			name:    "on-demand",
+			options: s.options(""),
+			key:     Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: OnDemand},
+			expected: map[string]float64{
+				"m5.large":    0.096,
+				"m5.xlarge":   0.192,
+				"r5.12xlarge": 3.024,
+			},
+		},
+		{
+			name:    "reserved",
+			options: s.options(""),
+			key:     Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: Reserved},
+			expected: map[string]float64{
+				"m5.large":    0.06,
+				"m5.xlarge":   0.121,
+				"r5.12xlarge": 1.905,
+			},
+		},
+		{
+			name:    "reserved partial upfront",
+			options: partialUpfront,
+			key:     Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: Reserved},
+			expected: map[string]float64{
+				"m5.large":    0.029 + 252/hoursPerYear,
+				"m5.xlarge":   0.058 + 504/hoursPerYear,
+				"r5.12xlarge": 0.907 + 7948/hoursPerYear,
+			},
+		},
+		{
+			// spot prices are averaged over the zones, and instance types without
+			// a product or a spot price are skipped
+			name:    "spot",
+			options: s.options(""),
+			key:     Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: Spot},
+			expected: map[string]float64{
+				"m5.large":  (0.0354 + 0.0374) / 2,
+				"m5.xlarge": 0.0712,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := NewAWSProvider(test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			types, err := p.InstanceTypes(test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertPrices(t, types, test.expected)
+		})
+	}
+
+	t.Run("memory and vcpus", func(t *testing.T) {
+		p, err := NewAWSProvider(s.options(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		types, err := p.InstanceTypes(Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: OnDemand})
+		if err != nil {
+			t.Fatal(err)
+		}
+		it := Index(types)["r5.12xlarge"]
+		if it == nil || it.VCPUs != 48 || it.Memory != 384 {
+			t.Errorf("expected r5.12xlarge to have 48 vCPUs and 384 GiB, got %+v", it)
+		}
+	})
+}
+
+func TestAWSProviderCache(t *testing.T) {
+	s := newStandIn(t)
+	defer s.Close()
+	key := Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: OnDemand}
+	expected := map[string]float64{"m5.large": 0.096, "m5.xlarge": 0.192, "r5.12xlarge": 3.024}
+
+	t.Run("hit", func(t *testing.T) {
+		cachePath, cleanup := tempCachePath(t)
+		defer cleanup()
+
+		p, err := NewAWSProvider(s.options(cachePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.InstanceTypes(key); err != nil {
+			t.Fatal(err)
+		}
+		fetched := atomic.LoadInt32(&s.requests)
+
+		// a new provider loads the prices from the cache file
+		p, err = NewAWSProvider(s.options(cachePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		types, err := p.InstanceTypes(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPrices(t, types, expected)
+		if requests := atomic.LoadInt32(&s.requests); requests != fetched {
+			t.Errorf("expected cached prices to be used, got %d more requests", requests-fetched)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		cachePath, cleanup := tempCachePath(t)
+		defer cleanup()
+		writeCache(t, cachePath, key, time.Now().Add(-48*time.Hour), []*InstanceType{{Name: "m5.large", Hourly: 1}})
+
+		p, err := NewAWSProvider(s.options(cachePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := atomic.LoadInt32(&s.requests)
+		types, err := p.InstanceTypes(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPrices(t, types, expected)
+		if atomic.LoadInt32(&s.requests) == before {
+			t.Error("expected expired prices to be fetched again")
+		}
+
+		cache, err := NewCache(cachePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cached, updated, ok := cache.Get(key)
+		if !ok || time.Since(updated) > time.Hour {
+			t.Fatalf("expected the cache file to be updated, got %s", updated)
+		}
+		assertPrices(t, cached, expected)
+	})
+
+	t.Run("stale prices when fetching fails", func(t *testing.T) {
+		cachePath, cleanup := tempCachePath(t)
+		defer cleanup()
+		writeCache(t, cachePath, key, time.Now().Add(-48*time.Hour), []*InstanceType{{Name: "m5.large", Hourly: 1}})
+
+		atomic.StoreInt32(&s.failing, 1)
+		defer atomic.StoreInt32(&s.failing, 0)
+
+		p, err := NewAWSProvider(s.options(cachePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		types, err := p.InstanceTypes(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPrices(t, types, map[string]float64{"m5.large": 1})
+
+		// without cached prices the failure is returned
+		_, err = p.InstanceTypes(Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: Reserved})
+		if err == nil {
+			t.Error("expected an error without cached prices")
+		}
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		cachePath, cleanup := tempCachePath(t)
+		defer cleanup()
+		writeCache(t, cachePath, key, time.Now().Add(-48*time.Hour), []*InstanceType{{Name: "m5.large", Hourly: 1}})
+
+		options := s.options(cachePath)
+		options.Offline = true
+		p, err := NewAWSProvider(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := atomic.LoadInt32(&s.requests)
+
+		// expired prices are used when offline
+		types, err := p.InstanceTypes(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPrices(t, types, map[string]float64{"m5.large": 1})
+
+		_, err = p.InstanceTypes(Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: Spot})
+		if err == nil {
+			t.Error("expected an error without cached prices")
+		}
+		if requests := atomic.LoadInt32(&s.requests); requests != before {
+			t.Errorf("expected no requests when offline, got %d", requests-before)
+		}
+	})
+
+	t.Run("other version", func(t *testing.T) {
+		cachePath, cleanup := tempCachePath(t)
+		defer cleanup()
+		data := []byte(`{"version":0,"entries":{"us-east-1/linux/on-demand":{"instanceTypes":[{"name":"m5.large"}]}}}`)
+		if err := ioutil.WriteFile(cachePath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cache, err := NewCache(cachePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := cache.Get(key); ok {
+			t.Error("expected cache files of other versions to be ignored")
+		}
+	})
+}
+
+// writeCache writes a cache file holding the instance types of the key, fetched
+// at the time
+func writeCache(t *testing.T, path string, key Key, updated time.Time, types []*InstanceType) {
+	t.Helper()
+	data, err := json.Marshal(&cacheFile{
+		Version: cacheVersion,
+		Entries: map[string]*cacheEntry{
+			key.String(): {Updated: updated, InstanceTypes: types},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
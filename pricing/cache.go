@@ -0,0 +1,116 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// cacheVersion is the version of the cache file format. Files of other versions
+// are ignored.
+const cacheVersion = 1
+
+// cacheEntry holds the instance types of a single key along with the time they
+// were fetched
+type cacheEntry struct {
+	Updated       time.Time       `json:"updated"`
+	InstanceTypes []*InstanceType `json:"instanceTypes"`
+}
+
+type cacheFile struct {
+	Version int                    `json:"version"`
+	Entries map[string]*cacheEntry `json:"entries"`
+}
+
+// Cache persists fetched prices to a local json file, keyed by Key.String(), so
+// that prices remain available when the pricing API cannot be reached.
+type Cache struct {
+	path    string
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// NewCache loads the cache file at the path. A missing file results in an empty
+// cache.
+func NewCache(path string) (*Cache, error) {
+	c := &Cache{
+		path:    path,
+		entries: make(map[string]*cacheEntry),
+	}
+
+	exists, err := util.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return c, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed parsing pricing cache %s: %s", path, err)
+	}
+	if file.Version != cacheVersion {
+		return c, nil
+	}
+	if file.Entries != nil {
+		c.entries = file.Entries
+	}
+	return c, nil
+}
+
+// Get returns the cached instance types of the key and the time they were
+// fetched. ok is false if the key is not cached.
+func (c *Cache) Get(key Key) (types []*InstanceType, updated time.Time, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key.String()]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return entry.InstanceTypes, entry.Updated, true
+}
+
+// Set caches the instance types of the key and writes the cache file
+func (c *Cache) Set(key Key, types []*InstanceType) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries[key.String()] = &cacheEntry{
+		Updated:       time.Now().UTC(),
+		InstanceTypes: types,
+	}
+
+	data, err := json.Marshal(&cacheFile{Version: cacheVersion, Entries: c.entries})
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a failed write doesn't corrupt the cache
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
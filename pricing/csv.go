@@ -0,0 +1,157 @@
+package pricing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"k8s.io/klog"
+)
+
+const (
+	apiNameColumn = "API Name"
+	vCPUsColumn   = "vCPUs"
+	memoryColumn  = "Memory"
+)
+
+// CSVProvider prices instance types from an instances.csv formatted file, which
+// holds one row per instance type and a price column per operating system and
+// purchase option, e.g. "Linux On Demand cost" or "Windows Reserved cost". The
+// file holds the prices of a single region.
+type CSVProvider struct {
+	path    string
+	region  string
+	columns map[string]int
+	records [][]string
+}
+
+// NewCSVProvider loads the instances csv at the path. When region is not empty,
+// only prices for that region are served.
+func NewCSVProvider(path string, region string) (*CSVProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no instances csv path configured")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p, err := readCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %s: %s", path, err)
+	}
+	p.path = path
+	p.region = region
+	return p, nil
+}
+
+func readCSV(r io.Reader) (*CSVProvider, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("instance types file is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{apiNameColumn, vCPUsColumn, memoryColumn} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("instance types file is missing column %q", name)
+		}
+	}
+
+	return &CSVProvider{
+		columns: columns,
+		records: records[1:],
+	}, nil
+}
+
+// CSVColumn returns the price column of the operating system and purchase
+// option. Spot prices are not part of the file.
+func CSVColumn(os string, purchaseOption string) (string, error) {
+	var prefix string
+	switch os {
+	case Linux:
+		prefix = "Linux"
+	case Windows:
+		prefix = "Windows"
+	case RHEL:
+		prefix = "RHEL"
+	case SUSE:
+		prefix = "SLES"
+	default:
+		return "", fmt.Errorf("unknown operating system %q", os)
+	}
+
+	switch purchaseOption {
+	case OnDemand:
+		return prefix + " On Demand cost", nil
+	case Reserved:
+		return prefix + " Reserved cost", nil
+	default:
+		return "", fmt.Errorf("purchase option %q is not supported by the csv provider", purchaseOption)
+	}
+}
+
+// InstanceTypes returns the instance types priced by the column of the os and
+// purchase option of the key. Rows which are missing a valid vCPUs, memory or
+// price value, e.g. "unavailable", are skipped with a warning.
+func (p *CSVProvider) InstanceTypes(key Key) ([]*InstanceType, error) {
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	if p.region != "" && key.Region != "" && key.Region != p.region {
+		return nil, fmt.Errorf("%s holds prices for region %s, not %s", p.path, p.region, key.Region)
+	}
+
+	column, err := CSVColumn(key.OperatingSystem, key.PurchaseOption)
+	if err != nil {
+		return nil, err
+	}
+	costIdx, ok := p.columns[column]
+	if !ok {
+		return nil, fmt.Errorf("instance types file is missing column %q", column)
+	}
+
+	var types []*InstanceType
+	skipped := 0
+	for _, record := range p.records {
+		vcpus, err := strconv.ParseFloat(record[p.columns[vCPUsColumn]], 64)
+		if err != nil {
+			skipped++
+			continue
+		}
+		memory, err := strconv.ParseFloat(record[p.columns[memoryColumn]], 64)
+		if err != nil {
+			skipped++
+			continue
+		}
+		hourly, err := strconv.ParseFloat(record[costIdx], 64)
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		types = append(types, &InstanceType{
+			Name:   record[p.columns[apiNameColumn]],
+			VCPUs:  vcpus,
+			Memory: memory,
+			Hourly: hourly,
+		})
+	}
+
+	if skipped > 0 {
+		klog.Warningf("Skipped %d of %d instance types without a valid vCPUs, Memory or %s value", skipped, len(p.records), column)
+	}
+
+	sortByName(types)
+	return types, nil
+}
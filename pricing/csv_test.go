@@ -0,0 +1,44 @@
+package pricing
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVProvider(t *testing.T) {
+	p, err := NewCSVProvider(filepath.Join("testdata", "instances.csv"), "us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rows holding unavailable vCPUs or prices are skipped
+	types, err := p.InstanceTypes(Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: OnDemand})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPrices(t, types, map[string]float64{
+		"m5dn.xlarge":  0.272,
+		"r5ad.xlarge":  0.262,
+		"r5n.12xlarge": 3.576,
+	})
+	if it := Index(types)["r5n.12xlarge"]; it.VCPUs != 48 || it.Memory != 384 {
+		t.Errorf("expected r5n.12xlarge to have 48 vCPUs and 384 GiB, got %+v", it)
+	}
+
+	types, err = p.InstanceTypes(Key{Region: "us-east-1", OperatingSystem: SUSE, PurchaseOption: Reserved})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPrices(t, types, map[string]float64{"r5ad.xlarge": 0.215})
+
+	// spot prices and other regions aren't served
+	if _, err := p.InstanceTypes(Key{Region: "us-east-1", OperatingSystem: Linux, PurchaseOption: Spot}); err == nil {
+		t.Error("expected an error for spot prices")
+	}
+	if _, err := p.InstanceTypes(Key{Region: "eu-west-1", OperatingSystem: Linux, PurchaseOption: OnDemand}); err == nil {
+		t.Error("expected an error for another region")
+	}
+	if _, err := p.InstanceTypes(Key{Region: "us-east-1", OperatingSystem: Windows, PurchaseOption: OnDemand}); err == nil {
+		t.Error("expected an error for a missing price column")
+	}
+}
@@ -0,0 +1,101 @@
+package pricing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mikeskali/PerfectScalePoc/env"
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// Operating systems supported by the providers. The values match the
+// kubernetes.io/os node label for linux and windows.
+const (
+	Linux   = "linux"
+	Windows = "windows"
+	RHEL    = "rhel"
+	SUSE    = "suse"
+)
+
+// Purchase options supported by the providers
+const (
+	OnDemand = "on-demand"
+	Reserved = "reserved"
+	Spot     = "spot"
+)
+
+// OperatingSystems returns the supported operating systems
+func OperatingSystems() []string {
+	return []string{Linux, Windows, RHEL, SUSE}
+}
+
+// PurchaseOptions returns the supported purchase options
+func PurchaseOptions() []string {
+	return []string{OnDemand, Reserved, Spot}
+}
+
+// InstanceType is a priced instance type. Memory is in GiB and Hourly is the
+// hourly cost in USD, with upfront fees spread over the hours of the term.
+type InstanceType struct {
+	Name   string  `json:"name"`
+	VCPUs  float64 `json:"vcpus"`
+	Memory float64 `json:"memory"`
+	Hourly float64 `json:"hourly"`
+}
+
+// Key selects the prices returned by a provider
+type Key struct {
+	Region          string `json:"region"`
+	OperatingSystem string `json:"operatingSystem"`
+	PurchaseOption  string `json:"purchaseOption"`
+}
+
+// String returns the key as region/os/purchase option, e.g. us-east-1/linux/on-demand
+func (k Key) String() string {
+	return k.Region + "/" + k.OperatingSystem + "/" + k.PurchaseOption
+}
+
+// Validate returns an error if the operating system or purchase option of the key
+// is not supported.
+func (k Key) Validate() error {
+	if !util.Contains(OperatingSystems(), k.OperatingSystem) {
+		return fmt.Errorf("unknown operating system %q, must be one of: %s", k.OperatingSystem, strings.Join(OperatingSystems(), ", "))
+	}
+	if !util.Contains(PurchaseOptions(), k.PurchaseOption) {
+		return fmt.Errorf("unknown purchase option %q, must be one of: %s", k.PurchaseOption, strings.Join(PurchaseOptions(), ", "))
+	}
+	return nil
+}
+
+// Provider prices instance types
+type Provider interface {
+	// InstanceTypes returns the instance types offered in the region of the key,
+	// priced for its operating system and purchase option and sorted by name.
+	InstanceTypes(key Key) ([]*InstanceType, error)
+}
+
+// NewProvider returns the CSV provider reading CSV_PATH, restricted to CSV_REGION,
+// when USE_CSV_PROVIDER is true, and the AWS provider with the default options
+// otherwise.
+func NewProvider() (Provider, error) {
+	if env.IsUseCSVProvider() {
+		return NewCSVProvider(env.GetCSVPath(), env.GetCSVRegion())
+	}
+	return NewAWSProvider(DefaultAWSOptions())
+}
+
+// Index maps the instance types by name
+func Index(types []*InstanceType) map[string]*InstanceType {
+	index := make(map[string]*InstanceType, len(types))
+	for _, t := range types {
+		index[t.Name] = t
+	}
+	return index
+}
+
+func sortByName(types []*InstanceType) {
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Name < types[j].Name
+	})
+}
@@ -0,0 +1,55 @@
+package rightsizing
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// WriteRecommendations writes a recommendations.csv formatted list of the
+// recommendations, one row per container. CPU is written in milli cores and
+// memory in bytes, with unset limits left empty.
+func WriteRecommendations(w io.Writer, recommendations []*Recommendation) error {
+	records := [][]string{
+		{
+			"namespace", "workload_kind", "workload_name", "container", "num_pods",
+			"usage_cpu_milli_core", "usage_mem_byte",
+			"req_cpu_milli_core", "recommended_req_cpu_milli_core",
+			"req_mem_byte", "recommended_req_mem_byte",
+			"limit_cpu_milli_core", "recommended_limit_cpu_milli_core",
+			"limit_mem_byte", "recommended_limit_mem_byte",
+			"hourly_savings", "monthly_savings",
+		},
+	}
+
+	for _, r := range recommendations {
+		records = append(records, []string{
+			r.Workload.Namespace,
+			r.Workload.Kind,
+			r.Workload.Name,
+			r.Container,
+			strconv.Itoa(r.Pods),
+			strconv.FormatInt(r.Usage.CPU, 10),
+			strconv.FormatInt(r.Usage.Memory, 10),
+			strconv.FormatInt(r.CurrentRequests.CPU, 10),
+			strconv.FormatInt(r.RecommendedRequests.CPU, 10),
+			strconv.FormatInt(r.CurrentRequests.Memory, 10),
+			strconv.FormatInt(r.RecommendedRequests.Memory, 10),
+			formatOptional(r.CurrentLimits.CPU),
+			formatOptional(r.RecommendedLimits.CPU),
+			formatOptional(r.CurrentLimits.Memory),
+			formatOptional(r.RecommendedLimits.Memory),
+			strconv.FormatFloat(r.HourlySavings, 'f', 4, 64),
+			strconv.FormatFloat(r.MonthlySavings, 'f', 4, 64),
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+func formatOptional(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
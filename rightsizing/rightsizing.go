@@ -0,0 +1,245 @@
+package rightsizing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+const bytesPerMiB = 1024 * 1024
+
+// Options configures how recommendations are computed from the usage
+type Options struct {
+	// CPUPercentile and MemoryPercentile select the usage percentile the
+	// requests are based on: 50, 95, 99 or 100 for the max
+	CPUPercentile    float64
+	MemoryPercentile float64
+
+	// CPUHeadroom and MemoryHeadroom are the fractions added on top of the usage
+	// percentile, e.g. 0.15 for 15%
+	CPUHeadroom    float64
+	MemoryHeadroom float64
+
+	// MinCPU, in milli cores, and MinMemory, in bytes, are the lowest recommended
+	// requests
+	MinCPU    int64
+	MinMemory int64
+}
+
+// DefaultOptions recommends the p95 CPU and p99 memory usage plus 15% headroom,
+// with at least 10m CPU and 32Mi memory
+func DefaultOptions() *Options {
+	return &Options{
+		CPUPercentile:    95,
+		MemoryPercentile: 99,
+		CPUHeadroom:      0.15,
+		MemoryHeadroom:   0.15,
+		MinCPU:           10,
+		MinMemory:        32 * bytesPerMiB,
+	}
+}
+
+// Percentiles are the usage percentiles the requests can be based on, 100 being
+// the max
+func Percentiles() []float64 {
+	return []float64{50, 95, 99, 100}
+}
+
+// Validate returns an error if the CPU or memory percentile isn't one of the
+// Percentiles, as the usage distributions only hold those
+func (o *Options) Validate() error {
+	if !validPercentile(o.CPUPercentile) {
+		return fmt.Errorf("unsupported CPU percentile %v, must be one of 50, 95, 99 or 100", o.CPUPercentile)
+	}
+	if !validPercentile(o.MemoryPercentile) {
+		return fmt.Errorf("unsupported memory percentile %v, must be one of 50, 95, 99 or 100", o.MemoryPercentile)
+	}
+	return nil
+}
+
+func validPercentile(p float64) bool {
+	for _, percentile := range Percentiles() {
+		if p == percentile {
+			return true
+		}
+	}
+	return false
+}
+
+// Resources holds CPU, in milli cores, and memory, in bytes. Zero means unset.
+type Resources struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+// Recommendation is the recommended requests and limits of a container of a
+// workload
+type Recommendation struct {
+	Workload  workload.Workload `json:"workload"`
+	Container string            `json:"container"`
+	Pods      int               `json:"pods"`
+
+	// Usage is the usage percentile of the container, the highest over the pods
+	// of the workload
+	Usage Resources `json:"usage"`
+
+	CurrentRequests     Resources `json:"currentRequests"`
+	CurrentLimits       Resources `json:"currentLimits"`
+	RecommendedRequests Resources `json:"recommendedRequests"`
+	RecommendedLimits   Resources `json:"recommendedLimits"`
+
+	// HourlySavings and MonthlySavings are the savings of the recommended
+	// requests over all the pods of the workload, priced by the unit prices of
+	// their nodes. They are negative when the recommendation is an increase.
+	HourlySavings  float64 `json:"hourlySavings"`
+	MonthlySavings float64 `json:"monthlySavings"`
+}
+
+// containerKey identifies a container of a workload
+type containerKey struct {
+	workload  workload.Workload
+	container string
+}
+
+// Recommend computes a recommendation for every container of every workload with
+// usage. Like a VPA recommender, the usage percentile of the container across
+// all pods of the workload, plus the headroom, becomes the recommended request,
+// and limits are scaled to keep their current ratio to the request. Containers
+// without any usage are skipped. prices maps node names to their unit prices;
+// pods on nodes without prices don't contribute to the savings.
+func Recommend(pods []*v1.Pod, resolver *workload.Resolver, usage *metrics.Usage, prices map[string]*cost.UnitPrice, options *Options) []*Recommendation {
+	if options == nil {
+		options = DefaultOptions()
+	}
+
+	type podContainer struct {
+		node     string
+		requests Resources
+	}
+
+	recommendations := make(map[containerKey]*Recommendation)
+	instances := make(map[containerKey][]podContainer)
+	observedCPU := make(map[containerKey]bool)
+	observedMemory := make(map[containerKey]bool)
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		w := resolver.Resolve(pod)
+		for _, container := range pod.Spec.Containers {
+			cu := usage.Container(pod.Namespace, pod.Name, container.Name)
+			if cu == nil || (cu.CPU == nil && cu.Memory == nil) {
+				continue
+			}
+
+			key := containerKey{workload: w, container: container.Name}
+			r, ok := recommendations[key]
+			if !ok {
+				r = &Recommendation{Workload: w, Container: container.Name}
+				recommendations[key] = r
+			}
+			r.Pods++
+
+			requests := Resources{
+				CPU:    container.Resources.Requests.Cpu().MilliValue(),
+				Memory: container.Resources.Requests.Memory().Value(),
+			}
+			limits := Resources{
+				CPU:    container.Resources.Limits.Cpu().MilliValue(),
+				Memory: container.Resources.Limits.Memory().Value(),
+			}
+			r.CurrentRequests = maxResources(r.CurrentRequests, requests)
+			r.CurrentLimits = maxResources(r.CurrentLimits, limits)
+
+			if cu.CPU != nil {
+				observedCPU[key] = true
+				r.Usage.CPU = maxInt64(r.Usage.CPU, int64(math.Ceil(cu.CPU.Percentile(options.CPUPercentile)*1000)))
+			}
+			if cu.Memory != nil {
+				observedMemory[key] = true
+				r.Usage.Memory = maxInt64(r.Usage.Memory, int64(math.Ceil(cu.Memory.Percentile(options.MemoryPercentile))))
+			}
+
+			instances[key] = append(instances[key], podContainer{node: pod.Spec.NodeName, requests: requests})
+		}
+	}
+
+	result := make([]*Recommendation, 0, len(recommendations))
+	for key, r := range recommendations {
+		// a resource without any usage samples keeps its current request
+		r.RecommendedRequests = r.CurrentRequests
+		if observedCPU[key] {
+			r.RecommendedRequests.CPU = maxInt64(options.MinCPU, int64(math.Ceil(float64(r.Usage.CPU)*(1+options.CPUHeadroom))))
+		}
+		if observedMemory[key] {
+			r.RecommendedRequests.Memory = maxInt64(options.MinMemory, roundUp(int64(math.Ceil(float64(r.Usage.Memory)*(1+options.MemoryHeadroom))), bytesPerMiB))
+		}
+		r.RecommendedLimits = Resources{
+			CPU:    scaleLimit(r.CurrentLimits.CPU, r.CurrentRequests.CPU, r.RecommendedRequests.CPU),
+			Memory: roundUp(scaleLimit(r.CurrentLimits.Memory, r.CurrentRequests.Memory, r.RecommendedRequests.Memory), bytesPerMiB),
+		}
+
+		for _, pc := range instances[key] {
+			price, ok := prices[pc.node]
+			if !ok {
+				continue
+			}
+			r.HourlySavings += float64(pc.requests.CPU-r.RecommendedRequests.CPU) / 1000 * price.CPU
+			r.HourlySavings += float64(pc.requests.Memory-r.RecommendedRequests.Memory) * price.Memory
+		}
+		r.MonthlySavings = r.HourlySavings * util.HoursPerMonth
+
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Workload != b.Workload {
+			return a.Workload.String() < b.Workload.String()
+		}
+		return a.Container < b.Container
+	})
+	return result
+}
+
+// scaleLimit scales the limit by the ratio of the recommended to the current
+// request. A limit without a request is kept as is, and an unset limit stays
+// unset.
+func scaleLimit(limit int64, request int64, recommended int64) int64 {
+	if limit == 0 {
+		return 0
+	}
+	if request == 0 {
+		return maxInt64(limit, recommended)
+	}
+	return int64(math.Ceil(float64(limit) * float64(recommended) / float64(request)))
+}
+
+func maxResources(a Resources, b Resources) Resources {
+	return Resources{
+		CPU:    maxInt64(a.CPU, b.CPU),
+		Memory: maxInt64(a.Memory, b.Memory),
+	}
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func roundUp(value int64, unit int64) int64 {
+	if value%unit == 0 {
+		return value
+	}
+	return (value/unit + 1) * unit
+}
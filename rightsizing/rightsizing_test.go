@@ -0,0 +1,238 @@
+package rightsizing
+
+import (
+	"math"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+const bytesPerGiB = 1024 * bytesPerMiB
+
+// withLimits sets the limits of the container of the pod
+func withLimits(pod *v1.Pod, cpu string, memory string) *v1.Pod {
+	pod.Spec.Containers[0].Resources.Limits = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+	return pod
+}
+
+// distribution returns a distribution with the value at every percentile but p50,
+// which is half of it
+func distribution(value float64) *metrics.Distribution {
+	return &metrics.Distribution{Samples: 100, P50: value / 2, P95: value, P99: value, Max: value}
+}
+
+// usageOf returns the usage of the main container of the pods, keyed by pod name,
+// in cores and MiB. Negative values have no samples.
+func usageOf(namespace string, usage map[string][2]float64) *metrics.Usage {
+	u := &metrics.Usage{Pods: make(map[string]*metrics.PodUsage)}
+	for pod, values := range usage {
+		cu := &metrics.ContainerUsage{Namespace: namespace, Pod: pod, Container: "main"}
+		if values[0] >= 0 {
+			cu.CPU = distribution(values[0])
+		}
+		if values[1] >= 0 {
+			cu.Memory = distribution(values[1] * bytesPerMiB)
+		}
+		u.Pods[namespace+"/"+pod] = &metrics.PodUsage{
+			Namespace:  namespace,
+			Pod:        pod,
+			Containers: map[string]*metrics.ContainerUsage{"main": cu},
+		}
+	}
+	return u
+}
+
+func TestRecommend(t *testing.T) {
+	db := clustercachetest.StatefulSet("default", "db", 2)
+	dbOwner := clustercachetest.OwnerReference("StatefulSet", db)
+	pods := []*v1.Pod{
+		// the db pods are over requested, only the pod of node-a is priced
+		withLimits(clustercachetest.Pod("default", "db-0", "node-a", "1", "1Gi", dbOwner), "2", "2Gi"),
+		withLimits(clustercachetest.Pod("default", "db-1", "node-b", "1", "1Gi", dbOwner), "2", "2Gi"),
+		// a tiny pod gets the minimum requests
+		clustercachetest.Pod("default", "tiny", "node-a", "100m", "128Mi", nil),
+		// an under requested pod costs more
+		clustercachetest.Pod("default", "busy", "node-a", "100m", "64Mi", nil),
+		// a pod with memory usage only keeps its CPU request
+		clustercachetest.Pod("default", "quiet", "node-a", "300m", "256Mi", nil),
+		// a pod without usage is skipped
+		clustercachetest.Pod("default", "new", "node-a", "100m", "64Mi", nil),
+	}
+	usage := usageOf("default", map[string][2]float64{
+		"db-0":  {0.3, 300},
+		"db-1":  {0.4, 200},
+		"tiny":  {0.001, 1},
+		"busy":  {0.5, 100},
+		"quiet": {-1, 100},
+	})
+	prices := map[string]*cost.UnitPrice{
+		"node-a": {CPU: 0.04, Memory: 0.01 / bytesPerGiB},
+	}
+
+	recommendations := Recommend(pods, workload.NewResolver(nil, nil), usage, prices, nil)
+
+	type expectation struct {
+		pods     int
+		usage    Resources
+		requests Resources
+		limits   Resources
+		hourly   float64
+	}
+	expected := map[string]expectation{
+		"default/BarePod/busy": {
+			pods:     1,
+			usage:    Resources{CPU: 500, Memory: 100 * bytesPerMiB},
+			requests: Resources{CPU: 575, Memory: 115 * bytesPerMiB},
+			hourly:   -0.475*0.04 - 51.0/1024*0.01,
+		},
+		"default/BarePod/quiet": {
+			pods:     1,
+			usage:    Resources{Memory: 100 * bytesPerMiB},
+			requests: Resources{CPU: 300, Memory: 115 * bytesPerMiB},
+			hourly:   141.0 / 1024 * 0.01,
+		},
+		"default/BarePod/tiny": {
+			pods:     1,
+			usage:    Resources{CPU: 1, Memory: bytesPerMiB},
+			requests: Resources{CPU: 10, Memory: 32 * bytesPerMiB},
+			hourly:   0.09*0.04 + 96.0/1024*0.01,
+		},
+		// the highest usage of the pods, plus the headroom rounded up to MiB, with
+		// the limits keeping their ratio to the requests
+		"default/StatefulSet/db": {
+			pods:     2,
+			usage:    Resources{CPU: 400, Memory: 300 * bytesPerMiB},
+			requests: Resources{CPU: 460, Memory: 345 * bytesPerMiB},
+			limits:   Resources{CPU: 920, Memory: 690 * bytesPerMiB},
+			hourly:   0.54*0.04 + 679.0/1024*0.01,
+		},
+	}
+
+	if len(recommendations) != len(expected) {
+		t.Fatalf("expected %d recommendations, got %d", len(expected), len(recommendations))
+	}
+	for i, r := range recommendations {
+		if i > 0 && recommendations[i-1].Workload.String() > r.Workload.String() {
+			t.Errorf("expected the recommendations sorted by workload, got %s before %s", recommendations[i-1].Workload, r.Workload)
+		}
+		e, ok := expected[r.Workload.String()]
+		if !ok {
+			t.Errorf("unexpected recommendation of %s", r.Workload)
+			continue
+		}
+		if r.Container != "main" || r.Pods != e.pods {
+			t.Errorf("%s: expected %d pods of main, got %d of %s", r.Workload, e.pods, r.Pods, r.Container)
+		}
+		if r.Usage != e.usage {
+			t.Errorf("%s: expected the usage %+v, got %+v", r.Workload, e.usage, r.Usage)
+		}
+		if r.RecommendedRequests != e.requests {
+			t.Errorf("%s: expected the requests %+v, got %+v", r.Workload, e.requests, r.RecommendedRequests)
+		}
+		if r.RecommendedLimits != e.limits {
+			t.Errorf("%s: expected the limits %+v, got %+v", r.Workload, e.limits, r.RecommendedLimits)
+		}
+		if math.Abs(r.HourlySavings-e.hourly) > 1e-9 {
+			t.Errorf("%s: expected hourly savings of %v, got %v", r.Workload, e.hourly, r.HourlySavings)
+		}
+		if r.Workload.Name == "busy" && r.MonthlySavings >= 0 {
+			t.Errorf("%s: expected negative savings for an increase, got %v", r.Workload, r.MonthlySavings)
+		}
+		if r.Workload.Name != "busy" && r.MonthlySavings <= 0 {
+			t.Errorf("%s: expected positive savings for a decrease, got %v", r.Workload, r.MonthlySavings)
+		}
+	}
+}
+
+func TestRecommendPercentiles(t *testing.T) {
+	pods := []*v1.Pod{clustercachetest.Pod("default", "web", "node-a", "1", "1Gi", nil)}
+	usage := usageOf("default", map[string][2]float64{"web": {0.4, 400}})
+	options := &Options{CPUPercentile: 50, MemoryPercentile: 100}
+
+	recommendations := Recommend(pods, workload.NewResolver(nil, nil), usage, nil, options)
+	if len(recommendations) != 1 {
+		t.Fatalf("expected 1 recommendation, got %d", len(recommendations))
+	}
+	r := recommendations[0]
+	// the p50 is half of the other percentiles
+	expected := Resources{CPU: 200, Memory: 400 * bytesPerMiB}
+	if r.RecommendedRequests != expected {
+		t.Errorf("expected the requests %+v without headroom, got %+v", expected, r.RecommendedRequests)
+	}
+	if r.HourlySavings != 0 {
+		t.Errorf("expected no savings without prices, got %v", r.HourlySavings)
+	}
+}
+
+func TestScaleLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int64
+		request     int64
+		recommended int64
+		expected    int64
+	}{
+		{name: "unset limit", limit: 0, request: 100, recommended: 50, expected: 0},
+		{name: "halved", limit: 200, request: 100, recommended: 50, expected: 100},
+		{name: "doubled", limit: 200, request: 100, recommended: 200, expected: 400},
+		{name: "rounded up", limit: 100, request: 30, recommended: 10, expected: 34},
+		{name: "limit without request", limit: 100, request: 0, recommended: 50, expected: 100},
+		{name: "limit below the recommendation without request", limit: 100, request: 0, recommended: 150, expected: 150},
+	}
+
+	for _, test := range tests {
+		if actual := scaleLimit(test.limit, test.request, test.recommended); actual != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestRoundUp(t *testing.T) {
+	tests := []struct {
+		value    int64
+		expected int64
+	}{
+		{value: 0, expected: 0},
+		{value: 1, expected: bytesPerMiB},
+		{value: bytesPerMiB, expected: bytesPerMiB},
+		{value: bytesPerMiB + 1, expected: 2 * bytesPerMiB},
+	}
+
+	for _, test := range tests {
+		if actual := roundUp(test.value, bytesPerMiB); actual != test.expected {
+			t.Errorf("%d: expected %d, got %d", test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, p := range Percentiles() {
+		options := DefaultOptions()
+		options.CPUPercentile, options.MemoryPercentile = p, p
+		if err := options.Validate(); err != nil {
+			t.Errorf("%v: %s", p, err)
+		}
+	}
+
+	for _, p := range []float64{0, 90, 99.9, 101} {
+		options := DefaultOptions()
+		options.CPUPercentile = p
+		if err := options.Validate(); err == nil {
+			t.Errorf("expected an error for the CPU percentile %v", p)
+		}
+		options = DefaultOptions()
+		options.MemoryPercentile = p
+		if err := options.Validate(); err == nil {
+			t.Errorf("expected an error for the memory percentile %v", p)
+		}
+	}
+}
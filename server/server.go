@@ -0,0 +1,403 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+	"github.com/mikeskali/PerfectScalePoc/cost"
+	"github.com/mikeskali/PerfectScalePoc/inventory"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+	"github.com/mikeskali/PerfectScalePoc/nodegroup"
+	"github.com/mikeskali/PerfectScalePoc/pricing"
+	"github.com/mikeskali/PerfectScalePoc/rightsizing"
+	"github.com/mikeskali/PerfectScalePoc/util"
+	"github.com/mikeskali/PerfectScalePoc/workload"
+)
+
+// Server serves the cluster inventory, costs and rightsizing recommendations
+// over HTTP. Every request is computed from the current state of the cluster
+// cache, so the responses follow the cluster as the watchers update it.
+//
+// Responses are JSON, or CSV when the Accept header asks for text/csv. The
+// results can be filtered with the query parameters:
+//
+//	namespace   only the pods, workloads, costs and recommendations of the namespace
+//	nodegroup   only the nodes of the node group and the pods running on them
+//	window      the Prometheus style duration of the usage history, e.g. 7d
+//	offset      how far back from now the usage history ends, e.g. 1h
+//	aggregate   namespace to sum the costs per namespace rather than per workload
+//
+// The usage of every window and offset is collected at most once per usageTTL.
+type Server struct {
+	cache        clustercache.ClusterCache
+	provider     pricing.Provider
+	querier      metrics.Querier
+	usageOptions *metrics.Options
+	mux          *http.ServeMux
+
+	usageTTL   time.Duration
+	usageLock  sync.Mutex
+	usageCache map[usageKey]*cachedUsage
+}
+
+// usageTTL is how long the usage of a window and offset is served before it is
+// collected again
+const usageTTL = time.Minute
+
+// usageKey identifies the usage collected over a window and offset
+type usageKey struct {
+	window string
+	offset string
+}
+
+// cachedUsage is the usage of a window and offset and the time it was collected
+type cachedUsage struct {
+	usage *metrics.Usage
+	at    time.Time
+}
+
+// NewServer creates a server of the cache. Costs are priced by the provider and
+// usage is queried with the querier over the window of the usage options; the
+// costs or the usage, and the recommendations which need both, are unavailable
+// when the provider or the querier is nil.
+func NewServer(cache clustercache.ClusterCache, provider pricing.Provider, querier metrics.Querier, usageOptions *metrics.Options) *Server {
+	if usageOptions == nil {
+		usageOptions = metrics.DefaultOptions()
+	}
+
+	s := &Server{
+		cache:        cache,
+		provider:     provider,
+		querier:      querier,
+		usageOptions: usageOptions,
+		mux:          http.NewServeMux(),
+		usageTTL:     usageTTL,
+		usageCache:   make(map[usageKey]*cachedUsage),
+	}
+	s.mux.HandleFunc("/nodes", s.handle(s.nodes))
+	s.mux.HandleFunc("/nodegroups", s.handle(s.nodeGroups))
+	s.mux.HandleFunc("/pods", s.handle(s.pods))
+	s.mux.HandleFunc("/workloads", s.handle(s.workloads))
+	s.mux.HandleFunc("/costs", s.handle(s.costs))
+	s.mux.HandleFunc("/recommendations", s.handle(s.recommendations))
+	return s
+}
+
+// Handle registers an additional handler for the pattern, e.g. a metrics
+// exporter
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// response is the result of an endpoint, written as JSON or with writeCSV
+type response struct {
+	value    interface{}
+	writeCSV func(io.Writer) error
+}
+
+// httpError is an error returned to the client with the status code
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+type handlerFunc func(f *filters) (*response, error)
+
+// handle parses the filters of the request, runs the handler and writes its
+// response in the format selected by the Accept header
+func (s *Server) handle(handler handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+			return
+		}
+
+		f, err := s.parseFilters(r)
+		var resp *response
+		if err == nil {
+			resp, err = handler(f)
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			if he, ok := err.(*httpError); ok {
+				status = he.status
+			}
+			klog.V(3).Infof("%s %s failed: %s", r.Method, r.URL, err)
+			writeError(w, status, err.Error())
+			return
+		}
+
+		var buf bytes.Buffer
+		contentType := "application/json"
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			contentType = "text/csv"
+			err = resp.writeCSV(&buf)
+		} else {
+			err = json.NewEncoder(&buf).Encode(resp.value)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write(buf.Bytes())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// filters are the query parameters narrowing down the results
+type filters struct {
+	namespace string
+	nodeGroup string
+	window    string
+	offset    string
+	aggregate string
+}
+
+// parseFilters returns the filters of the request, or a bad request error when
+// the window isn't a positive duration or the offset a duration
+func (s *Server) parseFilters(r *http.Request) (*filters, error) {
+	qp := util.NewQueryParams(r.URL.Query())
+	f := &filters{
+		namespace: qp.Get("namespace", ""),
+		nodeGroup: qp.Get("nodegroup", ""),
+		window:    qp.Get("window", s.usageOptions.Window),
+		offset:    qp.Get("offset", s.usageOptions.Offset),
+		aggregate: qp.Get("aggregate", ""),
+	}
+
+	if window, err := parseDuration(f.window); err != nil || *window <= 0 {
+		return nil, &httpError{status: http.StatusBadRequest, message: fmt.Sprintf("invalid window %q, expected a positive duration such as 7d", f.window)}
+	}
+	if f.offset != "" {
+		if offset, err := parseDuration(f.offset); err != nil || *offset < 0 {
+			return nil, &httpError{status: http.StatusBadRequest, message: fmt.Sprintf("invalid offset %q, expected a duration such as 1h", f.offset)}
+		}
+	}
+	return f, nil
+}
+
+// parseDuration parses the Prometheus style duration, failing on an empty one
+func parseDuration(duration string) (*time.Duration, error) {
+	if duration == "" {
+		return nil, fmt.Errorf("empty duration")
+	}
+	return util.ParseDuration(duration)
+}
+
+// cluster is the state of the cluster cache narrowed down by the node group
+// filter. The pods are all the pods on the nodes, regardless of the namespace
+// filter, as the cost of a node is split between all of its pods.
+type cluster struct {
+	groups     []*nodegroup.NodeGroup
+	node2group map[string]string
+	nodes      []*v1.Node
+	pods       []*v1.Pod
+	resolver   *workload.Resolver
+}
+
+func (s *Server) cluster(f *filters) *cluster {
+	groups, node2group := nodegroup.Group(s.cache.GetAllNodes())
+
+	c := &cluster{
+		groups:     []*nodegroup.NodeGroup{},
+		node2group: node2group,
+		resolver:   workload.NewResolverFromCache(s.cache),
+	}
+	for _, group := range groups {
+		if f.nodeGroup != "" && group.ID != f.nodeGroup {
+			continue
+		}
+		c.groups = append(c.groups, group)
+		c.nodes = append(c.nodes, group.Nodes...)
+	}
+	if f.nodeGroup == "" {
+		c.pods = s.cache.GetAllPods()
+		return c
+	}
+	for _, node := range c.nodes {
+		c.pods = append(c.pods, s.cache.GetPodsOnNode(node.Name)...)
+	}
+	return c
+}
+
+// namespacePods returns the pods of the namespace filter
+func (c *cluster) namespacePods(f *filters) []*v1.Pod {
+	if f.namespace == "" {
+		return c.pods
+	}
+	var pods []*v1.Pod
+	for _, pod := range c.pods {
+		if pod.Namespace == f.namespace {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// usage collects the usage over the window of the filters, or returns the usage
+// collected over the same window and offset within the usage TTL. It returns nil
+// when there is no querier. The usage is collected outside of the lock, so the
+// requests of other windows aren't held up.
+func (s *Server) usage(f *filters) (*metrics.Usage, error) {
+	if s.querier == nil {
+		return nil, nil
+	}
+
+	key := usageKey{window: f.window, offset: f.offset}
+	s.usageLock.Lock()
+	cached, ok := s.usageCache[key]
+	s.usageLock.Unlock()
+	if ok && time.Since(cached.at) < s.usageTTL {
+		return cached.usage, nil
+	}
+
+	options := *s.usageOptions
+	options.Window = f.window
+	options.Offset = f.offset
+	usage, err := metrics.NewCollector(s.querier, &options).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("failed collecting usage: %s", err)
+	}
+
+	s.usageLock.Lock()
+	defer s.usageLock.Unlock()
+	now := time.Now()
+	for k, c := range s.usageCache {
+		if now.Sub(c.at) >= s.usageTTL {
+			delete(s.usageCache, k)
+		}
+	}
+	s.usageCache[key] = &cachedUsage{usage: usage, at: now}
+	return usage, nil
+}
+
+func (s *Server) nodeGroups(f *filters) (*response, error) {
+	groups := s.cluster(f).groups
+	return &response{
+		value: groups,
+		writeCSV: func(w io.Writer) error {
+			return inventory.WriteNodeGroups(w, groups)
+		},
+	}, nil
+}
+
+func (s *Server) nodes(f *filters) (*response, error) {
+	nodes := inventory.Nodes(s.cluster(f).groups)
+	return &response{
+		value: nodes,
+		writeCSV: func(w io.Writer) error {
+			return inventory.WriteNodes(w, nodes)
+		},
+	}, nil
+}
+
+func (s *Server) pods(f *filters) (*response, error) {
+	usage, err := s.usage(f)
+	if err != nil {
+		return nil, err
+	}
+
+	c := s.cluster(f)
+	pods := inventory.Pods(c.namespacePods(f), c.node2group, c.resolver, usage)
+	return &response{
+		value: pods,
+		writeCSV: func(w io.Writer) error {
+			return inventory.WritePods(w, pods)
+		},
+	}, nil
+}
+
+func (s *Server) workloads(f *filters) (*response, error) {
+	c := s.cluster(f)
+	workloads := inventory.Workloads(inventory.Pods(c.namespacePods(f), c.node2group, c.resolver, nil))
+	return &response{
+		value: workloads,
+		writeCSV: func(w io.Writer) error {
+			return inventory.WriteWorkloads(w, workloads)
+		},
+	}, nil
+}
+
+// costs allocates the cost of the nodes to the workloads running on them. With
+// aggregate=namespace the costs are summed per namespace instead.
+func (s *Server) costs(f *filters) (*response, error) {
+	if s.provider == nil {
+		return nil, &httpError{status: http.StatusServiceUnavailable, message: "no pricing provider configured"}
+	}
+
+	c := s.cluster(f)
+	report := cost.NewReport(c.nodes, c.node2group, s.provider, cost.DefaultOptions())
+	allocated := cost.Allocate(report, c.nodes, c.pods, c.resolver, cost.DefaultAllocationOptions())
+
+	allocations := allocated.Allocations
+	if f.aggregate == "namespace" {
+		allocations = allocated.ByNamespace()
+	}
+	if f.namespace != "" {
+		filtered := []*cost.Allocation{}
+		for _, a := range allocations {
+			if a.Namespace == f.namespace {
+				filtered = append(filtered, a)
+			}
+		}
+		allocations = filtered
+	}
+
+	return &response{
+		value: allocations,
+		writeCSV: func(w io.Writer) error {
+			return cost.WriteAllocations(w, allocations)
+		},
+	}, nil
+}
+
+func (s *Server) recommendations(f *filters) (*response, error) {
+	if s.querier == nil {
+		return nil, &httpError{status: http.StatusServiceUnavailable, message: "no prometheus server configured"}
+	}
+	usage, err := s.usage(f)
+	if err != nil {
+		return nil, err
+	}
+
+	c := s.cluster(f)
+	var prices map[string]*cost.UnitPrice
+	if s.provider != nil {
+		report := cost.NewReport(c.nodes, c.node2group, s.provider, cost.DefaultOptions())
+		prices = cost.UnitPrices(report, c.nodes, cost.DefaultAllocationOptions())
+	}
+
+	recommendations := rightsizing.Recommend(c.namespacePods(f), c.resolver, usage, prices, rightsizing.DefaultOptions())
+	return &response{
+		value: recommendations,
+		writeCSV: func(w io.Writer) error {
+			return rightsizing.WriteRecommendations(w, recommendations)
+		},
+	}, nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache/clustercachetest"
+	"github.com/mikeskali/PerfectScalePoc/metrics"
+)
+
+// countingQuerier returns no series, counting the queries it receives
+type countingQuerier struct {
+	lock    sync.Mutex
+	queries int
+}
+
+func (q *countingQuerier) QueryRange(query string, start, end time.Time, step time.Duration) ([]*metrics.Series, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.queries++
+	return nil, nil
+}
+
+func (q *countingQuerier) count() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.queries
+}
+
+func get(s *Server, url string) int {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w.Code
+}
+
+func TestFilters(t *testing.T) {
+	h := clustercachetest.NewHarness()
+	defer h.Stop()
+	s := NewServer(h.Cache, nil, &countingQuerier{}, nil)
+
+	tests := []struct {
+		url      string
+		expected int
+	}{
+		{url: "/pods", expected: http.StatusOK},
+		{url: "/pods?window=1d&offset=1h", expected: http.StatusOK},
+		{url: "/pods?window=7", expected: http.StatusBadRequest},
+		{url: "/pods?window=1w", expected: http.StatusBadRequest},
+		{url: "/pods?window=0h", expected: http.StatusBadRequest},
+		{url: "/pods?window=-1d", expected: http.StatusBadRequest},
+		{url: "/pods?offset=soon", expected: http.StatusBadRequest},
+		{url: "/pods?offset=-1h", expected: http.StatusBadRequest},
+		{url: "/nodes?window=1w", expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		if code := get(s, test.url); code != test.expected {
+			t.Errorf("%s: expected status %d, got %d", test.url, test.expected, code)
+		}
+	}
+}
+
+func TestUsageCache(t *testing.T) {
+	h := clustercachetest.NewHarness()
+	defer h.Stop()
+	querier := &countingQuerier{}
+	s := NewServer(h.Cache, nil, querier, nil)
+
+	get(s, "/pods?window=1d")
+	queries := querier.count()
+	if queries == 0 {
+		t.Fatal("expected the usage to be queried")
+	}
+
+	// the usage of the same window and offset is served from the cache
+	get(s, "/pods?window=1d")
+	if count := querier.count(); count != queries {
+		t.Errorf("expected the cached usage, got %d queries after %d", count, queries)
+	}
+
+	get(s, "/pods?window=1d&offset=1h")
+	if count := querier.count(); count != 2*queries {
+		t.Errorf("expected another offset to be queried, got %d queries after %d", count, queries)
+	}
+
+	s.usageTTL = 0
+	get(s, "/pods?window=1d")
+	if count := querier.count(); count != 3*queries {
+		t.Errorf("expected the expired usage to be queried again, got %d queries after %d", count, 2*queries)
+	}
+}
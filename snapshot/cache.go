@@ -0,0 +1,374 @@
+package snapshot
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	stv1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/mikeskali/PerfectScalePoc/util"
+)
+
+// Cache is a ClusterCache serving the resources of a snapshot, so everything
+// built on the cluster cache runs offline exactly as it ran against the cluster
+// the snapshot was captured from. The resources never change: subscribers only
+// receive the resources of the snapshot as added, and update functions are never
+// called.
+type Cache struct {
+	snapshot *Snapshot
+
+	podsByNode          map[string][]*v1.Pod
+	podsByNamespace     map[string][]*v1.Pod
+	podsByOwner         map[types.UID][]*v1.Pod
+	nodesByInstanceType map[string][]*v1.Node
+}
+
+// NewCache creates a cluster cache serving the resources of the snapshot
+func NewCache(s *Snapshot) *Cache {
+	c := &Cache{
+		snapshot:            s,
+		podsByNode:          make(map[string][]*v1.Pod),
+		podsByNamespace:     make(map[string][]*v1.Pod),
+		podsByOwner:         make(map[types.UID][]*v1.Pod),
+		nodesByInstanceType: make(map[string][]*v1.Node),
+	}
+	for _, pod := range s.Pods {
+		if pod.Spec.NodeName != "" {
+			c.podsByNode[pod.Spec.NodeName] = append(c.podsByNode[pod.Spec.NodeName], pod)
+		}
+		c.podsByNamespace[pod.Namespace] = append(c.podsByNamespace[pod.Namespace], pod)
+		for _, owner := range pod.OwnerReferences {
+			c.podsByOwner[owner.UID] = append(c.podsByOwner[owner.UID], pod)
+		}
+	}
+	for _, node := range s.Nodes {
+		if instanceType, ok := util.GetInstanceType(node.Labels); ok {
+			c.nodesByInstanceType[instanceType] = append(c.nodesByInstanceType[instanceType], node)
+		}
+	}
+	return c
+}
+
+// Load reads the snapshot at path and creates a cluster cache serving it
+func Load(path string) (*Cache, error) {
+	s, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCache(s), nil
+}
+
+// Snapshot returns the snapshot served by the cache
+func (c *Cache) Snapshot() *Snapshot {
+	return c.snapshot
+}
+
+// Run does nothing, as the resources of a snapshot never change
+func (c *Cache) Run() {}
+
+// Stop does nothing, as the resources of a snapshot never change
+func (c *Cache) Stop() {}
+
+// GetClient returns nil, as there is no cluster to connect to
+func (c *Cache) GetClient() kubernetes.Interface {
+	return nil
+}
+
+func (c *Cache) GetAllNamespaces() []*v1.Namespace {
+	namespaces := make([]*v1.Namespace, 0, len(c.snapshot.Namespaces))
+	for _, ns := range c.snapshot.Namespaces {
+		namespaces = append(namespaces, ns.DeepCopy())
+	}
+	return namespaces
+}
+
+func (c *Cache) GetAllNodes() []*v1.Node {
+	nodes := make([]*v1.Node, 0, len(c.snapshot.Nodes))
+	for _, node := range c.snapshot.Nodes {
+		nodes = append(nodes, node.DeepCopy())
+	}
+	return nodes
+}
+
+func (c *Cache) GetAllPods() []*v1.Pod {
+	pods := make([]*v1.Pod, 0, len(c.snapshot.Pods))
+	for _, pod := range c.snapshot.Pods {
+		pods = append(pods, pod.DeepCopy())
+	}
+	return pods
+}
+
+func (c *Cache) GetAllServices() []*v1.Service {
+	services := make([]*v1.Service, 0, len(c.snapshot.Services))
+	for _, service := range c.snapshot.Services {
+		services = append(services, service.DeepCopy())
+	}
+	return services
+}
+
+func (c *Cache) GetAllDaemonSets() []*appsv1.DaemonSet {
+	daemonSets := make([]*appsv1.DaemonSet, 0, len(c.snapshot.DaemonSets))
+	for _, ds := range c.snapshot.DaemonSets {
+		daemonSets = append(daemonSets, ds.DeepCopy())
+	}
+	return daemonSets
+}
+
+func (c *Cache) GetAllDeployments() []*appsv1.Deployment {
+	deployments := make([]*appsv1.Deployment, 0, len(c.snapshot.Deployments))
+	for _, deployment := range c.snapshot.Deployments {
+		deployments = append(deployments, deployment.DeepCopy())
+	}
+	return deployments
+}
+
+func (c *Cache) GetAllStatefulSets() []*appsv1.StatefulSet {
+	statefulSets := make([]*appsv1.StatefulSet, 0, len(c.snapshot.StatefulSets))
+	for _, sts := range c.snapshot.StatefulSets {
+		statefulSets = append(statefulSets, sts.DeepCopy())
+	}
+	return statefulSets
+}
+
+func (c *Cache) GetAllReplicaSets() []*appsv1.ReplicaSet {
+	replicaSets := make([]*appsv1.ReplicaSet, 0, len(c.snapshot.ReplicaSets))
+	for _, rs := range c.snapshot.ReplicaSets {
+		replicaSets = append(replicaSets, rs.DeepCopy())
+	}
+	return replicaSets
+}
+
+func (c *Cache) GetAllJobs() []*batchv1.Job {
+	jobs := make([]*batchv1.Job, 0, len(c.snapshot.Jobs))
+	for _, job := range c.snapshot.Jobs {
+		jobs = append(jobs, job.DeepCopy())
+	}
+	return jobs
+}
+
+func (c *Cache) GetAllCronJobs() []*batchv1beta1.CronJob {
+	cronJobs := make([]*batchv1beta1.CronJob, 0, len(c.snapshot.CronJobs))
+	for _, cronJob := range c.snapshot.CronJobs {
+		cronJobs = append(cronJobs, cronJob.DeepCopy())
+	}
+	return cronJobs
+}
+
+func (c *Cache) GetAllPersistentVolumes() []*v1.PersistentVolume {
+	pvs := make([]*v1.PersistentVolume, 0, len(c.snapshot.PersistentVolumes))
+	for _, pv := range c.snapshot.PersistentVolumes {
+		pvs = append(pvs, pv.DeepCopy())
+	}
+	return pvs
+}
+
+func (c *Cache) GetAllPersistentVolumeClaims() []*v1.PersistentVolumeClaim {
+	pvcs := make([]*v1.PersistentVolumeClaim, 0, len(c.snapshot.PersistentVolumeClaims))
+	for _, pvc := range c.snapshot.PersistentVolumeClaims {
+		pvcs = append(pvcs, pvc.DeepCopy())
+	}
+	return pvcs
+}
+
+func (c *Cache) GetAllStorageClasses() []*stv1.StorageClass {
+	storageClasses := make([]*stv1.StorageClass, 0, len(c.snapshot.StorageClasses))
+	for _, sc := range c.snapshot.StorageClasses {
+		storageClasses = append(storageClasses, sc.DeepCopy())
+	}
+	return storageClasses
+}
+
+func (c *Cache) GetAllPodDisruptionBudgets() []*policyv1beta1.PodDisruptionBudget {
+	pdbs := make([]*policyv1beta1.PodDisruptionBudget, 0, len(c.snapshot.PodDisruptionBudgets))
+	for _, pdb := range c.snapshot.PodDisruptionBudgets {
+		pdbs = append(pdbs, pdb.DeepCopy())
+	}
+	return pdbs
+}
+
+func (c *Cache) GetAllHorizontalPodAutoscalers() []*autoscalingv1.HorizontalPodAutoscaler {
+	hpas := make([]*autoscalingv1.HorizontalPodAutoscaler, 0, len(c.snapshot.HorizontalPodAutoscalers))
+	for _, hpa := range c.snapshot.HorizontalPodAutoscalers {
+		hpas = append(hpas, hpa.DeepCopy())
+	}
+	return hpas
+}
+
+func (c *Cache) GetAllPriorityClasses() []*schedulingv1.PriorityClass {
+	priorityClasses := make([]*schedulingv1.PriorityClass, 0, len(c.snapshot.PriorityClasses))
+	for _, pc := range c.snapshot.PriorityClasses {
+		priorityClasses = append(priorityClasses, pc.DeepCopy())
+	}
+	return priorityClasses
+}
+
+func (c *Cache) GetAllLimitRanges() []*v1.LimitRange {
+	limitRanges := make([]*v1.LimitRange, 0, len(c.snapshot.LimitRanges))
+	for _, lr := range c.snapshot.LimitRanges {
+		limitRanges = append(limitRanges, lr.DeepCopy())
+	}
+	return limitRanges
+}
+
+func (c *Cache) GetAllResourceQuotas() []*v1.ResourceQuota {
+	quotas := make([]*v1.ResourceQuota, 0, len(c.snapshot.ResourceQuotas))
+	for _, quota := range c.snapshot.ResourceQuotas {
+		quotas = append(quotas, quota.DeepCopy())
+	}
+	return quotas
+}
+
+func (c *Cache) GetPodsOnNode(nodeName string) []*v1.Pod {
+	return copyPods(c.podsByNode[nodeName])
+}
+
+func (c *Cache) GetPodsInNamespace(namespace string) []*v1.Pod {
+	return copyPods(c.podsByNamespace[namespace])
+}
+
+func (c *Cache) GetPodsForOwner(uid types.UID) []*v1.Pod {
+	return copyPods(c.podsByOwner[uid])
+}
+
+func (c *Cache) GetNodesOfInstanceType(instanceType string) []*v1.Node {
+	var nodes []*v1.Node
+	for _, node := range c.nodesByInstanceType[instanceType] {
+		nodes = append(nodes, node.DeepCopy())
+	}
+	return nodes
+}
+
+func copyPods(pods []*v1.Pod) []*v1.Pod {
+	var copies []*v1.Pod
+	for _, pod := range pods {
+		copies = append(copies, pod.DeepCopy())
+	}
+	return copies
+}
+
+func (c *Cache) SetConfigMapUpdateFunc(func(interface{})) {}
+
+func (c *Cache) SetPodUpdateFunc(func(interface{})) {}
+
+func (c *Cache) SetPodRemovedFunc(func(interface{})) {}
+
+func (c *Cache) SetNodeUpdateFunc(func(interface{})) {}
+
+func (c *Cache) SetNodeRemovedFunc(func(interface{})) {}
+
+// SubscribeConfigMaps does nothing, as snapshots don't include configmaps
+func (c *Cache) SubscribeConfigMaps(func(old *v1.ConfigMap, new *v1.ConfigMap)) {}
+
+func (c *Cache) SubscribeNamespaces(handler func(old *v1.Namespace, new *v1.Namespace)) {
+	for _, ns := range c.GetAllNamespaces() {
+		handler(nil, ns)
+	}
+}
+
+func (c *Cache) SubscribeNodes(handler func(old *v1.Node, new *v1.Node)) {
+	for _, node := range c.GetAllNodes() {
+		handler(nil, node)
+	}
+}
+
+func (c *Cache) SubscribePods(handler func(old *v1.Pod, new *v1.Pod)) {
+	for _, pod := range c.GetAllPods() {
+		handler(nil, pod)
+	}
+}
+
+func (c *Cache) SubscribeServices(handler func(old *v1.Service, new *v1.Service)) {
+	for _, service := range c.GetAllServices() {
+		handler(nil, service)
+	}
+}
+
+func (c *Cache) SubscribeDaemonSets(handler func(old *appsv1.DaemonSet, new *appsv1.DaemonSet)) {
+	for _, ds := range c.GetAllDaemonSets() {
+		handler(nil, ds)
+	}
+}
+
+func (c *Cache) SubscribeDeployments(handler func(old *appsv1.Deployment, new *appsv1.Deployment)) {
+	for _, deployment := range c.GetAllDeployments() {
+		handler(nil, deployment)
+	}
+}
+
+func (c *Cache) SubscribeStatefulSets(handler func(old *appsv1.StatefulSet, new *appsv1.StatefulSet)) {
+	for _, sts := range c.GetAllStatefulSets() {
+		handler(nil, sts)
+	}
+}
+
+func (c *Cache) SubscribeReplicaSets(handler func(old *appsv1.ReplicaSet, new *appsv1.ReplicaSet)) {
+	for _, rs := range c.GetAllReplicaSets() {
+		handler(nil, rs)
+	}
+}
+
+func (c *Cache) SubscribeJobs(handler func(old *batchv1.Job, new *batchv1.Job)) {
+	for _, job := range c.GetAllJobs() {
+		handler(nil, job)
+	}
+}
+
+func (c *Cache) SubscribeCronJobs(handler func(old *batchv1beta1.CronJob, new *batchv1beta1.CronJob)) {
+	for _, cronJob := range c.GetAllCronJobs() {
+		handler(nil, cronJob)
+	}
+}
+
+func (c *Cache) SubscribePersistentVolumes(handler func(old *v1.PersistentVolume, new *v1.PersistentVolume)) {
+	for _, pv := range c.GetAllPersistentVolumes() {
+		handler(nil, pv)
+	}
+}
+
+func (c *Cache) SubscribePersistentVolumeClaims(handler func(old *v1.PersistentVolumeClaim, new *v1.PersistentVolumeClaim)) {
+	for _, pvc := range c.GetAllPersistentVolumeClaims() {
+		handler(nil, pvc)
+	}
+}
+
+func (c *Cache) SubscribeStorageClasses(handler func(old *stv1.StorageClass, new *stv1.StorageClass)) {
+	for _, sc := range c.GetAllStorageClasses() {
+		handler(nil, sc)
+	}
+}
+
+func (c *Cache) SubscribePodDisruptionBudgets(handler func(old *policyv1beta1.PodDisruptionBudget, new *policyv1beta1.PodDisruptionBudget)) {
+	for _, pdb := range c.GetAllPodDisruptionBudgets() {
+		handler(nil, pdb)
+	}
+}
+
+func (c *Cache) SubscribeHorizontalPodAutoscalers(handler func(old *autoscalingv1.HorizontalPodAutoscaler, new *autoscalingv1.HorizontalPodAutoscaler)) {
+	for _, hpa := range c.GetAllHorizontalPodAutoscalers() {
+		handler(nil, hpa)
+	}
+}
+
+func (c *Cache) SubscribePriorityClasses(handler func(old *schedulingv1.PriorityClass, new *schedulingv1.PriorityClass)) {
+	for _, pc := range c.GetAllPriorityClasses() {
+		handler(nil, pc)
+	}
+}
+
+func (c *Cache) SubscribeLimitRanges(handler func(old *v1.LimitRange, new *v1.LimitRange)) {
+	for _, lr := range c.GetAllLimitRanges() {
+		handler(nil, lr)
+	}
+}
+
+func (c *Cache) SubscribeResourceQuotas(handler func(old *v1.ResourceQuota, new *v1.ResourceQuota)) {
+	for _, quota := range c.GetAllResourceQuotas() {
+		handler(nil, quota)
+	}
+}
@@ -0,0 +1,168 @@
+package snapshot
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change types of the resources of two snapshots
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Change is a resource added, removed or changed between two snapshots
+type Change struct {
+	// Resource is the list of the snapshot holding the resource, e.g. pods
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+
+	// Fields are the top level fields of a changed resource which differ, e.g.
+	// metadata, spec or status
+	Fields []string `json:"fields,omitempty"`
+}
+
+// ignoredMetadata are the metadata fields updated by the API server on every
+// write, which don't make a resource changed by themselves
+var ignoredMetadata = []string{"resourceVersion", "managedFields"}
+
+// Diff returns the resources added, removed and changed from the old snapshot to
+// the new one, ordered by resource, namespace and name. Resources are compared by
+// their JSON representation, ignoring the resource version.
+func Diff(old *Snapshot, new *Snapshot) ([]*Change, error) {
+	oldLists, err := lists(old)
+	if err != nil {
+		return nil, err
+	}
+	newLists, err := lists(new)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make(map[string]bool)
+	for resource := range oldLists {
+		resources[resource] = true
+	}
+	for resource := range newLists {
+		resources[resource] = true
+	}
+
+	var changes []*Change
+	for resource := range resources {
+		oldObjects, newObjects := oldLists[resource], newLists[resource]
+		for key, oldObject := range oldObjects {
+			newObject, ok := newObjects[key]
+			if !ok {
+				changes = append(changes, &Change{Resource: resource, Namespace: key.namespace, Name: key.name, Type: Removed})
+				continue
+			}
+			if fields := changedFields(oldObject, newObject); len(fields) > 0 {
+				changes = append(changes, &Change{Resource: resource, Namespace: key.namespace, Name: key.name, Type: Changed, Fields: fields})
+			}
+		}
+		for key := range newObjects {
+			if _, ok := oldObjects[key]; !ok {
+				changes = append(changes, &Change{Resource: resource, Namespace: key.namespace, Name: key.name, Type: Added})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return changes, nil
+}
+
+// WriteChanges writes a diff.csv formatted list of the changes
+func WriteChanges(w io.Writer, changes []*Change) error {
+	records := [][]string{
+		{"resource", "namespace", "name", "change", "fields"},
+	}
+	for _, c := range changes {
+		records = append(records, []string{
+			c.Resource,
+			c.Namespace,
+			c.Name,
+			c.Type,
+			strings.Join(c.Fields, ","),
+		})
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+// objectKey identifies a resource within its list
+type objectKey struct {
+	namespace string
+	name      string
+}
+
+// lists returns the resources of every list of the snapshot by their JSON list
+// name, as decoded JSON objects, which compares every resource type alike
+func lists(s *Snapshot) (map[string]map[objectKey]map[string]interface{}, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding snapshot: %s", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed decoding snapshot: %s", err)
+	}
+
+	result := make(map[string]map[objectKey]map[string]interface{})
+	for name, raw := range fields {
+		if len(raw) == 0 || raw[0] != '[' {
+			// version, capture time and cluster id
+			continue
+		}
+		var objects []map[string]interface{}
+		if err := json.Unmarshal(raw, &objects); err != nil {
+			return nil, fmt.Errorf("failed decoding snapshot %s: %s", name, err)
+		}
+
+		byKey := make(map[objectKey]map[string]interface{}, len(objects))
+		for _, object := range objects {
+			metadata, _ := object["metadata"].(map[string]interface{})
+			for _, field := range ignoredMetadata {
+				delete(metadata, field)
+			}
+			namespace, _ := metadata["namespace"].(string)
+			objectName, _ := metadata["name"].(string)
+			byKey[objectKey{namespace: namespace, name: objectName}] = object
+		}
+		result[name] = byKey
+	}
+	return result, nil
+}
+
+// changedFields returns the sorted top level fields which differ between the
+// objects
+func changedFields(old map[string]interface{}, new map[string]interface{}) []string {
+	var fields []string
+	for field, value := range old {
+		if !reflect.DeepEqual(value, new[field]) {
+			fields = append(fields, field)
+		}
+	}
+	for field := range new {
+		if _, ok := old[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,165 @@
+package snapshot
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	stv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+)
+
+// Version is the version of the archives written by this package. Archives of
+// other versions are rejected when read.
+const Version = 1
+
+// Snapshot is the content of the cluster cache at a point in time
+type Snapshot struct {
+	Version    int       `json:"version"`
+	CapturedAt time.Time `json:"capturedAt"`
+	ClusterID  string    `json:"clusterId,omitempty"`
+
+	Namespaces               []*v1.Namespace                          `json:"namespaces"`
+	Nodes                    []*v1.Node                               `json:"nodes"`
+	Pods                     []*v1.Pod                                `json:"pods"`
+	Services                 []*v1.Service                            `json:"services"`
+	DaemonSets               []*appsv1.DaemonSet                      `json:"daemonSets"`
+	Deployments              []*appsv1.Deployment                     `json:"deployments"`
+	StatefulSets             []*appsv1.StatefulSet                    `json:"statefulSets"`
+	ReplicaSets              []*appsv1.ReplicaSet                     `json:"replicaSets"`
+	Jobs                     []*batchv1.Job                           `json:"jobs"`
+	CronJobs                 []*batchv1beta1.CronJob                  `json:"cronJobs"`
+	PersistentVolumes        []*v1.PersistentVolume                   `json:"persistentVolumes"`
+	PersistentVolumeClaims   []*v1.PersistentVolumeClaim              `json:"persistentVolumeClaims"`
+	StorageClasses           []*stv1.StorageClass                     `json:"storageClasses"`
+	PodDisruptionBudgets     []*policyv1beta1.PodDisruptionBudget     `json:"podDisruptionBudgets"`
+	HorizontalPodAutoscalers []*autoscalingv1.HorizontalPodAutoscaler `json:"horizontalPodAutoscalers"`
+	PriorityClasses          []*schedulingv1.PriorityClass            `json:"priorityClasses"`
+	LimitRanges              []*v1.LimitRange                         `json:"limitRanges"`
+	ResourceQuotas           []*v1.ResourceQuota                      `json:"resourceQuotas"`
+}
+
+// Capture copies every resource list of the cache into a snapshot. Every list is
+// ordered by namespace and name, so captures of the same cluster state are
+// identical.
+func Capture(cache clustercache.ClusterCache, clusterID string) *Snapshot {
+	s := &Snapshot{
+		Version:                  Version,
+		CapturedAt:               time.Now().UTC(),
+		ClusterID:                clusterID,
+		Namespaces:               cache.GetAllNamespaces(),
+		Nodes:                    cache.GetAllNodes(),
+		Pods:                     cache.GetAllPods(),
+		Services:                 cache.GetAllServices(),
+		DaemonSets:               cache.GetAllDaemonSets(),
+		Deployments:              cache.GetAllDeployments(),
+		StatefulSets:             cache.GetAllStatefulSets(),
+		ReplicaSets:              cache.GetAllReplicaSets(),
+		Jobs:                     cache.GetAllJobs(),
+		CronJobs:                 cache.GetAllCronJobs(),
+		PersistentVolumes:        cache.GetAllPersistentVolumes(),
+		PersistentVolumeClaims:   cache.GetAllPersistentVolumeClaims(),
+		StorageClasses:           cache.GetAllStorageClasses(),
+		PodDisruptionBudgets:     cache.GetAllPodDisruptionBudgets(),
+		HorizontalPodAutoscalers: cache.GetAllHorizontalPodAutoscalers(),
+		PriorityClasses:          cache.GetAllPriorityClasses(),
+		LimitRanges:              cache.GetAllLimitRanges(),
+		ResourceQuotas:           cache.GetAllResourceQuotas(),
+	}
+	s.sort()
+	return s
+}
+
+// Write writes the snapshot as gzipped JSON
+func Write(w io.Writer, s *Snapshot) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(s); err != nil {
+		gz.Close()
+		return fmt.Errorf("failed encoding snapshot: %s", err)
+	}
+	return gz.Close()
+}
+
+// Read reads a snapshot written by Write
+func Read(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading snapshot: %s", err)
+	}
+	defer gz.Close()
+
+	s := &Snapshot{}
+	if err := json.NewDecoder(gz).Decode(s); err != nil {
+		return nil, fmt.Errorf("failed decoding snapshot: %s", err)
+	}
+	if s.Version != Version {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expected %d", s.Version, Version)
+	}
+	return s, nil
+}
+
+// WriteFile writes the snapshot to the file at path, replacing it
+func WriteFile(path string, s *Snapshot) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFile reads the snapshot from the file at path
+func ReadFile(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+func (s *Snapshot) sort() {
+	sort.Slice(s.Namespaces, func(i, j int) bool { return less(s.Namespaces[i], s.Namespaces[j]) })
+	sort.Slice(s.Nodes, func(i, j int) bool { return less(s.Nodes[i], s.Nodes[j]) })
+	sort.Slice(s.Pods, func(i, j int) bool { return less(s.Pods[i], s.Pods[j]) })
+	sort.Slice(s.Services, func(i, j int) bool { return less(s.Services[i], s.Services[j]) })
+	sort.Slice(s.DaemonSets, func(i, j int) bool { return less(s.DaemonSets[i], s.DaemonSets[j]) })
+	sort.Slice(s.Deployments, func(i, j int) bool { return less(s.Deployments[i], s.Deployments[j]) })
+	sort.Slice(s.StatefulSets, func(i, j int) bool { return less(s.StatefulSets[i], s.StatefulSets[j]) })
+	sort.Slice(s.ReplicaSets, func(i, j int) bool { return less(s.ReplicaSets[i], s.ReplicaSets[j]) })
+	sort.Slice(s.Jobs, func(i, j int) bool { return less(s.Jobs[i], s.Jobs[j]) })
+	sort.Slice(s.CronJobs, func(i, j int) bool { return less(s.CronJobs[i], s.CronJobs[j]) })
+	sort.Slice(s.PersistentVolumes, func(i, j int) bool { return less(s.PersistentVolumes[i], s.PersistentVolumes[j]) })
+	sort.Slice(s.PersistentVolumeClaims, func(i, j int) bool { return less(s.PersistentVolumeClaims[i], s.PersistentVolumeClaims[j]) })
+	sort.Slice(s.StorageClasses, func(i, j int) bool { return less(s.StorageClasses[i], s.StorageClasses[j]) })
+	sort.Slice(s.PodDisruptionBudgets, func(i, j int) bool { return less(s.PodDisruptionBudgets[i], s.PodDisruptionBudgets[j]) })
+	sort.Slice(s.HorizontalPodAutoscalers, func(i, j int) bool { return less(s.HorizontalPodAutoscalers[i], s.HorizontalPodAutoscalers[j]) })
+	sort.Slice(s.PriorityClasses, func(i, j int) bool { return less(s.PriorityClasses[i], s.PriorityClasses[j]) })
+	sort.Slice(s.LimitRanges, func(i, j int) bool { return less(s.LimitRanges[i], s.LimitRanges[j]) })
+	sort.Slice(s.ResourceQuotas, func(i, j int) bool { return less(s.ResourceQuotas[i], s.ResourceQuotas[j]) })
+}
+
+// less orders objects by namespace and name
+func less(a metav1.Object, b metav1.Object) bool {
+	if a.GetNamespace() != b.GetNamespace() {
+		return a.GetNamespace() < b.GetNamespace()
+	}
+	return a.GetName() < b.GetName()
+}
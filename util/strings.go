@@ -0,0 +1,11 @@
+package util
+
+// Contains returns whether the string is one of the strings of arr
+func Contains(arr []string, str string) bool {
+	for _, a := range arr {
+		if a == str {
+			return true
+		}
+	}
+	return false
+}
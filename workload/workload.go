@@ -0,0 +1,128 @@
+package workload
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mikeskali/PerfectScalePoc/clustercache"
+)
+
+// Workload kinds. Besides the kinds of the kubernetes controllers, static pods
+// and pods without any owner get explicit kinds.
+const (
+	KindDeployment            = "Deployment"
+	KindReplicaSet            = "ReplicaSet"
+	KindStatefulSet           = "StatefulSet"
+	KindDaemonSet             = "DaemonSet"
+	KindJob                   = "Job"
+	KindCronJob               = "CronJob"
+	KindReplicationController = "ReplicationController"
+	KindStaticPod             = "StaticPod"
+	KindBarePod               = "BarePod"
+)
+
+// mirrorPodAnnotation is set by the kubelet on the mirror pods of static pods
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// Workload is the stable identity of the top level owner of a pod, which does
+// not change when the pod, or an intermediate owner, is replaced.
+type Workload struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// String returns the workload as namespace/kind/name
+func (w Workload) String() string {
+	return w.Namespace + "/" + w.Kind + "/" + w.Name
+}
+
+// Resolver resolves the workload of pods by walking their owner references up
+// through the cached ReplicaSets and Jobs: pods of a ReplicaSet owned by a
+// Deployment resolve to the Deployment, and pods of a Job owned by a CronJob
+// resolve to the CronJob.
+type Resolver struct {
+	replicaSets map[string]*appsv1.ReplicaSet
+	jobs        map[string]*batchv1.Job
+}
+
+// NewResolver creates a resolver from the ReplicaSets and Jobs of the cluster
+func NewResolver(replicaSets []*appsv1.ReplicaSet, jobs []*batchv1.Job) *Resolver {
+	r := &Resolver{
+		replicaSets: make(map[string]*appsv1.ReplicaSet, len(replicaSets)),
+		jobs:        make(map[string]*batchv1.Job, len(jobs)),
+	}
+	for _, rs := range replicaSets {
+		r.replicaSets[rs.Namespace+"/"+rs.Name] = rs
+	}
+	for _, job := range jobs {
+		r.jobs[job.Namespace+"/"+job.Name] = job
+	}
+	return r
+}
+
+// NewResolverFromCache creates a resolver from the ReplicaSets and Jobs of the cache
+func NewResolverFromCache(cache clustercache.ClusterCache) *Resolver {
+	return NewResolver(cache.GetAllReplicaSets(), cache.GetAllJobs())
+}
+
+// Resolve returns the workload of the pod. Mirror pods of static pods resolve to
+// a StaticPod named after the static pod manifest, i.e. without the node name
+// suffix, and pods without an owner resolve to a BarePod named after the pod. An
+// owner missing from the cache is used as the workload as is.
+func (r *Resolver) Resolve(pod *v1.Pod) Workload {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return Workload{
+			Kind:      KindStaticPod,
+			Namespace: pod.Namespace,
+			Name:      strings.TrimSuffix(pod.Name, "-"+pod.Spec.NodeName),
+		}
+	}
+
+	owner := controllerOf(pod.OwnerReferences)
+	if owner == nil {
+		return Workload{Kind: KindBarePod, Namespace: pod.Namespace, Name: pod.Name}
+	}
+	if owner.Kind == "Node" {
+		return Workload{
+			Kind:      KindStaticPod,
+			Namespace: pod.Namespace,
+			Name:      strings.TrimSuffix(pod.Name, "-"+owner.Name),
+		}
+	}
+
+	w := Workload{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}
+	switch owner.Kind {
+	case KindReplicaSet:
+		if rs, ok := r.replicaSets[pod.Namespace+"/"+owner.Name]; ok {
+			if parent := controllerOf(rs.OwnerReferences); parent != nil && parent.Kind == KindDeployment {
+				w.Kind, w.Name = parent.Kind, parent.Name
+			}
+		}
+	case KindJob:
+		if job, ok := r.jobs[pod.Namespace+"/"+owner.Name]; ok {
+			if parent := controllerOf(job.OwnerReferences); parent != nil && parent.Kind == KindCronJob {
+				w.Kind, w.Name = parent.Kind, parent.Name
+			}
+		}
+	}
+	return w
+}
+
+// controllerOf returns the controller owner reference, or the first owner
+// reference when none is marked as the controller
+func controllerOf(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	if len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}